@@ -6,13 +6,32 @@ import (
 	"fmt"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
+	"nfreconfig-mcp-server/internal/config"
+	"nfreconfig-mcp-server/internal/identity"
+	"nfreconfig-mcp-server/internal/kube"
+	"nfreconfig-mcp-server/internal/telemetry"
 	"nfreconfig-mcp-server/internal/tools"
 	"github.com/modelcontextprotocol/go-sdk/mcp"
 )
 
+const (
+	serverName    = "nfreconfig-mcp-server"
+	serverVersion = "0.1.0"
+)
+
 var (
-	httpAddr = flag.String("http", "", "if set, use streamable HTTP to serve MCP (on this address), instead of stdin/stdout")
+	httpAddr      = flag.String("http", "", "if set, use streamable HTTP to serve MCP (on this address), instead of stdin/stdout")
+	traceExporter = flag.String("trace-exporter", "", "trace exporter: otlp, file or none (default: otlp if OTEL_EXPORTER_OTLP_ENDPOINT is set, otherwise none)")
+	traceFile     = flag.String("trace-file", "", "output file for -trace-exporter=file")
+	configPath    = flag.String("config", os.Getenv(config.EnvPath), "YAML config file with tool defaults (env "+config.EnvPath+"); reloaded on change")
+	configReload  = flag.Duration("config-reload-interval", 5*time.Second, "how often to check the config file for changes")
+	kubeconfig    = flag.String("kubeconfig", "", "kubeconfig file(s), ':'-separated and merged like kubectl; overrides kube.kubeconfig and $KUBECONFIG")
+	mgmtContext   = flag.String("mgmt-context", "", "management cluster kube context; overrides kube.managementContext (default: current-context)")
+	drainTimeout  = flag.Duration("drain-timeout", 25*time.Second, "on SIGTERM/SIGINT, how long in-flight tool calls may run before they are cancelled")
 )
 
 func main() {
@@ -25,22 +44,100 @@ func main() {
 }
 
 func run() error {
+	if *configPath != "" {
+		cfg, err := config.Load(*configPath)
+		if err != nil {
+			return err
+		}
+		config.Set(cfg, *configPath)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go config.Watch(ctx, *configPath, *configReload, func(format string, args ...any) {
+			fmt.Fprintf(os.Stderr, format+"\n", args...)
+		})
+	}
+
+	kube.SetFlagOverrides(*kubeconfig, *mgmtContext)
+
+	shutdownTracing, err := telemetry.Setup(context.Background(), telemetry.Options{
+		Exporter:       *traceExporter,
+		File:           *traceFile,
+		ServiceName:    serverName,
+		ServiceVersion: serverVersion,
+	})
+	if err != nil {
+		return fmt.Errorf("setup tracing: %w", err)
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			fmt.Fprintf(os.Stderr, "flush traces: %v\n", err)
+		}
+	}()
+
 	server := mcp.NewServer(&mcp.Implementation{
-		Name:    "nfreconfig-mcp-server",
-		Version: "0.1.0",
+		Name:    serverName,
+		Version: serverVersion,
 	}, nil)
 
 	tools.AddToolsToServer(server)
 
+	sigCtx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer stop()
+
 	if *httpAddr != "" {
 		handler := mcp.NewStreamableHTTPHandler(func(req *http.Request) *mcp.Server {
 			return server
 		}, nil)
+		srv := &http.Server{Addr: *httpAddr, Handler: identity.HTTPMiddleware(handler)}
 
+		errc := make(chan error, 1)
+		go func() { errc <- srv.ListenAndServe() }()
 		fmt.Fprintf(os.Stderr, "MCP server listening at %s\n", *httpAddr)
-		return http.ListenAndServe(*httpAddr, handler)
+
+		select {
+		case err := <-errc:
+			return err
+		case <-sigCtx.Done():
+		}
+		// Keep serving while tool calls drain so their results still reach clients.
+		fmt.Fprintf(os.Stderr, "shutting down: draining tool calls (up to %s)\n", *drainTimeout)
+		drain()
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := srv.Shutdown(ctx); err != nil {
+			// streamable HTTP sessions hold long-lived GET streams open
+			_ = srv.Close()
+		}
+		return nil
 	} else {
 		// fmt.Fprintf(os.Stderr, "Starting MCP server on stdio\n")
-		return server.Run(context.Background(), mcp.NewStdioTransport())
+		runCtx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		errc := make(chan error, 1)
+		go func() { errc <- server.Run(runCtx, mcp.NewStdioTransport()) }()
+
+		select {
+		case err := <-errc:
+			drain()
+			return err
+		case <-sigCtx.Done():
+		}
+		fmt.Fprintf(os.Stderr, "shutting down: draining tool calls (up to %s)\n", *drainTimeout)
+		drain()
+		cancel()
+		<-errc
+		return nil
+	}
+}
+
+// drain waits for in-flight tool calls and runs cleanup hooks.
+func drain() {
+	ctx, cancel := context.WithTimeout(context.Background(), *drainTimeout)
+	defer cancel()
+	if err := tools.Drain(ctx); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
 	}
 }
@@ -0,0 +1,495 @@
+// Package config holds the server configuration: tool defaults, the management
+// cluster, allowed repositories and git credential references. It is loaded from a
+// YAML file at startup and reloaded when the file changes.
+package config
+
+import (
+	"fmt"
+	"net/netip"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"sigs.k8s.io/yaml"
+)
+
+// EnvPath names the config file when -config is not given.
+const EnvPath = "NFRECONFIG_CONFIG"
+
+type Config struct {
+	Kube        Kube         `json:"kube"`
+	Git         Git          `json:"git"`
+	ArgoCD      ArgoCD       `json:"argocd"`
+	Repos       Repos        `json:"repos"`
+	Scan        Scan         `json:"scan"`
+	Tools       Tools        `json:"tools"`
+	Credentials []Credential `json:"credentials,omitempty"`
+}
+
+type Kube struct {
+	Kubeconfig        string `json:"kubeconfig,omitempty"`        // file or ":"-separated list; default: $KUBECONFIG, then ~/.kube/config
+	ManagementContext string `json:"managementContext,omitempty"` // default: kubeconfig current-context
+	// PreferUserKubeconfig reads a CAPI cluster's <name>-user-kubeconfig secret
+	// before <name>-kubeconfig.
+	PreferUserKubeconfig bool `json:"preferUserKubeconfig,omitempty"`
+	// CredentialProviders is the order in which workload cluster credentials are
+	// looked up: capi, argocd, nephio, kubeconfig, registry.
+	CredentialProviders []string `json:"credentialProviders"`
+	// ClusterRegistry is a YAML file listing clusters and their kubeconfig files,
+	// used by the "registry" provider.
+	ClusterRegistry string `json:"clusterRegistry,omitempty"`
+	// Impersonation makes Kubernetes requests on behalf of the MCP caller.
+	Impersonation Impersonation `json:"impersonation"`
+	// Client tunes rate limiting, timeouts and retries of Kubernetes requests.
+	Client KubeClient `json:"client"`
+}
+
+// KubeClient applies to every Kubernetes client the server builds. Durations use
+// Go syntax ("30s").
+type KubeClient struct {
+	QPS   float32 `json:"qps"`   // per API server, shared by all tool calls
+	Burst int     `json:"burst"` // per API server
+	// RequestTimeout bounds one HTTP request to an API server.
+	RequestTimeout string `json:"requestTimeout"`
+	// DialTimeout bounds the TCP connect, so an unreachable API server fails fast.
+	DialTimeout string `json:"dialTimeout"`
+	// Retries is how often a request failing with 429, 5xx or a refused
+	// connection is retried; RetryBackoff is the first delay, doubled per attempt.
+	Retries      int    `json:"retries"`
+	RetryBackoff string `json:"retryBackoff"`
+}
+
+// Impersonation configures Impersonate-User/Group on Kubernetes requests. The
+// server's ServiceAccount (or kubeconfig user) needs the "impersonate" verb.
+type Impersonation struct {
+	Enabled bool `json:"enabled,omitempty"`
+	// UserHeader and GroupsHeader name the HTTP headers an authenticating proxy
+	// in front of the server sets to the caller's identity. Groups may be repeated
+	// or comma-separated.
+	UserHeader   string `json:"userHeader"`
+	GroupsHeader string `json:"groupsHeader"`
+	// AllowSessionIdentity lets a client pick its identity with the
+	// session_set_identity tool when no header identity is present (stdio).
+	AllowSessionIdentity bool `json:"allowSessionIdentity,omitempty"`
+	// Required rejects Kubernetes calls without a caller identity instead of
+	// running them as the server.
+	Required bool `json:"required,omitempty"`
+	// AllowedGroups, when set, are the only groups a caller may impersonate.
+	// system: groups (system:masters) are refused either way.
+	AllowedGroups []string `json:"allowedGroups,omitempty"`
+	// AllowedSystemUsers are the system: users (e.g. a ServiceAccount,
+	// system:serviceaccount:ns:name) a caller may impersonate; other system:
+	// users are refused.
+	AllowedSystemUsers []string `json:"allowedSystemUsers,omitempty"`
+	// SessionIdleTimeout ends an HTTP session bound to an identity after this
+	// long without a request; the client has to initialize a new one.
+	SessionIdleTimeout string `json:"sessionIdleTimeout"`
+	// TrustedProxies are the addresses or CIDRs identity headers are accepted
+	// from; a request from another peer carrying them gets 403. The default,
+	// loopback, fits an authenticating proxy in the same pod.
+	TrustedProxies []string `json:"trustedProxies"`
+}
+
+// ParseTrustedProxy parses a kube.impersonation.trustedProxies entry: a CIDR
+// or a single address.
+func ParseTrustedProxy(s string) (netip.Prefix, error) {
+	s = strings.TrimSpace(s)
+	if p, err := netip.ParsePrefix(s); err == nil {
+		return p.Masked(), nil
+	}
+	a, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Prefix{}, fmt.Errorf("%q is neither an address nor a CIDR", s)
+	}
+	return netip.PrefixFrom(a, a.BitLen()), nil
+}
+
+// CredentialProviderNames are the valid kube.credentialProviders entries.
+var CredentialProviderNames = []string{"capi", "argocd", "nephio", "kubeconfig", "registry"}
+
+type Git struct {
+	Ref              string `json:"ref"`       // clone ref and push branch
+	Depth            int    `json:"depth"`     // clone depth
+	CacheRoot        string `json:"cacheRoot"` // where git_clone_repos keeps workdirs
+	CloneConcurrency int    `json:"cloneConcurrency"`
+	PushConcurrency  int    `json:"pushConcurrency"`
+}
+
+type ArgoCD struct {
+	Namespace string `json:"namespace"`
+}
+
+type Repos struct {
+	Prefix string `json:"prefix"` // Porch repository name prefix
+	// Allowed restricts clone/push to these URLs. An entry ending in "/" or "*"
+	// matches as a prefix; otherwise the URL must match (ignoring ".git" and a
+	// trailing slash). Empty allows everything.
+	Allowed []string `json:"allowed,omitempty"`
+}
+
+type Scan struct {
+	MaxFiles int `json:"maxFiles"`
+	// ClusterConcurrency and ClusterTimeout bound cluster_scan_topology: how
+	// many clusters are scanned at once and how long one may take.
+	ClusterConcurrency int    `json:"clusterConcurrency"`
+	ClusterTimeout     string `json:"clusterTimeout"`
+}
+
+type Tools struct {
+	// DefaultTimeout bounds a tool call when neither Timeouts nor the tool sets one.
+	DefaultTimeout string `json:"defaultTimeout"`
+	// Timeouts overrides the timeout of individual tools, keyed by tool name.
+	Timeouts map[string]string `json:"timeouts,omitempty"`
+}
+
+// Credential tells the server where to find HTTP credentials for repos under
+// URLPrefix. Only references are configured; the values never appear in the config.
+type Credential struct {
+	Name         string `json:"name"`
+	URLPrefix    string `json:"urlPrefix"`
+	UsernameEnv  string `json:"usernameEnv,omitempty"`
+	UsernameFile string `json:"usernameFile,omitempty"`
+	PasswordEnv  string `json:"passwordEnv,omitempty"`
+	PasswordFile string `json:"passwordFile,omitempty"`
+}
+
+// Default returns the built-in defaults, used when no file is configured and as
+// the base that a file overrides.
+func Default() *Config {
+	root := "/tmp/nfreconfig-mcp-server/git-cache"
+	if home := strings.TrimSpace(os.Getenv("HOME")); home != "" {
+		root = filepath.Join(home, ".cache", "nfreconfig-mcp-server", "git-cache")
+	}
+	return &Config{
+		Kube: Kube{
+			CredentialProviders: append([]string(nil), CredentialProviderNames...),
+			Impersonation: Impersonation{
+				UserHeader:         "X-Forwarded-User",
+				GroupsHeader:       "X-Forwarded-Groups",
+				TrustedProxies:     []string{"127.0.0.1/32", "::1/128"},
+				SessionIdleTimeout: "30m",
+			},
+			Client: KubeClient{
+				QPS:            20,
+				Burst:          40,
+				RequestTimeout: "30s",
+				DialTimeout:    "5s",
+				Retries:        3,
+				RetryBackoff:   "250ms",
+			},
+		},
+		Git: Git{
+			Ref:              "main",
+			Depth:            1,
+			CacheRoot:        root,
+			CloneConcurrency: 4,
+			PushConcurrency:  3,
+		},
+		ArgoCD: ArgoCD{Namespace: "argocd"},
+		Repos:  Repos{Prefix: "5g-"},
+		Scan:   Scan{MaxFiles: 5000, ClusterConcurrency: 4, ClusterTimeout: "60s"},
+		Tools:  Tools{DefaultTimeout: "2m"},
+	}
+}
+
+// Parse overlays YAML onto the defaults. Unknown keys are rejected so typos
+// don't silently fall back to a default.
+func Parse(data []byte) (*Config, error) {
+	c := Default()
+	if err := yaml.UnmarshalStrict(data, c); err != nil {
+		return nil, err
+	}
+	if err := c.validate(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func Load(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	c, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("config %s: %w", path, err)
+	}
+	return c, nil
+}
+
+func (c *Config) validate() error {
+	d := Default()
+	if strings.TrimSpace(c.Git.Ref) == "" {
+		c.Git.Ref = d.Git.Ref
+	}
+	if strings.TrimSpace(c.Git.CacheRoot) == "" {
+		c.Git.CacheRoot = d.Git.CacheRoot
+	}
+	if strings.TrimSpace(c.ArgoCD.Namespace) == "" {
+		c.ArgoCD.Namespace = d.ArgoCD.Namespace
+	}
+	if strings.TrimSpace(c.Kube.Impersonation.UserHeader) == "" {
+		c.Kube.Impersonation.UserHeader = d.Kube.Impersonation.UserHeader
+	}
+	if strings.TrimSpace(c.Kube.Impersonation.GroupsHeader) == "" {
+		c.Kube.Impersonation.GroupsHeader = d.Kube.Impersonation.GroupsHeader
+	}
+	if len(c.Kube.Impersonation.TrustedProxies) == 0 {
+		c.Kube.Impersonation.TrustedProxies = d.Kube.Impersonation.TrustedProxies
+	}
+	for _, p := range c.Kube.Impersonation.TrustedProxies {
+		if _, err := ParseTrustedProxy(p); err != nil {
+			return fmt.Errorf("kube.impersonation.trustedProxies: %w", err)
+		}
+	}
+	if len(c.Kube.CredentialProviders) == 0 {
+		c.Kube.CredentialProviders = d.Kube.CredentialProviders
+	}
+	seen := map[string]bool{}
+	for _, name := range c.Kube.CredentialProviders {
+		if !slices.Contains(CredentialProviderNames, name) {
+			return fmt.Errorf("kube.credentialProviders: unknown provider %q (valid: %s)", name, strings.Join(CredentialProviderNames, ", "))
+		}
+		if seen[name] {
+			return fmt.Errorf("kube.credentialProviders: %q listed twice", name)
+		}
+		seen[name] = true
+	}
+	for _, f := range []struct {
+		name string
+		v    *string
+		def  string
+	}{
+		{"kube.client.requestTimeout", &c.Kube.Client.RequestTimeout, d.Kube.Client.RequestTimeout},
+		{"kube.client.dialTimeout", &c.Kube.Client.DialTimeout, d.Kube.Client.DialTimeout},
+		{"kube.client.retryBackoff", &c.Kube.Client.RetryBackoff, d.Kube.Client.RetryBackoff},
+		{"kube.impersonation.sessionIdleTimeout", &c.Kube.Impersonation.SessionIdleTimeout, d.Kube.Impersonation.SessionIdleTimeout},
+		{"scan.clusterTimeout", &c.Scan.ClusterTimeout, d.Scan.ClusterTimeout},
+	} {
+		if strings.TrimSpace(*f.v) == "" {
+			*f.v = f.def
+		}
+		if _, err := parsePositiveDuration(*f.v); err != nil {
+			return fmt.Errorf("%s: %w", f.name, err)
+		}
+	}
+	switch {
+	case c.Kube.Client.QPS <= 0:
+		return fmt.Errorf("kube.client.qps must be > 0")
+	case c.Kube.Client.Burst <= 0:
+		return fmt.Errorf("kube.client.burst must be > 0")
+	case c.Kube.Client.Retries < 0:
+		return fmt.Errorf("kube.client.retries must be >= 0")
+	case c.Git.Depth < 0:
+		return fmt.Errorf("git.depth must be >= 0")
+	case c.Git.CloneConcurrency <= 0:
+		return fmt.Errorf("git.cloneConcurrency must be > 0")
+	case c.Git.PushConcurrency <= 0:
+		return fmt.Errorf("git.pushConcurrency must be > 0")
+	case c.Scan.MaxFiles <= 0:
+		return fmt.Errorf("scan.maxFiles must be > 0")
+	case c.Scan.ClusterConcurrency <= 0:
+		return fmt.Errorf("scan.clusterConcurrency must be > 0")
+	}
+	if strings.TrimSpace(c.Tools.DefaultTimeout) == "" {
+		c.Tools.DefaultTimeout = d.Tools.DefaultTimeout
+	}
+	if _, err := parsePositiveDuration(c.Tools.DefaultTimeout); err != nil {
+		return fmt.Errorf("tools.defaultTimeout: %w", err)
+	}
+	for name, v := range c.Tools.Timeouts {
+		if _, err := parsePositiveDuration(v); err != nil {
+			return fmt.Errorf("tools.timeouts.%s: %w", name, err)
+		}
+	}
+	for i, cr := range c.Credentials {
+		if strings.TrimSpace(cr.URLPrefix) == "" {
+			return fmt.Errorf("credentials[%d]: urlPrefix is required", i)
+		}
+		if cr.PasswordEnv == "" && cr.PasswordFile == "" {
+			return fmt.Errorf("credentials[%d]: one of passwordEnv or passwordFile is required", i)
+		}
+	}
+	return nil
+}
+
+func parsePositiveDuration(s string) (time.Duration, error) {
+	d, err := time.ParseDuration(strings.TrimSpace(s))
+	if err != nil {
+		return 0, err
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("must be positive, got %s", s)
+	}
+	return d, nil
+}
+
+// Duration parses a duration that validate has already checked; def covers an
+// unset value.
+func Duration(s string, def time.Duration) time.Duration {
+	if d, err := parsePositiveDuration(s); err == nil {
+		return d
+	}
+	return def
+}
+
+// ---------------- current config ----------------
+
+type state struct {
+	cfg      *Config
+	path     string
+	loadedAt time.Time
+}
+
+var current atomic.Pointer[state]
+
+func init() { current.Store(&state{cfg: Default()}) }
+
+// Current returns the effective config. Callers must treat it as read-only; a
+// reload swaps in a new value rather than mutating this one.
+func Current() *Config { return current.Load().cfg }
+
+// Set makes c the effective config; path is the file it came from ("" for defaults).
+func Set(c *Config, path string) {
+	current.Store(&state{cfg: c, path: path, loadedAt: time.Now()})
+}
+
+// Source returns the file the effective config was loaded from and when.
+func Source() (path string, loadedAt time.Time) {
+	s := current.Load()
+	return s.path, s.loadedAt
+}
+
+// ---------------- helpers used by tools ----------------
+
+// RepoAllowed reports whether repoURL may be cloned or pushed.
+func (c *Config) RepoAllowed(repoURL string) bool {
+	if len(c.Repos.Allowed) == 0 {
+		return true
+	}
+	u := normalizeURL(repoURL)
+	for _, a := range c.Repos.Allowed {
+		a = strings.TrimSpace(a)
+		if p, ok := strings.CutSuffix(a, "*"); ok {
+			if strings.HasPrefix(u, stripUserinfo(p)) {
+				return true
+			}
+			continue
+		}
+		if strings.HasSuffix(a, "/") {
+			if strings.HasPrefix(u, stripUserinfo(a)) {
+				return true
+			}
+			continue
+		}
+		if u == normalizeURL(a) {
+			return true
+		}
+	}
+	return false
+}
+
+// ToolTimeout returns the timeout for a tool call: tools.timeouts[name], then the
+// tool's own default (toolDefault, 0 if none), then tools.defaultTimeout.
+func (c *Config) ToolTimeout(name string, toolDefault time.Duration) time.Duration {
+	if v, ok := c.Tools.Timeouts[name]; ok {
+		if d, err := parsePositiveDuration(v); err == nil {
+			return d
+		}
+	}
+	if toolDefault > 0 {
+		return toolDefault
+	}
+	d, _ := parsePositiveDuration(c.Tools.DefaultTimeout)
+	return d
+}
+
+// CredentialsFor resolves the credential whose urlPrefix is the longest match for
+// repoURL. ok is false when none matches.
+func (c *Config) CredentialsFor(repoURL string) (username, password string, ok bool, err error) {
+	u := normalizeURL(repoURL)
+	var best *Credential
+	for i := range c.Credentials {
+		cr := &c.Credentials[i]
+		p := stripUserinfo(strings.TrimSpace(cr.URLPrefix))
+		if strings.HasPrefix(u, p) && (best == nil || len(p) > len(stripUserinfo(best.URLPrefix))) {
+			best = cr
+		}
+	}
+	if best == nil {
+		return "", "", false, nil
+	}
+	if username, err = readRef(best.UsernameEnv, best.UsernameFile); err != nil {
+		return "", "", true, fmt.Errorf("credential %q username: %w", best.Name, err)
+	}
+	if password, err = readRef(best.PasswordEnv, best.PasswordFile); err != nil {
+		return "", "", true, fmt.Errorf("credential %q password: %w", best.Name, err)
+	}
+	return username, password, true, nil
+}
+
+func readRef(env, file string) (string, error) {
+	if env != "" {
+		if v, ok := os.LookupEnv(env); ok {
+			return v, nil
+		}
+		if file == "" {
+			return "", fmt.Errorf("environment variable %s is not set", env)
+		}
+	}
+	if file != "" {
+		b, err := os.ReadFile(file)
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimRight(string(b), "\r\n"), nil
+	}
+	return "", nil
+}
+
+// Redacted returns a copy safe to show to clients: passwords embedded in URLs
+// are masked. Credential entries are references only and are kept as-is.
+func (c *Config) Redacted() *Config {
+	r := *c
+	r.Repos.Allowed = make([]string, len(c.Repos.Allowed))
+	for i, a := range c.Repos.Allowed {
+		r.Repos.Allowed[i] = maskUserinfo(a)
+	}
+	r.Credentials = make([]Credential, len(c.Credentials))
+	for i, cr := range c.Credentials {
+		cr.URLPrefix = maskUserinfo(cr.URLPrefix)
+		r.Credentials[i] = cr
+	}
+	return &r
+}
+
+func normalizeURL(s string) string {
+	s = stripUserinfo(strings.TrimSpace(s))
+	s = strings.TrimSuffix(s, "/")
+	s = strings.TrimSuffix(s, ".git")
+	return s
+}
+
+func stripUserinfo(s string) string {
+	u, err := url.Parse(s)
+	if err != nil || u.User == nil {
+		return s
+	}
+	u.User = nil
+	return u.String()
+}
+
+func maskUserinfo(s string) string {
+	u, err := url.Parse(s)
+	if err != nil || u.User == nil {
+		return s
+	}
+	if _, has := u.User.Password(); has {
+		u.User = url.UserPassword(u.User.Username(), "REDACTED")
+	}
+	return u.String()
+}
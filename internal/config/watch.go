@@ -0,0 +1,48 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"time"
+)
+
+// Watch polls path and swaps in the new config whenever its content changes.
+// Polling (rather than inotify) also catches ConfigMap updates, which replace a
+// symlink instead of writing the file. A file that fails to parse is reported
+// through logf and the previous config stays in effect.
+func Watch(ctx context.Context, path string, interval time.Duration, logf func(format string, args ...any)) {
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+	last, _ := os.ReadFile(path)
+
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			if last != nil {
+				logf("config: read %s: %v (keeping previous config)", path, err)
+				last = nil
+			}
+			continue
+		}
+		if bytes.Equal(data, last) {
+			continue
+		}
+		last = data
+		c, err := Parse(data)
+		if err != nil {
+			logf("config: %s: %v (keeping previous config)", path, err)
+			continue
+		}
+		Set(c, path)
+		logf("config: reloaded %s", path)
+	}
+}
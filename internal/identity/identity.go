@@ -0,0 +1,233 @@
+// Package identity carries the MCP caller's identity through a tool call so
+// Kubernetes requests can impersonate it (kube.impersonation in the server config).
+package identity
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/netip"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"nfreconfig-mcp-server/internal/config"
+)
+
+// Identity is a Kubernetes user and its groups.
+type Identity struct {
+	User   string   `json:"user"`
+	Groups []string `json:"groups,omitempty"`
+	Source string   `json:"source,omitempty"` // "header" or "session"
+}
+
+func (id Identity) IsZero() bool { return id.User == "" }
+
+// Key identifies the identity for caching: user and sorted groups.
+func (id Identity) Key() string {
+	g := append([]string(nil), id.Groups...)
+	sort.Strings(g)
+	return id.User + "|" + strings.Join(g, ",")
+}
+
+type ctxKey struct{}
+
+// With returns ctx carrying id.
+func With(ctx context.Context, id Identity) context.Context {
+	return context.WithValue(ctx, ctxKey{}, id)
+}
+
+// From returns the identity carried by ctx, if any.
+func From(ctx context.Context) (Identity, bool) {
+	id, ok := ctx.Value(ctxKey{}).(Identity)
+	return id, ok && !id.IsZero()
+}
+
+// ErrGroupNotAllowed is returned for an identity with a group that may not be
+// impersonated.
+var ErrGroupNotAllowed = errors.New("group may not be impersonated")
+
+// CheckGroups refuses system: groups (system:masters would make any caller
+// cluster-admin) and, when allowed is set, groups not in it.
+func CheckGroups(groups, allowed []string) error {
+	for _, g := range groups {
+		if strings.HasPrefix(g, "system:") {
+			return fmt.Errorf("%w: %s is a system group", ErrGroupNotAllowed, g)
+		}
+		if len(allowed) > 0 && !slices.Contains(allowed, g) {
+			return fmt.Errorf("%w: %s is not in kube.impersonation.allowedGroups", ErrGroupNotAllowed, g)
+		}
+	}
+	return nil
+}
+
+// ErrUserNotAllowed is returned for an identity whose user may not be
+// impersonated.
+var ErrUserNotAllowed = errors.New("user may not be impersonated")
+
+// CheckUser refuses system: users (system:admin, system:kube-scheduler, ...)
+// that allowed does not list.
+func CheckUser(user string, allowed []string) error {
+	if strings.HasPrefix(user, "system:") && !slices.Contains(allowed, user) {
+		return fmt.Errorf("%w: %s is a system user not in kube.impersonation.allowedSystemUsers", ErrUserNotAllowed, user)
+	}
+	return nil
+}
+
+// Check applies CheckUser and CheckGroups with the limits in imp.
+func Check(id Identity, imp config.Impersonation) error {
+	if err := CheckUser(id.User, imp.AllowedSystemUsers); err != nil {
+		return err
+	}
+	return CheckGroups(id.Groups, imp.AllowedGroups)
+}
+
+type httpKey struct{}
+
+// OverHTTP reports whether ctx belongs to a call served by HTTPMiddleware
+// rather than over stdio.
+func OverHTTP(ctx context.Context) bool {
+	v, _ := ctx.Value(httpKey{}).(bool)
+	return v
+}
+
+// sessionIDHeader is the streamable HTTP session header of the MCP spec.
+const sessionIDHeader = "Mcp-Session-Id"
+
+// HTTPMiddleware reads the caller identity set by an authenticating proxy
+// (kube.impersonation.userHeader/groupsHeader) into the request context.
+// The headers are only trusted from kube.impersonation.trustedProxies; a
+// request from another peer that carries them is rejected, as is an identity
+// Check refuses.
+//
+// The MCP SDK keeps the context of the request that opened a session for every
+// later tool call in it, so a session is bound to the identity it was opened
+// with; requests for that session under another identity are rejected. The
+// binding ends with the client's DELETE or after
+// kube.impersonation.sessionIdleTimeout without a request; an expired session
+// is closed and its requests get 404, so the client initializes a new one.
+func HTTPMiddleware(next http.Handler) http.Handler {
+	var (
+		mu    sync.Mutex
+		bound = map[string]*boundSession{} // by session id
+	)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r = r.WithContext(context.WithValue(r.Context(), httpKey{}, true))
+		imp := config.Current().Kube.Impersonation
+		if !imp.Enabled {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if (r.Header.Get(imp.UserHeader) != "" || r.Header.Get(imp.GroupsHeader) != "") && !trustedPeer(r.RemoteAddr, imp.TrustedProxies) {
+			http.Error(w, "identity headers are only accepted from trusted proxies", http.StatusForbidden)
+			return
+		}
+		id := fromHeaders(r.Header, imp.UserHeader, imp.GroupsHeader)
+		if err := Check(id, imp); err != nil {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+		idle := config.Duration(imp.SessionIdleTimeout, 30*time.Minute)
+
+		if sid := r.Header.Get(sessionIDHeader); sid != "" {
+			now := time.Now()
+			mu.Lock()
+			b, ok := bound[sid]
+			expired := ok && now.Sub(b.seen) >= idle
+			switch {
+			case expired, ok && b.key == id.Key() && r.Method == http.MethodDelete:
+				delete(bound, sid)
+			case ok && b.key == id.Key():
+				b.seen = now
+			}
+			mu.Unlock()
+			if expired {
+				closeSession(next, r, sid)
+				http.Error(w, "session expired", http.StatusNotFound)
+				return
+			}
+			if ok && b.key != id.Key() {
+				http.Error(w, "session belongs to another identity", http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(With(r.Context(), id)))
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(With(r.Context(), id)))
+		if sid := w.Header().Get(sessionIDHeader); sid != "" {
+			// sweep here too: sessions clients never come back to are
+			// not looked up again
+			now := time.Now()
+			var expired []string
+			mu.Lock()
+			for k, b := range bound {
+				if now.Sub(b.seen) >= idle {
+					delete(bound, k)
+					expired = append(expired, k)
+				}
+			}
+			bound[sid] = &boundSession{key: id.Key(), seen: now}
+			mu.Unlock()
+			for _, k := range expired {
+				closeSession(next, r, k)
+			}
+		}
+	})
+}
+
+type boundSession struct {
+	key  string // identity key
+	seen time.Time
+}
+
+// closeSession ends session sid in next the way a client's DELETE does.
+func closeSession(next http.Handler, r *http.Request, sid string) {
+	del := r.Clone(r.Context())
+	del.Method = http.MethodDelete
+	del.Body = http.NoBody
+	del.ContentLength = 0
+	del.Header = http.Header{}
+	del.Header.Set("Accept", "application/json, text/event-stream")
+	del.Header.Set(sessionIDHeader, sid)
+	next.ServeHTTP(discardResponse{http.Header{}}, del)
+}
+
+type discardResponse struct{ h http.Header }
+
+func (d discardResponse) Header() http.Header         { return d.h }
+func (d discardResponse) Write(b []byte) (int, error) { return len(b), nil }
+func (d discardResponse) WriteHeader(int)             {}
+
+func fromHeaders(h http.Header, userHeader, groupsHeader string) Identity {
+	id := Identity{User: strings.TrimSpace(h.Get(userHeader)), Source: "header"}
+	if id.User == "" {
+		return Identity{}
+	}
+	for _, v := range h.Values(groupsHeader) {
+		for _, g := range strings.Split(v, ",") {
+			if g = strings.TrimSpace(g); g != "" {
+				id.Groups = append(id.Groups, g)
+			}
+		}
+	}
+	return id
+}
+
+// trustedPeer reports whether remoteAddr (host:port) is in one of proxies.
+func trustedPeer(remoteAddr string, proxies []string) bool {
+	ap, err := netip.ParseAddrPort(remoteAddr)
+	if err != nil {
+		return false
+	}
+	addr := ap.Addr().Unmap()
+	for _, s := range proxies {
+		if p, err := config.ParseTrustedProxy(s); err == nil && p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,80 @@
+package identity
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"nfreconfig-mcp-server/internal/config"
+)
+
+func TestCheckUser(t *testing.T) {
+	allowed := []string{"system:serviceaccount:ran:inventory"}
+	tests := []struct {
+		user string
+		ok   bool
+	}{
+		{"alice", true},
+		{"system:serviceaccount:ran:inventory", true},
+		{"system:admin", false},
+		{"system:serviceaccount:kube-system:default", false},
+	}
+	for _, tt := range tests {
+		err := CheckUser(tt.user, allowed)
+		if ok := err == nil; ok != tt.ok || !ok && !errors.Is(err, ErrUserNotAllowed) {
+			t.Errorf("CheckUser(%q) = %v; want ok %v", tt.user, err, tt.ok)
+		}
+	}
+}
+
+func TestHTTPMiddlewareExpiresIdleSessions(t *testing.T) {
+	c, err := config.Parse([]byte("kube:\n  impersonation:\n    enabled: true\n    sessionIdleTimeout: 20ms\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	prev := config.Current()
+	config.Set(c, "")
+	defer config.Set(prev, "")
+
+	var n int
+	deleted := map[string]bool{}
+	h := HTTPMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch sid := r.Header.Get(sessionIDHeader); {
+		case r.Method == http.MethodDelete:
+			deleted[sid] = true
+		case sid == "":
+			n++
+			w.Header().Set(sessionIDHeader, string(rune('a'+n-1)))
+		}
+	}))
+	call := func(sid, user string) int {
+		r := httptest.NewRequest(http.MethodPost, "/", nil)
+		r.RemoteAddr = "127.0.0.1:40000"
+		r.Header.Set("X-Forwarded-User", user)
+		if sid != "" {
+			r.Header.Set(sessionIDHeader, sid)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	call("", "alice") // opens session a
+	if code := call("a", "bob"); code != http.StatusForbidden {
+		t.Errorf("session a as bob: status %d; want 403", code)
+	}
+	time.Sleep(30 * time.Millisecond)
+	call("", "carol") // opens session b, sweeping a
+	if !deleted["a"] {
+		t.Errorf("idle session a was not closed when session b opened")
+	}
+	if code := call("b", "carol"); code != http.StatusOK {
+		t.Errorf("session b as carol: status %d; want 200", code)
+	}
+	time.Sleep(30 * time.Millisecond)
+	if code := call("b", "carol"); code != http.StatusNotFound || !deleted["b"] {
+		t.Errorf("idle session b: status %d, closed %v; want 404 and closed", code, deleted["b"])
+	}
+}
@@ -0,0 +1,236 @@
+package kube
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const (
+	// CAPISecretType is the type CAPI gives the secrets it generates.
+	CAPISecretType corev1.SecretType = "cluster.x-k8s.io/secret"
+
+	capiGroup = "cluster.x-k8s.io"
+)
+
+var capiClusterGK = schema.GroupKind{Group: capiGroup, Kind: "Cluster"}
+
+// AmbiguousClusterError is returned when a bare cluster name matches clusters
+// (CAPI Clusters, Nephio WorkloadClusters) in more than one namespace.
+type AmbiguousClusterError struct {
+	Name       string
+	Namespaces []string
+}
+
+func (e *AmbiguousClusterError) Error() string {
+	return fmt.Sprintf("cluster %q exists in namespaces %s; use namespace/name", e.Name, strings.Join(e.Namespaces, ", "))
+}
+
+// WorkloadCluster is a resolved CAPI Cluster with the kubeconfig to reach it.
+type WorkloadCluster struct {
+	Namespace  string
+	Name       string
+	Object     *unstructured.Unstructured
+	Secret     *corev1.Secret
+	Kubeconfig []byte
+}
+
+// WorkloadClusterResolver finds CAPI Clusters on a management cluster and reads
+// their kubeconfig secrets. The Cluster API version is taken from discovery, so
+// v1beta1 and v1beta2 management clusters both work.
+type WorkloadClusterResolver struct {
+	Mgmt *Clients
+	// PreferUserKubeconfig reads <cluster>-user-kubeconfig before <cluster>-kubeconfig.
+	// Providers such as EKS and AKS publish the user variant for non-admin access.
+	PreferUserKubeconfig bool
+}
+
+func NewWorkloadClusterResolver(mgmt *Clients) *WorkloadClusterResolver {
+	return &WorkloadClusterResolver{Mgmt: mgmt}
+}
+
+// ClusterGVR returns the served (preferred) version of cluster.x-k8s.io Clusters.
+func (r *WorkloadClusterResolver) ClusterGVR() (schema.GroupVersionResource, error) {
+	m, err := r.Mgmt.Mapper.RESTMapping(capiClusterGK)
+	if err != nil {
+		if meta.IsNoMatchError(err) {
+			return schema.GroupVersionResource{}, apierrors.NewNotFound(schema.GroupResource{Group: capiGroup, Resource: "clusters"},
+				"(Cluster API is not installed on the management cluster)")
+		}
+		return schema.GroupVersionResource{}, fmt.Errorf("discover CAPI Cluster version: %w", err)
+	}
+	return m.Resource, nil
+}
+
+// List returns all CAPI Clusters, sorted by namespace/name.
+func (r *WorkloadClusterResolver) List(ctx context.Context) ([]unstructured.Unstructured, error) {
+	gvr, err := r.ClusterGVR()
+	if err != nil {
+		return nil, err
+	}
+	ul, err := r.Mgmt.Dynamic.Resource(gvr).Namespace("").List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("list CAPI clusters: %w", err)
+	}
+	items := ul.Items
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].GetNamespace() != items[j].GetNamespace() {
+			return items[i].GetNamespace() < items[j].GetNamespace()
+		}
+		return items[i].GetName() < items[j].GetName()
+	})
+	return items, nil
+}
+
+// Find looks up a Cluster by "name" or "namespace/name". A bare name that exists
+// in several namespaces is an AmbiguousClusterError.
+func (r *WorkloadClusterResolver) Find(ctx context.Context, ref string) (*unstructured.Unstructured, error) {
+	ns, name := SplitClusterRef(ref)
+	gvr, err := r.ClusterGVR()
+	if err != nil {
+		return nil, err
+	}
+	if ns != "" {
+		obj, err := r.Mgmt.Dynamic.Resource(gvr).Namespace(ns).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		return obj, nil
+	}
+
+	items, err := r.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var matches []unstructured.Unstructured
+	for _, it := range items {
+		if it.GetName() == name {
+			matches = append(matches, it)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return nil, apierrors.NewNotFound(gvr.GroupResource(), name)
+	case 1:
+		return &matches[0], nil
+	}
+	amb := &AmbiguousClusterError{Name: name}
+	for _, m := range matches {
+		amb.Namespaces = append(amb.Namespaces, m.GetNamespace())
+	}
+	return nil, amb
+}
+
+// KubeconfigSecret reads the kubeconfig secret of the cluster ns/name, honouring
+// PreferUserKubeconfig and falling back to the other variant when it is missing.
+func (r *WorkloadClusterResolver) KubeconfigSecret(ctx context.Context, ns, name string) (*corev1.Secret, error) {
+	names := []string{name + "-kubeconfig", name + "-user-kubeconfig"}
+	if r.PreferUserKubeconfig {
+		names[0], names[1] = names[1], names[0]
+	}
+	var firstErr error
+	for _, sn := range names {
+		sec, err := r.GetKubeconfigSecret(ctx, ns, sn)
+		if err == nil {
+			return sec, nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+		if !apierrors.IsNotFound(err) {
+			break
+		}
+	}
+	return nil, firstErr
+}
+
+// GetKubeconfigSecret reads one named kubeconfig secret and checks its type.
+// CAPI-generated secrets are cluster.x-k8s.io/secret; hand-made Opaque ones are
+// accepted too.
+func (r *WorkloadClusterResolver) GetKubeconfigSecret(ctx context.Context, ns, secretName string) (*corev1.Secret, error) {
+	sec, err := r.Mgmt.Typed.CoreV1().Secrets(ns).Get(ctx, secretName, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("get kubeconfig secret %s/%s: %w", ns, secretName, err)
+	}
+	if sec.Type != CAPISecretType && sec.Type != corev1.SecretTypeOpaque && sec.Type != "" {
+		return nil, apierrors.NewBadRequest(fmt.Sprintf("secret %s/%s has type %q, want %q", ns, secretName, sec.Type, CAPISecretType))
+	}
+	return sec, nil
+}
+
+// Resolve finds the Cluster and its kubeconfig.
+func (r *WorkloadClusterResolver) Resolve(ctx context.Context, ref string) (*WorkloadCluster, error) {
+	obj, err := r.Find(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	wc := &WorkloadCluster{Namespace: obj.GetNamespace(), Name: obj.GetName(), Object: obj}
+	if wc.Secret, err = r.KubeconfigSecret(ctx, wc.Namespace, wc.Name); err != nil {
+		return nil, err
+	}
+	if wc.Kubeconfig, err = KubeconfigFromSecret(wc.Secret); err != nil {
+		return nil, err
+	}
+	return wc, nil
+}
+
+// SplitClusterRef splits "namespace/name"; a bare name has an empty namespace.
+func SplitClusterRef(ref string) (namespace, name string) {
+	ref = strings.TrimSpace(ref)
+	if ns, n, ok := strings.Cut(ref, "/"); ok {
+		return strings.TrimSpace(ns), strings.TrimSpace(n)
+	}
+	return "", ref
+}
+
+// KubeconfigFromSecret is the one place kubeconfig bytes are taken out of a
+// secret: data["value"] (CAPI), then data["kubeconfig"], then the same keys in
+// stringData; a base64 payload stored inside data is decoded once more.
+func KubeconfigFromSecret(sec *corev1.Secret) ([]byte, error) {
+	var kubeBytes []byte
+	if b, ok := sec.Data["value"]; ok && len(b) > 0 {
+		kubeBytes = b
+	} else if b, ok := sec.Data["kubeconfig"]; ok && len(b) > 0 {
+		kubeBytes = b
+	} else if s, ok := sec.StringData["value"]; ok && s != "" {
+		kubeBytes = []byte(s)
+	} else if s, ok := sec.StringData["kubeconfig"]; ok && s != "" {
+		kubeBytes = []byte(s)
+	}
+	if len(kubeBytes) == 0 {
+		return nil, fmt.Errorf("kubeconfig secret %s/%s missing data[value|kubeconfig]", sec.Namespace, sec.Name)
+	}
+
+	// Some secrets might store base64 string in Data (rare)
+	if looksBase64(kubeBytes) {
+		if dec, e := base64.StdEncoding.DecodeString(strings.TrimSpace(string(kubeBytes))); e == nil {
+			kubeBytes = dec
+		}
+	}
+	return kubeBytes, nil
+}
+
+// looksBase64 is a heuristic check if bytes look like base64 encoded data
+func looksBase64(b []byte) bool {
+	s := strings.TrimSpace(string(b))
+	if len(s) < 16 {
+		return false
+	}
+	// quick heuristic
+	for _, r := range s {
+		if (r >= 'A' && r <= 'Z') || (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '+' || r == '/' || r == '=' || r == '\n' {
+			continue
+		}
+		return false
+	}
+	return true
+}
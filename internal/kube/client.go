@@ -4,6 +4,10 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync/atomic"
+
+	"nfreconfig-mcp-server/internal/config"
 
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
@@ -11,14 +15,78 @@ import (
 	"k8s.io/client-go/util/homedir"
 )
 
-func DefaultKubeconfigPath() string {
-	if v := os.Getenv("KUBECONFIG"); v != "" {
-		return v
+// Server flags (-kubeconfig, -mgmt-context) override the config file.
+var flagOverrides atomic.Pointer[[2]string]
+
+// SetFlagOverrides records the -kubeconfig and -mgmt-context server flags. Empty
+// values leave the config file / environment in charge.
+func SetFlagOverrides(kubeconfig, mgmtContext string) {
+	flagOverrides.Store(&[2]string{strings.TrimSpace(kubeconfig), strings.TrimSpace(mgmtContext)})
+}
+
+func flagOverride(i int) string {
+	if o := flagOverrides.Load(); o != nil {
+		return o[i]
+	}
+	return ""
+}
+
+// KubeconfigPaths returns the kubeconfig files to merge, in precedence order:
+// -kubeconfig, then kube.kubeconfig from the server config, then $KUBECONFIG,
+// then ~/.kube/config. Each may be a list separated like $PATH (":" on Linux).
+func KubeconfigPaths() []string {
+	for _, v := range []string{flagOverride(0), config.Current().Kube.Kubeconfig, os.Getenv("KUBECONFIG")} {
+		if paths := splitPathList(v); len(paths) > 0 {
+			return paths
+		}
 	}
 	if home := homedir.HomeDir(); home != "" {
-		return filepath.Join(home, ".kube", "config")
+		return []string{filepath.Join(home, ".kube", "config")}
 	}
-	return ""
+	return nil
+}
+
+func splitPathList(v string) []string {
+	var out []string
+	for _, p := range filepath.SplitList(v) {
+		if p = strings.TrimSpace(p); p != "" {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// DefaultKubeconfigPath returns KubeconfigPaths joined with the list separator,
+// as it would appear in $KUBECONFIG.
+func DefaultKubeconfigPath() string {
+	return strings.Join(KubeconfigPaths(), string(filepath.ListSeparator))
+}
+
+// explicitKubeconfig reports whether a kubeconfig was given to the server (flag,
+// config file or $KUBECONFIG). It then takes precedence over in-cluster config.
+func explicitKubeconfig() bool {
+	return flagOverride(0) != "" || strings.TrimSpace(config.Current().Kube.Kubeconfig) != "" ||
+		strings.TrimSpace(os.Getenv("KUBECONFIG")) != ""
+}
+
+// LoadingRules merges KubeconfigPaths the way kubectl merges $KUBECONFIG: the
+// first file that sets a context, cluster, user or current-context wins, and
+// missing files are skipped.
+func LoadingRules() (*clientcmd.ClientConfigLoadingRules, error) {
+	paths := KubeconfigPaths()
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("cannot determine kubeconfig path (KUBECONFIG not set and no home dir)")
+	}
+	return &clientcmd.ClientConfigLoadingRules{Precedence: paths}, nil
+}
+
+// ManagementContext returns -mgmt-context, else kube.managementContext from the
+// server config; "" means the kubeconfig current-context (or in-cluster).
+func ManagementContext() string {
+	if c := flagOverride(1); c != "" {
+		return c
+	}
+	return strings.TrimSpace(config.Current().Kube.ManagementContext)
 }
 
 // IsInCluster checks if running inside a Kubernetes pod
@@ -28,8 +96,9 @@ func IsInCluster() bool {
 }
 
 func LoadRawConfig() (clientcmd.ClientConfig, api.Config, error) {
-	// Try in-cluster config first (when running inside a pod)
-	if IsInCluster() {
+	// Try in-cluster config first (when running inside a pod), unless the
+	// server was given a kubeconfig explicitly
+	if !explicitKubeconfig() && IsInCluster() {
 		// Build a synthetic config for in-cluster
 		restConfig, err := rest.InClusterConfig()
 		if err != nil {
@@ -62,13 +131,11 @@ func LoadRawConfig() (clientcmd.ClientConfig, api.Config, error) {
 		return cfg, rawCfg, nil
 	}
 
-	// Fall back to kubeconfig file
-	kubeconfig := DefaultKubeconfigPath()
-	if kubeconfig == "" {
-		return nil, api.Config{}, fmt.Errorf("cannot determine kubeconfig path (KUBECONFIG not set and no home dir)")
+	// Fall back to kubeconfig files
+	loadingRules, err := LoadingRules()
+	if err != nil {
+		return nil, api.Config{}, err
 	}
-
-	loadingRules := &clientcmd.ClientConfigLoadingRules{ExplicitPath: kubeconfig}
 	cfg := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, &clientcmd.ConfigOverrides{})
 
 	rawCfg, err := cfg.RawConfig()
@@ -1,8 +1,6 @@
 package kube
 
 import (
-	"fmt"
-
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
@@ -10,18 +8,21 @@ import (
 
 // DynamicClientForContext builds a dynamic client for a specific kubeconfig context name.
 func DynamicClientForContext(contextName string) (dynamic.Interface, *rest.Config, error) {
-	kubeconfig := DefaultKubeconfigPath()
-	if kubeconfig == "" {
-		return nil, nil, fmt.Errorf("cannot determine kubeconfig path")
+	loadingRules, err := LoadingRules()
+	if err != nil {
+		return nil, nil, err
 	}
 
+	if contextName == "" {
+		contextName = ManagementContext()
+	}
 	overrides := &clientcmd.ConfigOverrides{}
 	if contextName != "" {
 		overrides.CurrentContext = contextName
 	}
 
 	cfg := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
-		&clientcmd.ClientConfigLoadingRules{ExplicitPath: kubeconfig},
+		loadingRules,
 		overrides,
 	)
 
@@ -29,6 +30,8 @@ func DynamicClientForContext(contextName string) (dynamic.Interface, *rest.Confi
 	if err != nil {
 		return nil, nil, err
 	}
+	WithTracing(restCfg)
+	applyClientSettings(restCfg, currentClientSettings())
 
 	dc, err := dynamic.NewForConfig(restCfg)
 	if err != nil {
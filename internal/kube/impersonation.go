@@ -0,0 +1,65 @@
+package kube
+
+import (
+	"context"
+	"errors"
+
+	"nfreconfig-mcp-server/internal/config"
+	"nfreconfig-mcp-server/internal/identity"
+
+	"k8s.io/client-go/rest"
+)
+
+// ErrNoIdentity is returned for Kubernetes calls without a caller identity when
+// kube.impersonation.required is set.
+var ErrNoIdentity = errors.New("kubernetes impersonation is required but the call has no caller identity")
+
+// impersonationFor returns the identity Kubernetes requests made for ctx should
+// impersonate; ok is false when they run as the server itself.
+func impersonationFor(ctx context.Context) (id identity.Identity, ok bool, err error) {
+	imp := config.Current().Kube.Impersonation
+	if !imp.Enabled {
+		return identity.Identity{}, false, nil
+	}
+	id, ok = identity.From(ctx)
+	if !ok && imp.Required {
+		return identity.Identity{}, false, ErrNoIdentity
+	}
+	if ok {
+		// checked again here: the config may have changed since the
+		// identity was set
+		if err := identity.Check(id, imp); err != nil {
+			return identity.Identity{}, false, err
+		}
+	}
+	return id, ok, nil
+}
+
+// impersonated returns clients like base but acting as id, cached under baseKey.
+func (p *Pool) impersonated(baseKey string, base *Clients, id identity.Identity) (*Clients, error) {
+	key := baseKey + "|as|" + id.Key()
+	if e := p.lookup(key); e != nil {
+		return e.clients, nil
+	}
+	cfg := rest.CopyConfig(base.base)
+	cfg.Impersonate = rest.ImpersonationConfig{UserName: id.User, Groups: id.Groups}
+	cl, err := p.newClients(cfg)
+	if err != nil {
+		return nil, err
+	}
+	p.store(key, &poolEntry{clients: cl})
+	return cl, nil
+}
+
+// forCaller returns base, or its impersonating variant when ctx carries a caller
+// identity and impersonation is enabled.
+func (p *Pool) forCaller(ctx context.Context, baseKey string, base *Clients) (*Clients, error) {
+	id, ok, err := impersonationFor(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return base, nil
+	}
+	return p.impersonated(baseKey, base, id)
+}
@@ -1,8 +1,6 @@
 package kube
 
 import (
-	"fmt"
-
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
@@ -10,21 +8,27 @@ import (
 )
 
 // BuildRESTConfig returns a Kubernetes REST config.
-// Priority: in-cluster config if available; otherwise kubeconfig file.
-// If contextName == "", uses kubeconfig current-context or in-cluster config.
+// Priority: in-cluster config if available and no kubeconfig was configured;
+// otherwise the merged kubeconfig files (see KubeconfigPaths).
+// If contextName == "", uses the management context (ManagementContext), else
+// kubeconfig current-context or in-cluster config.
 // If contextName != "" and not "in-cluster", requires kubeconfig file.
 func BuildRESTConfig(contextName string) (*rest.Config, error) {
+	if contextName == "" {
+		contextName = ManagementContext()
+	}
 	// If no context specified or explicitly "in-cluster", try in-cluster first
-	if contextName == "" || contextName == "in-cluster" {
+	// (unless the server was given a kubeconfig explicitly)
+	if contextName == "in-cluster" || (contextName == "" && !explicitKubeconfig()) {
 		if cfg, err := rest.InClusterConfig(); err == nil {
-			return cfg, nil
+			return WithTracing(cfg), nil
 		}
 	}
 
 	// Fall back to local kubeconfig (or required for specific contexts)
-	kubeconfig := DefaultKubeconfigPath()
-	if kubeconfig == "" {
-		return nil, fmt.Errorf("cannot determine kubeconfig path (KUBECONFIG not set and no home dir)")
+	loadingRules, err := LoadingRules()
+	if err != nil {
+		return nil, err
 	}
 
 	overrides := &clientcmd.ConfigOverrides{}
@@ -32,9 +36,12 @@ func BuildRESTConfig(contextName string) (*rest.Config, error) {
 		overrides.CurrentContext = contextName
 	}
 
-	loadingRules := &clientcmd.ClientConfigLoadingRules{ExplicitPath: kubeconfig}
 	cc := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, overrides)
-	return cc.ClientConfig()
+	cfg, err := cc.ClientConfig()
+	if err != nil {
+		return nil, err
+	}
+	return WithTracing(cfg), nil
 }
 
 func BuildClientset(contextName string) (*kubernetes.Clientset, error) {
@@ -42,6 +49,7 @@ func BuildClientset(contextName string) (*kubernetes.Clientset, error) {
 	if err != nil {
 		return nil, err
 	}
+	applyClientSettings(cfg, currentClientSettings())
 	return kubernetes.NewForConfig(cfg)
 }
 
@@ -50,5 +58,6 @@ func BuildDynamicClient(contextName string) (dynamic.Interface, error) {
 	if err != nil {
 		return nil, err
 	}
+	applyClientSettings(cfg, currentClientSettings())
 	return dynamic.NewForConfig(cfg)
 }
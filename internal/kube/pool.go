@@ -0,0 +1,333 @@
+package kube
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"nfreconfig-mcp-server/internal/config"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/restmapper"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/util/flowcontrol"
+)
+
+// Clients bundles the clients for one cluster. They are safe for concurrent use
+// and shared between tool calls; do not modify Config.
+type Clients struct {
+	Config  *rest.Config
+	Dynamic dynamic.Interface
+	Typed   *kubernetes.Clientset
+	// Mapper resolves kinds lazily through a cached discovery client.
+	Mapper meta.RESTMapper
+
+	base *rest.Config // Config before the pool's limiter, timeouts and retries
+}
+
+// Pool caches Clients per management context, per workload cluster and per
+// kubeconfig. Clients built by the pool share one rate limiter per API server, so
+// parallel tool calls against the same cluster cannot exceed its QPS together.
+type Pool struct {
+	// TTL bounds how long any entry is reused.
+	TTL time.Duration
+	// RecheckInterval is how long a workload entry is trusted before the
+	// credential's version is read again to detect rotation.
+	RecheckInterval time.Duration
+	// QPS and Burst override kube.client.qps/burst for the shared
+	// per-API-server rate limiter; zero uses the server config.
+	QPS   float32
+	Burst int
+
+	mu       sync.Mutex
+	entries  map[string]*poolEntry
+	limiters map[string]flowcontrol.RateLimiter
+	reach    map[string]Reachability
+}
+
+type poolEntry struct {
+	clients *Clients
+	created time.Time
+	checked time.Time
+
+	// workload entries: where the credential came from
+	cred *ClusterCredential
+}
+
+const (
+	defaultPoolTTL = 10 * time.Minute
+	defaultRecheck = 30 * time.Second
+)
+
+var defaultPool = &Pool{
+	TTL:             defaultPoolTTL,
+	RecheckInterval: defaultRecheck,
+}
+
+// DefaultPool is the process-wide pool used by the tools.
+func DefaultPool() *Pool { return defaultPool }
+
+// Management returns clients for a management cluster context ("" = configured
+// management context, else current-context or in-cluster). With impersonation
+// enabled they act as the caller carried by ctx.
+func (p *Pool) Management(ctx context.Context, contextName string) (*Clients, error) {
+	key, cl, err := p.management(contextName)
+	if err != nil {
+		return nil, err
+	}
+	return p.forCaller(ctx, key, cl)
+}
+
+// ManagementAsServer is Management with the server's own identity. It is for
+// reading workload cluster credentials, which callers usually may not read.
+func (p *Pool) ManagementAsServer(contextName string) (*Clients, error) {
+	_, cl, err := p.management(contextName)
+	return cl, err
+}
+
+func (p *Pool) management(contextName string) (string, *Clients, error) {
+	if contextName == "" {
+		contextName = ManagementContext()
+	}
+	key := "mgmt|" + DefaultKubeconfigPath() + "|" + contextName
+	if c := p.lookup(key); c != nil {
+		return key, c.clients, nil
+	}
+	cfg, err := BuildRESTConfig(contextName)
+	if err != nil {
+		return "", nil, err
+	}
+	cl, err := p.newClients(cfg)
+	if err != nil {
+		return "", nil, err
+	}
+	p.store(key, &poolEntry{clients: cl})
+	return key, cl, nil
+}
+
+// FromKubeconfig returns clients for raw kubeconfig bytes, acting as the caller
+// carried by ctx when impersonation is enabled. Entries are keyed by content, so a
+// changed kubeconfig gets new clients.
+func (p *Pool) FromKubeconfig(ctx context.Context, kubeconfig []byte) (*Clients, error) {
+	if len(kubeconfig) == 0 {
+		return nil, fmt.Errorf("empty kubeconfig bytes")
+	}
+	sum := sha256.Sum256(kubeconfig)
+	key := "kubeconfig|" + hex.EncodeToString(sum[:])
+	if c := p.lookup(key); c != nil {
+		return p.forCaller(ctx, key, c.clients)
+	}
+	cl, err := p.clientsFromKubeconfig(kubeconfig)
+	if err != nil {
+		return nil, err
+	}
+	p.store(key, &poolEntry{clients: cl})
+	return p.forCaller(ctx, key, cl)
+}
+
+// Workload returns clients for a workload cluster ("name" or "namespace/name"),
+// using the first credential provider in kube.credentialProviders that knows it.
+// Credentials are looked up as the server; the clients act as the caller carried
+// by ctx when impersonation is enabled.
+// Within RecheckInterval the cached clients are returned without any API call;
+// after that only the credential's version (secret resourceVersion, file hash)
+// is read again, and the clients are rebuilt if it changed.
+func (p *Pool) Workload(ctx context.Context, mgmtContext, clusterRef string) (*Clients, error) {
+	cl, _, err := p.WorkloadCredential(ctx, mgmtContext, clusterRef)
+	return cl, err
+}
+
+// WorkloadCredential is Workload that also reports where the credential came from.
+func (p *Pool) WorkloadCredential(ctx context.Context, mgmtContext, clusterRef string) (*Clients, *ClusterCredential, error) {
+	if _, _, err := impersonationFor(ctx); err != nil {
+		return nil, nil, err
+	}
+	cl, cred, err := p.workload(ctx, mgmtContext, clusterRef)
+	if err != nil {
+		return nil, nil, err
+	}
+	// the credential version is part of the key, so rotation drops these too
+	cl, err = p.forCaller(ctx, "workload|"+cred.Provider+"|"+cred.Source+"|"+cred.Version, cl)
+	if err != nil {
+		return nil, nil, err
+	}
+	return cl, cred, nil
+}
+
+func (p *Pool) workload(ctx context.Context, mgmtContext, clusterRef string) (*Clients, *ClusterCredential, error) {
+	mgmtContext = strings.TrimSpace(mgmtContext)
+	cfg := config.Current()
+	if mgmtContext == "" {
+		mgmtContext = ManagementContext()
+	}
+	key := "workload|" + DefaultKubeconfigPath() + "|" + mgmtContext + "|" +
+		strings.Join(cfg.Kube.CredentialProviders, ",") + "|" + strings.TrimSpace(clusterRef)
+
+	mgmt, err := p.ManagementAsServer(mgmtContext)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	p.mu.Lock()
+	e := p.entries[key]
+	now := time.Now()
+	if e != nil && now.Sub(e.created) >= p.ttl() {
+		delete(p.entries, key)
+		e = nil
+	}
+	if e != nil && (e.cred.Recheck == nil || now.Sub(e.checked) < p.recheck()) {
+		p.mu.Unlock()
+		return e.clients, e.cred, nil
+	}
+	p.mu.Unlock()
+
+	if e != nil {
+		v, err := e.cred.Recheck(ctx)
+		if err != nil {
+			p.Invalidate(key)
+			return nil, nil, err
+		}
+		if v == e.cred.Version {
+			p.mu.Lock()
+			e.checked = time.Now()
+			p.mu.Unlock()
+			return e.clients, e.cred, nil
+		}
+	}
+
+	cred, err := LookupCredential(ctx, mgmt, ProvidersFromConfig(cfg), clusterRef)
+	if err != nil {
+		return nil, nil, err
+	}
+	cl, err := p.newClients(cred.Config)
+	if err != nil {
+		return nil, nil, err
+	}
+	p.store(key, &poolEntry{clients: cl, cred: cred})
+	return cl, cred, nil
+}
+
+// Invalidate drops one entry; Reset drops all of them.
+func (p *Pool) Invalidate(key string) {
+	p.mu.Lock()
+	delete(p.entries, key)
+	p.mu.Unlock()
+}
+
+func (p *Pool) Reset() {
+	p.mu.Lock()
+	p.entries = nil
+	p.mu.Unlock()
+}
+
+func (p *Pool) lookup(key string) *poolEntry {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	e := p.entries[key]
+	if e == nil {
+		return nil
+	}
+	if time.Since(e.created) >= p.ttl() {
+		delete(p.entries, key)
+		return nil
+	}
+	return e
+}
+
+// store adds an entry and drops the expired ones: keys of rotated
+// kubeconfigs and one-off callers are never looked up again.
+func (p *Pool) store(key string, e *poolEntry) {
+	now := time.Now()
+	e.created, e.checked = now, now
+	p.mu.Lock()
+	if p.entries == nil {
+		p.entries = map[string]*poolEntry{}
+	}
+	for k, old := range p.entries {
+		if now.Sub(old.created) >= p.ttl() {
+			delete(p.entries, k)
+		}
+	}
+	p.entries[key] = e
+	p.mu.Unlock()
+}
+
+func (p *Pool) ttl() time.Duration {
+	if p.TTL > 0 {
+		return p.TTL
+	}
+	return defaultPoolTTL
+}
+
+func (p *Pool) recheck() time.Duration {
+	if p.RecheckInterval > 0 {
+		return p.RecheckInterval
+	}
+	return defaultRecheck
+}
+
+func (p *Pool) clientsFromKubeconfig(kubeconfig []byte) (*Clients, error) {
+	cfg, err := clientcmd.RESTConfigFromKubeConfig(kubeconfig)
+	if err != nil {
+		return nil, fmt.Errorf("parse kubeconfig: %w", err)
+	}
+	return p.newClients(WithTracing(cfg))
+}
+
+// newClients builds clients from cfg with the pool's rate limiter and the
+// kube.client timeouts and retries. cfg itself is not modified.
+func (p *Pool) newClients(cfg *rest.Config) (*Clients, error) {
+	base := cfg
+	cfg = rest.CopyConfig(cfg)
+	cfg.RateLimiter = p.limiterFor(cfg.Host)
+	applyClientSettings(cfg, currentClientSettings())
+
+	dyn, err := dynamic.NewForConfig(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("dynamic client: %w", err)
+	}
+	cs, err := kubernetes.NewForConfig(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("clientset: %w", err)
+	}
+	return &Clients{
+		Config:  cfg,
+		Dynamic: dyn,
+		Typed:   cs,
+		Mapper:  restmapper.NewDeferredDiscoveryRESTMapper(memory.NewMemCacheClient(cs.Discovery())),
+		base:    base,
+	}, nil
+}
+
+// limiterFor returns the rate limiter shared by every client of one API server.
+// A changed qps/burst gets a new limiter for clients built from then on.
+func (p *Pool) limiterFor(host string) flowcontrol.RateLimiter {
+	qps, burst := p.QPS, p.Burst
+	if qps <= 0 {
+		qps = config.Current().Kube.Client.QPS
+	}
+	if burst <= 0 {
+		burst = config.Current().Kube.Client.Burst
+	}
+	key := fmt.Sprintf("%s|%g|%d", host, qps, burst)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if l, ok := p.limiters[key]; ok {
+		return l
+	}
+	if p.limiters == nil {
+		p.limiters = map[string]flowcontrol.RateLimiter{}
+	}
+	l := flowcontrol.NewTokenBucketRateLimiter(qps, burst)
+	p.limiters[key] = l
+	return l
+}
@@ -0,0 +1,24 @@
+package kube
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPoolStoreEvictsExpired(t *testing.T) {
+	p := &Pool{TTL: 10 * time.Millisecond}
+	p.store("kubeconfig|old", &poolEntry{})
+	p.store("kubeconfig|old|as|alice|", &poolEntry{})
+	time.Sleep(20 * time.Millisecond)
+	p.store("kubeconfig|new", &poolEntry{})
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.entries) != 1 || p.entries["kubeconfig|new"] == nil {
+		keys := make([]string, 0, len(p.entries))
+		for k := range p.entries {
+			keys = append(keys, k)
+		}
+		t.Errorf("entries after expiry = %v; want only kubeconfig|new", keys)
+	}
+}
@@ -0,0 +1,500 @@
+package kube
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+
+	"nfreconfig-mcp-server/internal/config"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	"sigs.k8s.io/yaml"
+)
+
+// ClusterCredential is how to reach one workload cluster.
+type ClusterCredential struct {
+	Provider  string
+	Name      string
+	Namespace string       // namespace of the object the credential came from, if any
+	Source    string       // e.g. "secret capi-system/edge-kubeconfig"
+	Config    *rest.Config // already wrapped with WithTracing
+
+	// Version identifies the credential's current content (a secret's
+	// resourceVersion, a file hash). Recheck returns the live version so cached
+	// clients can be rebuilt after rotation; nil means rely on the cache TTL.
+	Version string
+	Recheck func(ctx context.Context) (string, error)
+}
+
+// CredentialProvider finds the credential for a workload cluster reference
+// ("name" or "namespace/name"). A provider that does not know the cluster returns
+// an error for which apierrors.IsNotFound is true, so the next one is tried.
+type CredentialProvider interface {
+	Name() string
+	Lookup(ctx context.Context, mgmt *Clients, ref string) (*ClusterCredential, error)
+}
+
+const (
+	ProviderCAPI       = "capi"
+	ProviderArgoCD     = "argocd"
+	ProviderNephio     = "nephio"
+	ProviderKubeconfig = "kubeconfig"
+	ProviderRegistry   = "registry"
+)
+
+// ProvidersFromConfig builds the provider chain in the order of
+// kube.credentialProviders.
+func ProvidersFromConfig(c *config.Config) []CredentialProvider {
+	out := make([]CredentialProvider, 0, len(c.Kube.CredentialProviders))
+	for _, name := range c.Kube.CredentialProviders {
+		switch name {
+		case ProviderCAPI:
+			out = append(out, capiProvider{preferUser: c.Kube.PreferUserKubeconfig})
+		case ProviderArgoCD:
+			out = append(out, argoCDProvider{namespace: c.ArgoCD.Namespace})
+		case ProviderNephio:
+			out = append(out, nephioProvider{})
+		case ProviderKubeconfig:
+			out = append(out, kubeconfigContextProvider{})
+		case ProviderRegistry:
+			if c.Kube.ClusterRegistry != "" {
+				out = append(out, registryProvider{path: c.Kube.ClusterRegistry})
+			}
+		}
+	}
+	return out
+}
+
+// LookupCredential tries each provider in turn. Not-found answers fall through;
+// an ambiguous reference stops the search. When nobody knows the cluster the
+// result is the most severe failure (see lookupSeverity), NotFound only when
+// every provider answered NotFound; either way listing what was tried.
+func LookupCredential(ctx context.Context, mgmt *Clients, providers []CredentialProvider, ref string) (*ClusterCredential, error) {
+	var tried, failures []string
+	var worst error
+	for _, p := range providers {
+		cred, err := p.Lookup(ctx, mgmt, ref)
+		if err == nil {
+			cred.Provider = p.Name()
+			return cred, nil
+		}
+		var amb *AmbiguousClusterError
+		if errors.As(err, &amb) {
+			return nil, err
+		}
+		tried = append(tried, p.Name())
+		if !apierrors.IsNotFound(err) {
+			failures = append(failures, p.Name()+": "+err.Error())
+			if worst == nil || lookupSeverity(err) > lookupSeverity(worst) {
+				worst = err
+			}
+		}
+	}
+	if worst != nil {
+		return nil, &LookupError{
+			Message: fmt.Sprintf("no credential provider could look up cluster %q (tried %s); errors: %s", ref, strings.Join(tried, ", "), strings.Join(failures, "; ")),
+			Err:     worst,
+		}
+	}
+	return nil, &apierrors.StatusError{ErrStatus: metav1.Status{
+		Status:  metav1.StatusFailure,
+		Code:    404,
+		Reason:  metav1.StatusReasonNotFound,
+		Message: fmt.Sprintf("no credential provider knows cluster %q (tried %s)", ref, strings.Join(tried, ", ")),
+	}}
+}
+
+// LookupError is a failed credential lookup. It unwraps to the most severe
+// provider failure, so it is classified (Forbidden, Unavailable) by that.
+type LookupError struct {
+	Message string
+	Err     error
+}
+
+func (e *LookupError) Error() string { return e.Message }
+func (e *LookupError) Unwrap() error { return e.Err }
+
+// lookupSeverity ranks a provider failure: a credential the server may not
+// read outranks an unreachable or overloaded API server, which outranks any
+// other error; NotFound ranks lowest.
+func lookupSeverity(err error) int {
+	var ne net.Error
+	switch {
+	case apierrors.IsNotFound(err):
+		return 0
+	case apierrors.IsForbidden(err), apierrors.IsUnauthorized(err):
+		return 3
+	case apierrors.IsServiceUnavailable(err), apierrors.IsTooManyRequests(err), apierrors.IsTimeout(err),
+		apierrors.IsServerTimeout(err), apierrors.IsInternalError(err), apierrors.IsUnexpectedServerError(err),
+		errors.Is(err, context.DeadlineExceeded), errors.As(err, &ne):
+		return 2
+	}
+	return 1
+}
+
+func notFound(what, ref string) error {
+	return apierrors.NewNotFound(schema.GroupResource{Resource: what}, ref)
+}
+
+// secretRecheck returns the live resourceVersion of a secret.
+func secretRecheck(mgmt *Clients, ns, name string) func(context.Context) (string, error) {
+	return func(ctx context.Context) (string, error) {
+		sec, err := mgmt.Typed.CoreV1().Secrets(ns).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return "", err
+		}
+		return sec.ResourceVersion, nil
+	}
+}
+
+func restConfigFromKubeconfig(kubeconfig []byte, contextName string) (*rest.Config, error) {
+	if contextName == "" {
+		cfg, err := clientcmd.RESTConfigFromKubeConfig(kubeconfig)
+		if err != nil {
+			return nil, fmt.Errorf("parse kubeconfig: %w", err)
+		}
+		return WithTracing(cfg), nil
+	}
+	raw, err := clientcmd.Load(kubeconfig)
+	if err != nil {
+		return nil, fmt.Errorf("parse kubeconfig: %w", err)
+	}
+	cfg, err := clientcmd.NewNonInteractiveClientConfig(*raw, contextName, &clientcmd.ConfigOverrides{}, nil).ClientConfig()
+	if err != nil {
+		return nil, fmt.Errorf("kubeconfig context %q: %w", contextName, err)
+	}
+	return WithTracing(cfg), nil
+}
+
+// ---------------- CAPI ----------------
+
+type capiProvider struct{ preferUser bool }
+
+func (capiProvider) Name() string { return ProviderCAPI }
+
+func (p capiProvider) Lookup(ctx context.Context, mgmt *Clients, ref string) (*ClusterCredential, error) {
+	r := NewWorkloadClusterResolver(mgmt)
+	r.PreferUserKubeconfig = p.preferUser
+	wc, err := r.Resolve(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := restConfigFromKubeconfig(wc.Kubeconfig, "")
+	if err != nil {
+		return nil, err
+	}
+	return &ClusterCredential{
+		Name:      wc.Name,
+		Namespace: wc.Namespace,
+		Source:    "secret " + wc.Namespace + "/" + wc.Secret.Name,
+		Config:    cfg,
+		Version:   wc.Secret.ResourceVersion,
+		Recheck:   secretRecheck(mgmt, wc.Namespace, wc.Secret.Name),
+	}, nil
+}
+
+// ---------------- ArgoCD cluster secrets ----------------
+
+// argoCDProvider reads ArgoCD declarative cluster secrets
+// (label argocd.argoproj.io/secret-type=cluster) on the management cluster.
+type argoCDProvider struct{ namespace string }
+
+func (argoCDProvider) Name() string { return ProviderArgoCD }
+
+// argoClusterConfig is the "config" key of an ArgoCD cluster secret.
+type argoClusterConfig struct {
+	Username        string `json:"username,omitempty"`
+	Password        string `json:"password,omitempty"`
+	BearerToken     string `json:"bearerToken,omitempty"`
+	TLSClientConfig struct {
+		Insecure   bool   `json:"insecure,omitempty"`
+		ServerName string `json:"serverName,omitempty"`
+		CAData     []byte `json:"caData,omitempty"`
+		CertData   []byte `json:"certData,omitempty"`
+		KeyData    []byte `json:"keyData,omitempty"`
+	} `json:"tlsClientConfig"`
+	ExecProviderConfig *json.RawMessage `json:"execProviderConfig,omitempty"`
+	AWSAuthConfig      *json.RawMessage `json:"awsAuthConfig,omitempty"`
+}
+
+func (p argoCDProvider) Lookup(ctx context.Context, mgmt *Clients, ref string) (*ClusterCredential, error) {
+	ns, name := SplitClusterRef(ref)
+	if ns == "" {
+		ns = p.namespace
+	}
+	list, err := mgmt.Typed.CoreV1().Secrets(ns).List(ctx, metav1.ListOptions{
+		LabelSelector: "argocd.argoproj.io/secret-type=cluster",
+	})
+	if err != nil {
+		return nil, fmt.Errorf("list ArgoCD cluster secrets in %s: %w", ns, err)
+	}
+	for i := range list.Items {
+		sec := &list.Items[i]
+		if string(sec.Data["name"]) != name && sec.Name != name {
+			continue
+		}
+		cfg, err := argoRESTConfig(sec)
+		if err != nil {
+			return nil, fmt.Errorf("ArgoCD cluster secret %s/%s: %w", sec.Namespace, sec.Name, err)
+		}
+		return &ClusterCredential{
+			Name:      name,
+			Namespace: sec.Namespace,
+			Source:    "secret " + sec.Namespace + "/" + sec.Name,
+			Config:    cfg,
+			Version:   sec.ResourceVersion,
+			Recheck:   secretRecheck(mgmt, sec.Namespace, sec.Name),
+		}, nil
+	}
+	return nil, notFound("argocd cluster secrets", ref)
+}
+
+func argoRESTConfig(sec *corev1.Secret) (*rest.Config, error) {
+	server := strings.TrimSpace(string(sec.Data["server"]))
+	if server == "" {
+		return nil, fmt.Errorf("missing data.server")
+	}
+	var ac argoClusterConfig
+	if b := sec.Data["config"]; len(b) > 0 {
+		if err := json.Unmarshal(b, &ac); err != nil {
+			return nil, fmt.Errorf("parse data.config: %w", err)
+		}
+	}
+	if ac.BearerToken == "" && ac.Username == "" && len(ac.TLSClientConfig.CertData) == 0 {
+		if ac.ExecProviderConfig != nil || ac.AWSAuthConfig != nil {
+			return nil, fmt.Errorf("exec/AWS auth is not supported; use a bearer token or client certificate")
+		}
+		if strings.HasPrefix(server, "https://kubernetes.default.svc") {
+			// ArgoCD's own cluster: the server runs there too
+			cfg, err := rest.InClusterConfig()
+			if err != nil {
+				return nil, err
+			}
+			return WithTracing(cfg), nil
+		}
+	}
+	return WithTracing(&rest.Config{
+		Host:        server,
+		Username:    ac.Username,
+		Password:    ac.Password,
+		BearerToken: ac.BearerToken,
+		TLSClientConfig: rest.TLSClientConfig{
+			Insecure:   ac.TLSClientConfig.Insecure,
+			ServerName: ac.TLSClientConfig.ServerName,
+			CAData:     ac.TLSClientConfig.CAData,
+			CertData:   ac.TLSClientConfig.CertData,
+			KeyData:    ac.TLSClientConfig.KeyData,
+		},
+	}), nil
+}
+
+// ---------------- Nephio WorkloadCluster ----------------
+
+// KubeconfigSecretAnnotation on a Nephio WorkloadCluster names the kubeconfig
+// secret ("namespace/name" or "name") when it is not <clusterName>-kubeconfig.
+const KubeconfigSecretAnnotation = "nfreconfig-mcp-server/kubeconfig-secret"
+
+var nephioWorkloadClusterGK = schema.GroupKind{Group: "infra.nephio.org", Kind: "WorkloadCluster"}
+
+// nephioProvider matches Nephio WorkloadCluster resources by name or
+// spec.clusterName and reads the kubeconfig secret next to them. This covers
+// clusters imported into Nephio without CAPI.
+type nephioProvider struct{}
+
+func (nephioProvider) Name() string { return ProviderNephio }
+
+func (nephioProvider) Lookup(ctx context.Context, mgmt *Clients, ref string) (*ClusterCredential, error) {
+	m, err := mgmt.Mapper.RESTMapping(nephioWorkloadClusterGK)
+	if err != nil {
+		if meta.IsNoMatchError(err) {
+			return nil, notFound("workloadclusters.infra.nephio.org", ref)
+		}
+		return nil, err
+	}
+	ns, name := SplitClusterRef(ref)
+	ul, err := mgmt.Dynamic.Resource(m.Resource).Namespace(ns).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("list Nephio WorkloadClusters: %w", err)
+	}
+
+	var matches []int
+	for i, it := range ul.Items {
+		clusterName, _, _ := unstructuredString(it.Object, "spec", "clusterName")
+		if it.GetName() == name || clusterName == name {
+			matches = append(matches, i)
+		}
+	}
+	switch {
+	case len(matches) == 0:
+		return nil, notFound("workloadclusters.infra.nephio.org", ref)
+	case len(matches) > 1:
+		amb := &AmbiguousClusterError{Name: name}
+		for _, i := range matches {
+			amb.Namespaces = append(amb.Namespaces, ul.Items[i].GetNamespace())
+		}
+		return nil, amb
+	}
+	wc := ul.Items[matches[0]]
+
+	clusterName, _, _ := unstructuredString(wc.Object, "spec", "clusterName")
+	if clusterName == "" {
+		clusterName = wc.GetName()
+	}
+	secNS, secName := wc.GetNamespace(), clusterName+"-kubeconfig"
+	if a := strings.TrimSpace(wc.GetAnnotations()[KubeconfigSecretAnnotation]); a != "" {
+		if n, s := SplitClusterRef(a); n != "" {
+			secNS, secName = n, s
+		} else {
+			secName = s
+		}
+	}
+	sec, err := mgmt.Typed.CoreV1().Secrets(secNS).Get(ctx, secName, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("WorkloadCluster %s/%s: get kubeconfig secret %s/%s: %w", wc.GetNamespace(), wc.GetName(), secNS, secName, err)
+	}
+	kubeBytes, err := KubeconfigFromSecret(sec)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := restConfigFromKubeconfig(kubeBytes, "")
+	if err != nil {
+		return nil, err
+	}
+	return &ClusterCredential{
+		Name:      clusterName,
+		Namespace: wc.GetNamespace(),
+		Source:    "secret " + secNS + "/" + secName,
+		Config:    cfg,
+		Version:   sec.ResourceVersion,
+		Recheck:   secretRecheck(mgmt, secNS, secName),
+	}, nil
+}
+
+func unstructuredString(obj map[string]any, fields ...string) (string, bool, error) {
+	cur := any(obj)
+	for _, f := range fields {
+		m, ok := cur.(map[string]any)
+		if !ok {
+			return "", false, nil
+		}
+		if cur, ok = m[f]; !ok {
+			return "", false, nil
+		}
+	}
+	s, ok := cur.(string)
+	return s, ok, nil
+}
+
+// ---------------- kubeconfig contexts ----------------
+
+// kubeconfigContextProvider treats a context of the server's kubeconfig with
+// the cluster's name as its credential.
+type kubeconfigContextProvider struct{}
+
+func (kubeconfigContextProvider) Name() string { return ProviderKubeconfig }
+
+func (kubeconfigContextProvider) Lookup(ctx context.Context, mgmt *Clients, ref string) (*ClusterCredential, error) {
+	_, name := SplitClusterRef(ref)
+	_, raw, err := LoadRawConfig()
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := raw.Contexts[name]; !ok {
+		return nil, notFound("kubeconfig contexts", name)
+	}
+	cfg, err := BuildRESTConfig(name)
+	if err != nil {
+		return nil, err
+	}
+	return &ClusterCredential{Name: name, Source: "kubeconfig context " + name, Config: cfg}, nil
+}
+
+// ---------------- static registry ----------------
+
+// ClusterRegistry is the static registry file (kube.clusterRegistry):
+//
+//	clusters:
+//	  - name: standby
+//	    kubeconfig: /etc/nfreconfig/standby.kubeconfig
+//	    context: standby-admin   # optional, default current-context
+type ClusterRegistry struct {
+	Clusters []RegistryEntry `json:"clusters"`
+}
+
+type RegistryEntry struct {
+	Name       string `json:"name"`
+	Kubeconfig string `json:"kubeconfig"`
+	Context    string `json:"context,omitempty"`
+}
+
+type registryProvider struct{ path string }
+
+func (registryProvider) Name() string { return ProviderRegistry }
+
+func (p registryProvider) Lookup(ctx context.Context, mgmt *Clients, ref string) (*ClusterCredential, error) {
+	_, name := SplitClusterRef(ref)
+	e, err := p.find(name)
+	if err != nil {
+		return nil, err
+	}
+	kubeBytes, err := os.ReadFile(e.Kubeconfig)
+	if err != nil {
+		return nil, fmt.Errorf("registry %s: cluster %q: %w", p.path, name, err)
+	}
+	cfg, err := restConfigFromKubeconfig(kubeBytes, e.Context)
+	if err != nil {
+		return nil, fmt.Errorf("registry %s: cluster %q: %w", p.path, name, err)
+	}
+	return &ClusterCredential{
+		Name:    name,
+		Source:  "registry " + p.path,
+		Config:  cfg,
+		Version: p.version(e),
+		Recheck: func(context.Context) (string, error) {
+			e, err := p.find(name)
+			if err != nil {
+				return "", err
+			}
+			return p.version(e), nil
+		},
+	}, nil
+}
+
+func (p registryProvider) find(name string) (RegistryEntry, error) {
+	data, err := os.ReadFile(p.path)
+	if err != nil {
+		return RegistryEntry{}, fmt.Errorf("read cluster registry: %w", err)
+	}
+	var reg ClusterRegistry
+	if err := yaml.UnmarshalStrict(data, &reg); err != nil {
+		return RegistryEntry{}, fmt.Errorf("parse cluster registry %s: %w", p.path, err)
+	}
+	for _, e := range reg.Clusters {
+		if e.Name == name {
+			return e, nil
+		}
+	}
+	return RegistryEntry{}, notFound("cluster registry", name)
+}
+
+// version hashes the entry and the kubeconfig it points to.
+func (p registryProvider) version(e RegistryEntry) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00%s\x00", e.Kubeconfig, e.Context)
+	if b, err := os.ReadFile(e.Kubeconfig); err == nil {
+		h.Write(b)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,58 @@
+package kube
+
+import (
+	"net/http"
+
+	"nfreconfig-mcp-server/internal/telemetry"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"k8s.io/client-go/rest"
+)
+
+// WithTracing wraps cfg's transport so every API request becomes a child span of
+// the caller's context. It returns cfg for chaining.
+func WithTracing(cfg *rest.Config) *rest.Config {
+	if cfg == nil {
+		return nil
+	}
+	host := cfg.Host
+	cfg.Wrap(func(rt http.RoundTripper) http.RoundTripper {
+		return &tracingRoundTripper{next: rt, host: host}
+	})
+	return cfg
+}
+
+type tracingRoundTripper struct {
+	next http.RoundTripper
+	host string
+}
+
+func (t *tracingRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx, span := telemetry.Tracer().Start(req.Context(), "k8s "+req.Method,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("http.request.method", req.Method),
+			attribute.String("url.path", req.URL.Path),
+			attribute.String("server.address", t.host),
+		),
+	)
+	defer span.End()
+
+	req = req.Clone(ctx)
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		telemetry.RecordError(span, err)
+		return nil, err
+	}
+	span.SetAttributes(attribute.Int("http.response.status_code", resp.StatusCode))
+	if resp.StatusCode >= 500 {
+		span.SetStatus(codes.Error, resp.Status)
+	}
+	return resp, nil
+}
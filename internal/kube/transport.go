@@ -0,0 +1,203 @@
+package kube
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+
+	"nfreconfig-mcp-server/internal/config"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/rest"
+)
+
+// clientSettings are the kube.client values in effect when clients are built.
+type clientSettings struct {
+	requestTimeout time.Duration
+	dialTimeout    time.Duration
+	retries        int
+	backoff        time.Duration
+}
+
+func currentClientSettings() clientSettings {
+	c := config.Current().Kube.Client
+	return clientSettings{
+		requestTimeout: config.Duration(c.RequestTimeout, 30*time.Second),
+		dialTimeout:    config.Duration(c.DialTimeout, 5*time.Second),
+		retries:        c.Retries,
+		backoff:        config.Duration(c.RetryBackoff, 250*time.Millisecond),
+	}
+}
+
+// applyClientSettings sets the request timeout, a dialer with a connect timeout
+// and the retrying transport on cfg. Call it once per config: it wraps the
+// transport.
+func applyClientSettings(cfg *rest.Config, s clientSettings) {
+	cfg.Timeout = s.requestTimeout
+	cfg.Dial = (&net.Dialer{Timeout: s.dialTimeout, KeepAlive: 30 * time.Second}).DialContext
+	if s.retries > 0 {
+		cfg.Wrap(func(rt http.RoundTripper) http.RoundTripper {
+			return &retryRoundTripper{next: rt, retries: s.retries, backoff: s.backoff}
+		})
+	}
+}
+
+// maxRetryDelay caps the exponential backoff and a server's Retry-After.
+const maxRetryDelay = 10 * time.Second
+
+// retryRoundTripper retries transient failures with exponential backoff and
+// jitter: 429 and 5xx responses and refused/reset connections. Requests that may
+// have reached the server (5xx on a write) are retried only when idempotent.
+type retryRoundTripper struct {
+	next    http.RoundTripper
+	retries int
+	backoff time.Duration
+}
+
+type noRetryKey struct{}
+
+// withoutRetries marks ctx so its requests are sent once (reachability probes).
+func withoutRetries(ctx context.Context) context.Context {
+	return context.WithValue(ctx, noRetryKey{}, true)
+}
+
+func (t *retryRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	if ctx.Value(noRetryKey{}) != nil || (req.Body != nil && req.Body != http.NoBody && req.GetBody == nil) {
+		return t.next.RoundTrip(req)
+	}
+	delay := t.backoff
+	for attempt := 0; ; attempt++ {
+		r := req
+		if attempt > 0 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			r = req.Clone(ctx)
+			r.Body = body
+		}
+		resp, err := t.next.RoundTrip(r)
+		if attempt >= t.retries || !retryable(req.Method, resp, err) {
+			return resp, err
+		}
+
+		wait := delay + time.Duration(rand.Int63n(int64(delay)/2+1))
+		if resp != nil {
+			if ra := retryAfter(resp); ra > 0 {
+				wait = ra
+			}
+			resp.Body.Close()
+		}
+		if wait > maxRetryDelay {
+			wait = maxRetryDelay
+		}
+		select {
+		case <-ctx.Done():
+			if err == nil {
+				err = fmt.Errorf("%s %s: giving up after %d attempt(s): %w", req.Method, req.URL.Path, attempt+1, ctx.Err())
+			}
+			return nil, err
+		case <-time.After(wait):
+		}
+		delay *= 2
+	}
+}
+
+func retryable(method string, resp *http.Response, err error) bool {
+	if err != nil {
+		// refused: the request never reached the server; reset: it may have
+		return errors.Is(err, syscall.ECONNREFUSED) || (errors.Is(err, syscall.ECONNRESET) && idempotent(method))
+	}
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests:
+		return true
+	case resp.StatusCode == http.StatusServiceUnavailable:
+		return true // not processed (overloaded, or apiserver shutting down)
+	case resp.StatusCode >= 500:
+		return idempotent(method)
+	}
+	return false
+}
+
+func idempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	return false
+}
+
+func retryAfter(resp *http.Response) time.Duration {
+	if s, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && s > 0 {
+		return time.Duration(s) * time.Second
+	}
+	return 0
+}
+
+// ---------------- reachability ----------------
+
+// Reachability is the result of probing an API server's /version.
+type Reachability struct {
+	Reachable bool      `json:"reachable"`
+	APIServer string    `json:"apiServer,omitempty"`
+	Version   string    `json:"version,omitempty"`
+	LatencyMs int64     `json:"latencyMs"`
+	Error     string    `json:"error,omitempty"`
+	CheckedAt time.Time `json:"checkedAt"`
+	FromCache bool      `json:"fromCache,omitempty"`
+}
+
+// reachabilityTTL is how long a probe result is reused, so a dead cluster costs
+// one dial timeout per window instead of one per tool call.
+const reachabilityTTL = 15 * time.Second
+
+// Probe checks that cl's API server answers, without retries and within twice
+// the dial timeout. Results are cached per API server for reachabilityTTL.
+func (p *Pool) Probe(ctx context.Context, cl *Clients) Reachability {
+	host := cl.Config.Host
+	p.mu.Lock()
+	if r, ok := p.reach[host]; ok && time.Since(r.CheckedAt) < reachabilityTTL {
+		p.mu.Unlock()
+		r.FromCache = true
+		return r
+	}
+	p.mu.Unlock()
+
+	probeCtx, cancel := context.WithTimeout(withoutRetries(ctx), 2*currentClientSettings().dialTimeout)
+	defer cancel()
+	start := time.Now()
+	r := Reachability{APIServer: host, CheckedAt: start}
+	body, err := cl.Typed.Discovery().RESTClient().Get().AbsPath("/version").DoRaw(probeCtx)
+	r.LatencyMs = time.Since(start).Milliseconds()
+	if err != nil {
+		r.Error = err.Error()
+		if ctx.Err() != nil {
+			return r // the caller went away; says nothing about the cluster
+		}
+		// an HTTP error status (401, 403, ...) still means the server answered
+		var st apierrors.APIStatus
+		r.Reachable = errors.As(err, &st)
+	} else {
+		var info struct {
+			GitVersion string `json:"gitVersion"`
+		}
+		_ = json.Unmarshal(body, &info)
+		r.Reachable, r.Version = true, info.GitVersion
+	}
+
+	p.mu.Lock()
+	if p.reach == nil {
+		p.reach = map[string]Reachability{}
+	}
+	p.reach[host] = r
+	p.mu.Unlock()
+	return r
+}
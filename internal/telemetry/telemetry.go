@@ -0,0 +1,146 @@
+package telemetry
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "nfreconfig-mcp-server"
+
+// Options selects where spans are exported.
+type Options struct {
+	// Exporter is "otlp", "file" or "none".
+	// Empty means "otlp" when OTEL_EXPORTER_OTLP_ENDPOINT (or the traces variant) is set, otherwise "none".
+	Exporter string
+	// File is the output path for the "file" exporter (one JSON span per line).
+	File string
+
+	ServiceName    string
+	ServiceVersion string
+}
+
+// Setup installs the global tracer provider and W3C trace-context propagator.
+// The returned function flushes and stops the exporter; it is always non-nil.
+func Setup(ctx context.Context, opts Options) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	noop := func(context.Context) error { return nil }
+
+	exporter := strings.ToLower(strings.TrimSpace(opts.Exporter))
+	if exporter == "" {
+		if os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") != "" || os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") != "" {
+			exporter = "otlp"
+		} else {
+			exporter = "none"
+		}
+	}
+
+	var (
+		sp      sdktrace.SpanExporter
+		closeFn func() error
+		err     error
+	)
+	switch exporter {
+	case "none":
+		return noop, nil
+	case "otlp":
+		// Endpoint, headers and TLS come from the standard OTEL_EXPORTER_OTLP_* variables.
+		sp, err = otlptracehttp.New(ctx)
+		if err != nil {
+			return noop, fmt.Errorf("create otlp exporter: %w", err)
+		}
+	case "file":
+		path := strings.TrimSpace(opts.File)
+		if path == "" {
+			return noop, fmt.Errorf("trace exporter %q requires a file path", exporter)
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return noop, fmt.Errorf("create trace dir: %w", err)
+		}
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return noop, fmt.Errorf("open trace file %q: %w", path, err)
+		}
+		sp, err = stdouttrace.New(stdouttrace.WithWriter(f))
+		if err != nil {
+			_ = f.Close()
+			return noop, fmt.Errorf("create file exporter: %w", err)
+		}
+		closeFn = f.Close
+	default:
+		return noop, fmt.Errorf("unsupported trace exporter %q (want otlp, file or none)", opts.Exporter)
+	}
+
+	name := opts.ServiceName
+	if name == "" {
+		name = instrumentationName
+	}
+	res := resource.NewSchemaless(
+		attribute.String("service.name", name),
+		attribute.String("service.version", opts.ServiceVersion),
+	)
+
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(sp),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(tp)
+
+	return func(ctx context.Context) error {
+		err := tp.Shutdown(ctx)
+		if closeFn != nil {
+			if cerr := closeFn(); err == nil {
+				err = cerr
+			}
+		}
+		return err
+	}, nil
+}
+
+// Tracer returns the server-wide tracer. It is a no-op until Setup installs an exporter.
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// ExtractFromMeta continues a trace carried in MCP request metadata
+// (the "traceparent", "tracestate" and "baggage" keys of params._meta).
+func ExtractFromMeta(ctx context.Context, meta map[string]any) context.Context {
+	if len(meta) == 0 {
+		return ctx
+	}
+	carrier := propagation.MapCarrier{}
+	for _, k := range []string{"traceparent", "tracestate", "baggage"} {
+		if s, ok := meta[k].(string); ok && s != "" {
+			carrier[k] = s
+		}
+	}
+	if len(carrier) == 0 {
+		return ctx
+	}
+	return otel.GetTextMapPropagator().Extract(ctx, carrier)
+}
+
+// RecordError marks span as failed with err (nil is ignored).
+func RecordError(span trace.Span, err error) {
+	if err == nil {
+		return
+	}
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
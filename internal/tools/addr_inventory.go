@@ -0,0 +1,480 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/netip"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"nfreconfig-mcp-server/internal/config"
+	"nfreconfig-mcp-server/internal/kube"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// Roles of an address in AddrUse.
+const (
+	roleAddress   = "address"   // an interface's own address
+	roleGateway   = "gateway"   // a gateway configured on an interface or NAD
+	roleReference = "reference" // an address mentioned in NF configuration (a peer, a server)
+)
+
+// AddrUse is one place an address is used.
+type AddrUse struct {
+	Address   string `json:"address"`
+	CIDR      string `json:"cidr,omitempty"` // the address with its prefix length, when given as a CIDR
+	Role      string `json:"role"`           // address, gateway or reference
+	Interface string `json:"interface,omitempty"`
+	Network   string `json:"network,omitempty"` // NAD a live pod address is on
+	NF        string `json:"nf,omitempty"`      // NFDeployment owning the address, when known
+	Cluster   string `json:"cluster,omitempty"`
+	Repo      string `json:"repo,omitempty"`
+	File      string `json:"file,omitempty"`
+	Object    string `json:"object"` // Kind/namespace/name
+	// Live is true for addresses read from a pod's network-status
+	Live bool `json:"live,omitempty"`
+
+	subject bool // being validated, not only part of the fleet
+}
+
+// where is the place of u without its address, for messages.
+func (u AddrUse) where() string {
+	switch {
+	case u.File != "":
+		return u.Repo + ":" + u.File + " " + u.Object
+	case u.Cluster != "":
+		return u.Cluster + " " + u.Object
+	}
+	return u.Object
+}
+
+// inventoryNAD is a NAD network and where it was read.
+type inventoryNAD struct {
+	loc   AddrUse // Address is empty
+	iface NetworkInterface
+}
+
+// inventoryNF is an NFDeployment and the interfaces it declares.
+type inventoryNF struct {
+	loc        AddrUse // NF is its name
+	provider   string  // spec.provider, e.g. cucp.openairinterface.org
+	interfaces []string
+}
+
+// reservedCIDR is a cluster's pod or service CIDR.
+type reservedCIDR struct {
+	cluster string
+	kind    string // pod or service
+	prefix  netip.Prefix
+}
+
+// addrInventory collects who uses which address across clusters and repos, the
+// NAD networks and the pod and service CIDRs, for planning and validating
+// addresses.
+type addrInventory struct {
+	uses     []AddrUse
+	nads     []inventoryNAD
+	nfs      []inventoryNF
+	reserved []reservedCIDR
+}
+
+// inventoryKinds are the repo kinds whose addresses count as used. ConfigMaps
+// are read from repos only: NF configuration files often live in them.
+var inventoryKinds = map[string]bool{
+	"NFDeployment":                true,
+	"NetworkAttachmentDefinition": true,
+	"NFConfig":                    true,
+	"Config":                      true,
+	"IPClaim":                     true,
+	"ConfigMap":                   true,
+}
+
+// addRepo reads the inventory kinds from a cloned repo.
+func (inv *addrInventory) addRepo(r RepoWorkdir, report func(string, error)) {
+	objs := readManifestObjects(r, func(u *unstructured.Unstructured) bool { return inventoryKinds[u.GetKind()] }, report)
+	for _, o := range objs {
+		inv.addObject(AddrUse{Repo: o.repo, File: o.file, Object: objectRef(o.u)}, o.u.Object)
+	}
+}
+
+// addCluster reads NADs, NFDeployments, NFConfigs, the live pod addresses and
+// the pod and service CIDRs of a cluster.
+func (inv *addrInventory) addCluster(ctx context.Context, name string, c *kube.Clients, namespace string, report func(string, error)) {
+	list := func(source, kind string) []unstructured.Unstructured {
+		ul, err := c.Dynamic.Resource(kindMap[kind].GVR).Namespace(namespace).List(ctx, metav1.ListOptions{})
+		if err != nil {
+			report(source, err)
+			return nil
+		}
+		return ul.Items
+	}
+	nads := list(sourceNADs, "NetworkAttachmentDefinition")
+	nfds := list(sourceNFDeployments, "NFDeployment")
+	nfcs := list(sourceNFConfigs, "NFConfig")
+	for _, items := range [][]unstructured.Unstructured{nads, nfds, nfcs} {
+		for i := range items {
+			inv.addObject(AddrUse{Cluster: name, Object: objectRef(&items[i])}, items[i].Object)
+		}
+	}
+
+	if pl, err := c.Typed.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{}); err != nil {
+		report(sourcePods, err)
+	} else {
+		pods, _ := liveNetworks(pl.Items, nfds, report)
+		for _, p := range pods {
+			for _, pi := range p.Interfaces {
+				if pi.Default {
+					continue
+				}
+				iface := pi.Interface
+				if iface == "" {
+					iface = pi.Network
+				}
+				for _, ip := range pi.IPs {
+					inv.uses = append(inv.uses, AddrUse{Address: ip, Role: roleAddress, Interface: iface, Network: pi.Network, NF: p.NF, Cluster: name, Object: "Pod/" + p.Namespace + "/" + p.Name, Live: true})
+				}
+			}
+		}
+	}
+
+	cidrs := getClusterCIDRs(ctx, c.Dynamic, c.Typed, report)
+	for kind, list := range map[string][]string{"pod": cidrs.pod, "service": cidrs.service} {
+		for _, s := range list {
+			if p, err := netip.ParsePrefix(s); err == nil {
+				inv.reserved = append(inv.reserved, reservedCIDR{cluster: name, kind: kind, prefix: p.Masked()})
+			}
+		}
+	}
+}
+
+func objectRef(u *unstructured.Unstructured) string {
+	if ns := u.GetNamespace(); ns != "" {
+		return u.GetKind() + "/" + ns + "/" + u.GetName()
+	}
+	return u.GetKind() + "/" + u.GetName()
+}
+
+// addObject records the addresses of one object. NADs go through the typed CNI
+// config; elsewhere an address belongs to the nearest enclosing map with a name
+// (an NFDeployment interface), and only NFDeployment and IPClaim addresses are
+// an interface's own, the rest are references.
+func (inv *addrInventory) addObject(loc AddrUse, obj map[string]any) {
+	kind, _ := obj["kind"].(string)
+	if kind == "NetworkAttachmentDefinition" {
+		if iface, ok := nadNetworkInterface(obj); ok {
+			inv.addNAD(loc, iface)
+			return
+		}
+	}
+	own := kind == "NFDeployment" || kind == "IPClaim"
+	if kind == "NFDeployment" {
+		loc.NF, _, _ = unstructured.NestedString(obj, "metadata", "name")
+		nf := inventoryNF{loc: loc}
+		nf.provider, _, _ = unstructured.NestedString(obj, "spec", "provider")
+		ifaces, _, _ := unstructured.NestedSlice(obj, "spec", "interfaces")
+		for _, i := range ifaces {
+			m, _ := i.(map[string]any)
+			if n, _ := m["name"].(string); n != "" {
+				nf.interfaces = append(nf.interfaces, n)
+			}
+		}
+		inv.nfs = append(inv.nfs, nf)
+	}
+	seen := map[string]bool{}
+	add := func(u AddrUse) {
+		k := u.Address + "|" + u.Role + "|" + u.Interface
+		if !seen[k] {
+			seen[k] = true
+			inv.uses = append(inv.uses, u)
+		}
+	}
+
+	var walk func(v any, iface, key string)
+	walk = func(v any, iface, key string) {
+		switch t := v.(type) {
+		case map[string]any:
+			if n, ok := t["name"].(string); ok && n != "" {
+				iface = n
+			}
+			for k, c := range t {
+				if k != "metadata" {
+					walk(c, iface, k)
+				}
+			}
+		case []any:
+			for _, c := range t {
+				walk(c, iface, key)
+			}
+		case string:
+			role := addrKeyRole(key)
+			if role == "" {
+				return
+			}
+			if role == roleAddress && !own {
+				role = roleReference
+			}
+			for _, a := range hostAddrs(t) {
+				u := loc
+				u.Address, u.CIDR, u.Role, u.Interface = a.addr, a.cidr, role, iface
+				add(u)
+			}
+		}
+	}
+	walk(obj, "", "")
+}
+
+// addNAD records a NAD network and its static addresses and gateways.
+func (inv *addrInventory) addNAD(loc AddrUse, iface NetworkInterface) {
+	inv.nads = append(inv.nads, inventoryNAD{loc: loc, iface: iface})
+	for _, c := range iface.CIDRs {
+		if p, err := netip.ParsePrefix(c); err == nil && p.Addr() != p.Masked().Addr() {
+			u := loc
+			u.Address, u.CIDR, u.Role, u.Interface = p.Addr().String(), c, roleAddress, iface.Name
+			inv.uses = append(inv.uses, u)
+		}
+	}
+	if iface.NAD == nil {
+		return
+	}
+	for _, g := range iface.NAD.Gateways {
+		if a, err := netip.ParseAddr(g); err == nil {
+			u := loc
+			u.Address, u.Role, u.Interface = a.String(), roleGateway, iface.Name
+			inv.uses = append(inv.uses, u)
+		}
+	}
+}
+
+// addrKeyRole classifies the key an address was found under: gateway, address,
+// or "" for keys that define networks rather than use addresses (range bounds,
+// exclusions, route destinations, subnets).
+func addrKeyRole(key string) string {
+	k := strings.ToLower(key)
+	switch {
+	case strings.Contains(k, "gateway") || k == "gw":
+		return roleGateway
+	case strings.Contains(k, "range") || strings.Contains(k, "exclude") || strings.Contains(k, "subnet") ||
+		strings.Contains(k, "pool") || k == "dst":
+		return ""
+	}
+	return roleAddress
+}
+
+type hostAddr struct{ addr, cidr string }
+
+// hostAddrs returns the host addresses in s: bare IPs and CIDRs with host bits
+// set (10.0.0.5/24), but not network prefixes (10.0.0.0/24).
+func hostAddrs(s string) []hostAddr {
+	cidrs, ips := findAddrs(s)
+	var out []hostAddr
+	fromCIDR := map[string]bool{}
+	for _, c := range cidrs {
+		p, err := netip.ParsePrefix(c)
+		if err != nil {
+			continue
+		}
+		fromCIDR[p.Addr().String()] = true
+		if p.Bits() == p.Addr().BitLen() || p.Addr() != p.Masked().Addr() {
+			out = append(out, hostAddr{addr: p.Addr().String(), cidr: c})
+		}
+	}
+	for _, ip := range ips {
+		if !fromCIDR[ip] {
+			out = append(out, hostAddr{addr: ip})
+		}
+	}
+	return out
+}
+
+// usedSet returns every address in use, in any role.
+func (inv *addrInventory) usedSet() map[netip.Addr]bool {
+	out := make(map[netip.Addr]bool, len(inv.uses))
+	for _, u := range inv.uses {
+		if a, err := netip.ParseAddr(u.Address); err == nil {
+			out[a.Unmap()] = true
+		}
+	}
+	return out
+}
+
+// nadsFor returns the NAD networks of interface iface, repo ones first.
+func (inv *addrInventory) nadsFor(iface string) []inventoryNAD {
+	var out []inventoryNAD
+	for _, n := range inv.nads {
+		if networkNameMatches(n.iface.Name, iface) {
+			out = append(out, n)
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].loc.Repo != "" && out[j].loc.Repo == "" })
+	return out
+}
+
+// clusterClients returns reachable clients for cluster: a context of the
+// kubeconfig, else a workload cluster (name or namespace/name) resolved through
+// the management cluster.
+func clusterClients(ctx context.Context, mgmtContext, cluster string) (*kube.Clients, error) {
+	var c *kube.Clients
+	var err error
+	if _, raw, lerr := kube.LoadRawConfig(); lerr == nil && raw.Contexts[cluster] != nil {
+		c, err = kube.DefaultPool().Management(ctx, cluster)
+	} else {
+		mgmtCtx, merr := defaultMgmtContext(mgmtContext)
+		if merr != nil {
+			return nil, merr
+		}
+		c, err = kube.DefaultPool().Workload(ctx, mgmtCtx, cluster)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("build clients for %s: %w", cluster, err)
+	}
+	if r := kube.DefaultPool().Probe(ctx, c); !r.Reachable {
+		return nil, &ToolError{
+			Code:      CodeUnavailable,
+			Message:   fmt.Sprintf("API server of %s unreachable: %s", cluster, r.Error),
+			Target:    cluster,
+			Retryable: true,
+		}
+	}
+	return c, nil
+}
+
+// fleetCluster is a cluster to read: its display name and how to reach it.
+type fleetCluster struct{ name, ref string }
+
+// fleetClusters returns the clusters named, or every kubeconfig context and
+// CAPI cluster, with target added when missing.
+func fleetClusters(ctx context.Context, mgmtContext string, names []string, target string, report func(string, error)) ([]fleetCluster, error) {
+	var out []fleetCluster
+	seen := map[string]bool{}
+	add := func(fc fleetCluster) {
+		if fc.ref != "" && !seen[fc.ref] && !seen[fc.name] {
+			seen[fc.ref], seen[fc.name] = true, true
+			out = append(out, fc)
+		}
+	}
+	for _, n := range names {
+		n = strings.TrimSpace(n)
+		add(fleetCluster{name: n, ref: n})
+	}
+	if len(names) == 0 {
+		_, raw, err := kube.LoadRawConfig()
+		if err != nil {
+			return nil, err
+		}
+		ctxNames := make([]string, 0, len(raw.Contexts))
+		for n := range raw.Contexts {
+			ctxNames = append(ctxNames, n)
+		}
+		sort.Strings(ctxNames)
+		for _, n := range ctxNames {
+			add(fleetCluster{name: n, ref: n})
+		}
+		mgmtCtx, err := defaultMgmtContext(mgmtContext)
+		if err != nil {
+			return nil, err
+		}
+		mgmt, err := kube.DefaultPool().Management(ctx, mgmtCtx)
+		if err != nil {
+			return nil, fmt.Errorf("build clients (context=%s): %w", mgmtCtx, err)
+		}
+		items, err := kube.NewWorkloadClusterResolver(mgmt).List(ctx)
+		if err != nil {
+			report(sourceCAPIClusters, err)
+		}
+		for i := range items {
+			add(fleetCluster{name: items[i].GetName(), ref: items[i].GetNamespace() + "/" + items[i].GetName()})
+		}
+	}
+	if target != "" {
+		add(fleetCluster{name: target, ref: target})
+	}
+	return out, nil
+}
+
+// merge adds what o collected.
+func (inv *addrInventory) merge(o *addrInventory) {
+	inv.uses = append(inv.uses, o.uses...)
+	inv.nads = append(inv.nads, o.nads...)
+	inv.nfs = append(inv.nfs, o.nfs...)
+	inv.reserved = append(inv.reserved, o.reserved...)
+}
+
+// fleetScanLimits resolves the concurrency and per-cluster deadline of a fleet
+// read, defaulting to the scan section of the server config.
+func fleetScanLimits(concurrency int, clusterTimeout string) (int, time.Duration, error) {
+	cfg := config.Current()
+	if concurrency <= 0 {
+		concurrency = cfg.Scan.ClusterConcurrency
+	}
+	timeout := config.Duration(cfg.Scan.ClusterTimeout, time.Minute)
+	if v := strings.TrimSpace(clusterTimeout); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return 0, 0, invalidArgument("clusterTimeout", "invalid duration %q", v)
+		}
+		timeout = d
+	}
+	return concurrency, timeout, nil
+}
+
+// scanFleet reads clusters in parallel, each into its own inventory under its
+// own deadline, and merges them. Problems are passed to report with the
+// cluster they came from.
+func scanFleet(ctx context.Context, mgmtContext string, clusters []fleetCluster, namespace string, concurrency int, timeout time.Duration, report func(cluster, source string, err error)) *addrInventory {
+	invs := make([]addrInventory, len(clusters))
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, fc := range clusters {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			rep := func(source string, err error) { report(fc.name, source, err) }
+			cctx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+			c, err := clusterClients(cctx, mgmtContext, fc.ref)
+			if err != nil {
+				source := sourceClients
+				if asToolError(err).Code == CodeUnavailable {
+					source = sourceAPIServer
+				}
+				rep(source, err)
+				return
+			}
+			invs[i].addCluster(cctx, fc.name, c, namespace, rep)
+			if errors.Is(cctx.Err(), context.DeadlineExceeded) && ctx.Err() == nil {
+				rep(sourceDeadline, fmt.Errorf("cluster read exceeded %s: %w", timeout, cctx.Err()))
+			}
+		}()
+	}
+	wg.Wait()
+
+	var inv addrInventory
+	for i := range invs {
+		inv.merge(&invs[i])
+	}
+	return &inv
+}
+
+// fleetReporter returns a scanFleet report function that appends to errs and
+// warns, safe for concurrent cluster reads.
+func fleetReporter(errs, warns *[]ScanIssue) func(cluster, source string, err error) {
+	var mu sync.Mutex
+	return func(cluster, source string, err error) {
+		issue, warning := newScanIssue(source, err)
+		issue.Cluster = cluster
+		mu.Lock()
+		defer mu.Unlock()
+		if warning {
+			*warns = append(*warns, issue)
+		} else {
+			*errs = append(*errs, issue)
+		}
+	}
+}
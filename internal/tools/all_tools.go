@@ -2,6 +2,10 @@ package tools
 
 import (
     "context"
+    "fmt"
+    "time"
+
+    "github.com/modelcontextprotocol/go-sdk/jsonschema"
     "github.com/modelcontextprotocol/go-sdk/mcp"
 )
 
@@ -9,18 +13,62 @@ func AddToolsToServer(server *mcp.Server) {
     for _, addToolFunc := range toolsToAdd {
         addToolFunc(server)
     }
+    server.AddReceivingMiddleware(validateToolArgs)
 }
 
 var toolsToAdd []func(server *mcp.Server)
 
 func registerTool[I, O any](tool MCPTool[I, O]) {
     toolsToAdd = append(toolsToAdd, func(server *mcp.Server) {
-        mcp.AddTool(server, &mcp.Tool{Name: tool.Name, Description: tool.Description}, tool.Handler)
+        inSchema, err := schemaFor[I]()
+        if err != nil {
+            panic(fmt.Sprintf("tool %q: input schema: %v", tool.Name, err))
+        }
+        // A schema can only be resolved once and AddTool resolves its own, so
+        // validateToolArgs gets a separate copy.
+        vSchema, _ := schemaFor[I]()
+        resolved, err := vSchema.Resolve(&jsonschema.ResolveOptions{ValidateDefaults: true})
+        if err != nil {
+            panic(fmt.Sprintf("tool %q: resolve input schema: %v", tool.Name, err))
+        }
+        inputSchemas.Store(tool.Name, resolved)
+
+        // Tools are registered with Out=any so failures can carry a ToolErrorResult;
+        // the output schema is still derived from O.
+        outSchema, err := schemaFor[O]()
+        if err != nil {
+            panic(fmt.Sprintf("tool %q: output schema: %v", tool.Name, err))
+        }
+        mcp.AddTool(server, &mcp.Tool{Name: tool.Name, Description: tool.Description, InputSchema: inSchema, OutputSchema: outSchema},
+            withToolErrors(tracedHandler(tool.Name, withLifecycle(tool.Name, tool.Timeout, withCallerIdentity(tool.Handler)))))
     })
 }
 
 type MCPTool[I, O any] struct {
     Name        string
     Description string
+    // Timeout bounds a call; zero means tools.defaultTimeout from the server config.
+    // tools.timeouts in the config overrides it.
+    Timeout     time.Duration
     Handler     func(ctx context.Context, cc *mcp.ServerSession, params *mcp.CallToolParamsFor[I]) (*mcp.CallToolResultFor[O], error)
 }
+
+// withToolErrors turns handler errors into isError results whose structured
+// content is a ToolErrorResult, so agents get a code instead of free text.
+func withToolErrors[I, O any](h func(context.Context, *mcp.ServerSession, *mcp.CallToolParamsFor[I]) (*mcp.CallToolResultFor[O], error)) mcp.ToolHandlerFor[I, any] {
+    return func(ctx context.Context, cc *mcp.ServerSession, params *mcp.CallToolParamsFor[I]) (*mcp.CallToolResultFor[any], error) {
+        res, err := h(ctx, cc, params)
+        if err != nil {
+            return toolErrorResult(asToolError(err)), nil
+        }
+        if res == nil {
+            return &mcp.CallToolResultFor[any]{Content: []mcp.Content{}}, nil
+        }
+        return &mcp.CallToolResultFor[any]{
+            Meta:              res.Meta,
+            Content:           res.Content,
+            StructuredContent: res.StructuredContent,
+            IsError:           res.IsError,
+        }, nil
+    }
+}
@@ -3,10 +3,10 @@ package tools
 import (
 	"context"
 	"encoding/json"
-	"fmt"
 	"strings"
 	"time"
 
+	"nfreconfig-mcp-server/internal/config"
 	"nfreconfig-mcp-server/internal/kube"
 
 	"github.com/modelcontextprotocol/go-sdk/mcp"
@@ -18,16 +18,16 @@ import (
 func init() { registerTool(ArgoCDSyncApp()) }
 
 type ArgoCDSyncAppParams struct {
-	Context   string `json:"context,omitempty"`   // mgmt kube context; default current
-	Cluster   string `json:"cluster"`             // workload cluster name (CAPI cluster)
-	Namespace string `json:"namespace,omitempty"` // default "argocd"
-	AppName   string `json:"appName"`             // application name
-	Prune     bool   `json:"prune,omitempty"`     // default true
+	Context   string `json:"context,omitempty" description:"Management cluster kube context; defaults to the -mgmt-context flag, then kube.managementContext from the server config, then the current context."`
+	Cluster   string `json:"cluster" description:"Workload cluster (CAPI Cluster) as name or namespace/name; a bare name must be unique across namespaces." example:"5g-edge"`
+	Namespace string `json:"namespace,omitempty" description:"Namespace of the ArgoCD Application on the workload cluster; defaults to argocd.namespace from the server config (argocd)."`
+	AppName   string `json:"appName" description:"ArgoCD Application name."`
+	Prune     *bool  `json:"prune,omitempty" description:"Prune resources no longer in git." default:"true"`
 }
 
 type ArgoCDSyncAppResult struct {
-	Patched bool   `json:"patched"`
-	Error   string `json:"error,omitempty"`
+	Patched bool       `json:"patched"`
+	Error   *ToolError `json:"error,omitempty"`
 }
 
 func ArgoCDSyncApp() MCPTool[ArgoCDSyncAppParams, ArgoCDSyncAppResult] {
@@ -37,15 +37,15 @@ func ArgoCDSyncApp() MCPTool[ArgoCDSyncAppParams, ArgoCDSyncAppResult] {
 		Handler: func(ctx context.Context, cc *mcp.ServerSession, params *mcp.CallToolParamsFor[ArgoCDSyncAppParams]) (*mcp.CallToolResultFor[ArgoCDSyncAppResult], error) {
 			ns := strings.TrimSpace(params.Arguments.Namespace)
 			if ns == "" {
-				ns = "argocd"
+				ns = config.Current().ArgoCD.Namespace
 			}
 			app := strings.TrimSpace(params.Arguments.AppName)
 			if app == "" {
-				return toolErr[ArgoCDSyncAppResult](fmt.Errorf("missing required field: appName"))
+				return toolErr[ArgoCDSyncAppResult](missingField("appName"))
 			}
 			cluster := strings.TrimSpace(params.Arguments.Cluster)
 			if cluster == "" {
-				return toolErr[ArgoCDSyncAppResult](fmt.Errorf("missing required field: cluster"))
+				return toolErr[ArgoCDSyncAppResult](missingField("cluster"))
 			}
 
 			dyn, err := kube.BuildWorkloadDynamicClientByCAPICluster(ctx, params.Arguments.Context, cluster)
@@ -59,10 +59,9 @@ func ArgoCDSyncApp() MCPTool[ArgoCDSyncAppParams, ArgoCDSyncAppResult] {
 				Resource: "applications",
 			}
 
-			prune := params.Arguments.Prune
-			if !params.Arguments.Prune {
-				// allow false explicitly; default true behavior:
-				prune = true
+			prune := true
+			if params.Arguments.Prune != nil {
+				prune = *params.Arguments.Prune
 			}
 
 			patch := map[string]any{
@@ -0,0 +1,427 @@
+package tools
+
+import (
+	"context"
+	"net/netip"
+	"regexp"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/yaml"
+)
+
+// CIDR sources, reported in ClusterNetworkInfo and ScanIssue.Source.
+const (
+	sourceServiceCIDRObjects = "servicecidrs"       // networking.k8s.io ServiceCIDR
+	sourceAPIServerPod       = "kube-apiserver-pod" // static pod --service-cluster-ip-range
+	sourceKubeadmConfig      = "kubeadm-config"     // kube-system/kubeadm-config ClusterConfiguration
+	sourceServiceIPProbe     = "service-ip-probe"   // dry-run Service with an out-of-range ClusterIP
+	sourceKubernetesService  = "kubernetes-service" // ClusterIP of default/kubernetes, an estimate
+	sourceCalicoIPPools      = "calico-ippools"
+	sourceCilium             = "cilium" // cilium-config ConfigMap or CiliumNodes
+)
+
+var (
+	serviceCIDRGVRs = []schema.GroupVersionResource{
+		{Group: "networking.k8s.io", Version: "v1", Resource: "servicecidrs"},
+		{Group: "networking.k8s.io", Version: "v1beta1", Resource: "servicecidrs"},
+	}
+	calicoIPPoolGVRs = []schema.GroupVersionResource{
+		{Group: "crd.projectcalico.org", Version: "v1", Resource: "ippools"},
+		{Group: "projectcalico.org", Version: "v3", Resource: "ippools"},
+	}
+	ciliumNodeGVR = schema.GroupVersionResource{Group: "cilium.io", Version: "v2", Resource: "ciliumnodes"}
+)
+
+// clusterCIDRs is what getClusterCIDRs found and where.
+type clusterCIDRs struct {
+	pod, service             []string
+	podSource, serviceSource string
+}
+
+// cidrScan tries the CIDR sources of one cluster in turn.
+type cidrScan struct {
+	dyn    dynamic.Interface
+	cs     *kubernetes.Clientset
+	report func(source string, err error)
+
+	kubeadm *kubeadmNetworking // read once, used by both chains
+}
+
+type kubeadmNetworking struct {
+	PodSubnet     string `json:"podSubnet"`
+	ServiceSubnet string `json:"serviceSubnet"`
+}
+
+// getClusterCIDRs discovers pod and service CIDRs, taking each from the first
+// source that has them:
+//
+//	service: ServiceCIDR objects, kube-apiserver static pod args, kubeadm-config,
+//	         a dry-run Service with an out-of-range ClusterIP, the ClusterIP
+//	         of the kubernetes Service
+//	pod:     Node.spec.podCIDR(s), Calico IPPools, Cilium, kube-proxy
+//	         clusterCIDR, kubeadm-config
+//
+// Failures that are not NotFound are always reported; NotFound (CRD or
+// ConfigMap absent), and Forbidden for optional sources, only when no source
+// had the CIDRs.
+func getClusterCIDRs(ctx context.Context, dyn dynamic.Interface, cs *kubernetes.Clientset, report func(source string, err error)) clusterCIDRs {
+	var out clusterCIDRs
+	if cs == nil {
+		return out
+	}
+	s := &cidrScan{dyn: dyn, cs: cs, report: report}
+
+	out.service, out.serviceSource = s.first(ctx, []cidrSource{
+		{sourceServiceCIDRObjects, s.serviceCIDRObjects, false},
+		{sourceAPIServerPod, s.apiServerServiceRange, false},
+		{sourceKubeadmConfig, s.kubeadmServiceSubnet, false},
+		{sourceServiceIPProbe, s.probeServiceRange, true},
+		{sourceKubernetesService, s.kubernetesServiceRange, false},
+	})
+
+	// Node podCIDRs are what kube-controller-manager allocated; CNIs with their
+	// own IPAM (Calico, Cilium cluster-pool) leave them empty.
+	out.pod, out.podSource = s.first(ctx, []cidrSource{
+		{sourceNodes, s.nodePodCIDRs, false},
+		{sourceCalicoIPPools, s.calicoIPPools, false},
+		{sourceCilium, s.ciliumPodCIDRs, false},
+		{sourceKubeProxy, s.kubeProxyClusterCIDR, false},
+		{sourceKubeadmConfig, s.kubeadmPodSubnet, false},
+	})
+	return out
+}
+
+type cidrSource struct {
+	name string
+	read func(context.Context) ([]string, error)
+	// optional sources need a permission the server may lack by design
+	optional bool
+}
+
+// first returns the CIDRs of the first source that has any.
+func (s *cidrScan) first(ctx context.Context, sources []cidrSource) ([]string, string) {
+	type issue struct {
+		source string
+		err    error
+	}
+	var missing []issue
+	for _, src := range sources {
+		cidrs, err := src.read(ctx)
+		if err != nil {
+			if apierrors.IsNotFound(err) || src.optional && apierrors.IsForbidden(err) {
+				missing = append(missing, issue{src.name, err})
+			} else {
+				s.report(src.name, err)
+			}
+			if ctx.Err() != nil {
+				return nil, ""
+			}
+			continue
+		}
+		if cidrs = normalizeCIDRs(cidrs); len(cidrs) > 0 {
+			return cidrs, src.name
+		}
+	}
+	for _, m := range missing {
+		s.report(m.source, m.err)
+	}
+	return nil, ""
+}
+
+// normalizeCIDRs trims, validates, dedups and sorts; invalid entries are dropped.
+func normalizeCIDRs(in []string) []string {
+	seen := map[string]bool{}
+	var out []string
+	for _, c := range in {
+		c = strings.TrimSpace(c)
+		if c == "" || seen[c] {
+			continue
+		}
+		if _, err := netip.ParsePrefix(c); err != nil {
+			continue
+		}
+		seen[c] = true
+		out = append(out, c)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// splitList splits comma or whitespace separated values.
+func splitList(s string) []string {
+	return strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == ' ' || r == '\n' || r == '\t' })
+}
+
+// ---------------- service CIDR sources ----------------
+
+func (s *cidrScan) serviceCIDRObjects(ctx context.Context) ([]string, error) {
+	list, err := listFirstServed(ctx, s.dyn, serviceCIDRGVRs)
+	if err != nil {
+		return nil, err
+	}
+	var out []string
+	for _, it := range list {
+		cidrs, _, _ := unstructured.NestedStringSlice(it.Object, "spec", "cidrs")
+		out = append(out, cidrs...)
+	}
+	return out, nil
+}
+
+func (s *cidrScan) apiServerServiceRange(ctx context.Context) ([]string, error) {
+	pods, err := s.cs.CoreV1().Pods("kube-system").List(ctx, metav1.ListOptions{LabelSelector: "component=kube-apiserver"})
+	if err != nil {
+		return nil, err
+	}
+	var out []string
+	for i := range pods.Items {
+		for _, c := range pods.Items[i].Spec.Containers {
+			if v, ok := flagValue(append(append([]string{}, c.Command...), c.Args...), "--service-cluster-ip-range"); ok {
+				out = append(out, splitList(v)...)
+			}
+		}
+	}
+	return out, nil
+}
+
+// flagValue finds --name=value or --name value in args.
+func flagValue(args []string, name string) (string, bool) {
+	for i, a := range args {
+		if v, ok := strings.CutPrefix(a, name+"="); ok {
+			return v, true
+		}
+		if a == name && i+1 < len(args) {
+			return args[i+1], true
+		}
+	}
+	return "", false
+}
+
+func (s *cidrScan) readKubeadm(ctx context.Context) (*kubeadmNetworking, error) {
+	if s.kubeadm != nil {
+		return s.kubeadm, nil
+	}
+	cm, err := s.cs.CoreV1().ConfigMaps("kube-system").Get(ctx, "kubeadm-config", metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	var cc struct {
+		Networking kubeadmNetworking `json:"networking"`
+	}
+	if err := yaml.Unmarshal([]byte(cm.Data["ClusterConfiguration"]), &cc); err != nil {
+		return nil, invalidArgument("kube-system/kubeadm-config", "parse ClusterConfiguration: %v", err)
+	}
+	s.kubeadm = &cc.Networking
+	return s.kubeadm, nil
+}
+
+func (s *cidrScan) kubeadmServiceSubnet(ctx context.Context) ([]string, error) {
+	n, err := s.readKubeadm(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return splitList(n.ServiceSubnet), nil
+}
+
+// probeRangeRE matches the range in the apiserver's rejection of an
+// out-of-range ClusterIP: "... The range of valid IPs is 10.96.0.0/12".
+var probeRangeRE = regexp.MustCompile(`range of valid IPs is ([0-9A-Fa-f:./]+)`)
+
+// probeServiceRange asks the apiserver to validate (dry run, nothing is
+// created) a Service whose ClusterIP is outside any sane service range and
+// reads the valid range from the rejection. Needs create on services in the
+// default namespace.
+func (s *cidrScan) probeServiceRange(ctx context.Context) ([]string, error) {
+	svc := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: "nfreconfig-mcp-cidr-probe"},
+		Spec: corev1.ServiceSpec{
+			ClusterIP: "1.1.1.1",
+			Ports:     []corev1.ServicePort{{Port: 443}},
+		},
+	}
+	_, err := s.cs.CoreV1().Services(metav1.NamespaceDefault).Create(ctx, svc, metav1.CreateOptions{DryRun: []string{metav1.DryRunAll}})
+	if err == nil {
+		return nil, nil // 1.1.1.1 is in range; nothing learned
+	}
+	if !apierrors.IsInvalid(err) {
+		return nil, err
+	}
+	var out []string
+	for _, m := range probeRangeRE.FindAllStringSubmatch(err.Error(), -1) {
+		out = append(out, strings.TrimRight(m[1], "."))
+	}
+	return out, nil
+}
+
+// kubernetesServiceRange estimates the service range from the ClusterIP of
+// default/kubernetes, the first address of the primary range: the widest
+// range the apiserver allows (/12 for IPv4, /108 for IPv6) that starts just
+// below it. It can only be wider than the real range, never miss part of it.
+func (s *cidrScan) kubernetesServiceRange(ctx context.Context) ([]string, error) {
+	svc, err := s.cs.CoreV1().Services(metav1.NamespaceDefault).Get(ctx, "kubernetes", metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	var out []string
+	ips := svc.Spec.ClusterIPs
+	if len(ips) == 0 {
+		ips = []string{svc.Spec.ClusterIP}
+	}
+	for _, ip := range ips {
+		a, err := netip.ParseAddr(ip)
+		if err != nil {
+			continue
+		}
+		if p, ok := serviceRangeFor(a); ok {
+			out = append(out, p.String())
+		}
+	}
+	return out, nil
+}
+
+// serviceRangeFor returns the widest allowed range whose first address is
+// a - 1.
+func serviceRangeFor(a netip.Addr) (netip.Prefix, bool) {
+	b := a.AsSlice()
+	for i := len(b) - 1; i >= 0; i-- {
+		b[i]--
+		if b[i] != 0xff {
+			break
+		}
+	}
+	base, _ := netip.AddrFromSlice(b)
+	widest := 12
+	if a.Is6() {
+		widest = 108
+	}
+	for bits := widest; bits <= base.BitLen(); bits++ {
+		if p := netip.PrefixFrom(base, bits); p.Masked().Addr() == base {
+			return p, true
+		}
+	}
+	return netip.Prefix{}, false
+}
+
+// ---------------- pod CIDR sources ----------------
+
+func (s *cidrScan) nodePodCIDRs(ctx context.Context) ([]string, error) {
+	nl, err := s.cs.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	var out []string
+	for i := range nl.Items {
+		n := &nl.Items[i]
+		out = append(out, n.Spec.PodCIDR)
+		out = append(out, n.Spec.PodCIDRs...)
+	}
+	return out, nil
+}
+
+func (s *cidrScan) calicoIPPools(ctx context.Context) ([]string, error) {
+	list, err := listFirstServed(ctx, s.dyn, calicoIPPoolGVRs)
+	if err != nil {
+		return nil, err
+	}
+	var out []string
+	for _, it := range list {
+		if disabled, _, _ := unstructured.NestedBool(it.Object, "spec", "disabled"); disabled {
+			continue
+		}
+		if c, _, _ := unstructured.NestedString(it.Object, "spec", "cidr"); c != "" {
+			out = append(out, c)
+		}
+	}
+	return out, nil
+}
+
+// ciliumPodCIDRs reads the cluster-pool IPAM ranges from cilium-config, else
+// the per-node allocations from CiliumNodes.
+func (s *cidrScan) ciliumPodCIDRs(ctx context.Context) ([]string, error) {
+	var out []string
+	cm, err := s.cs.CoreV1().ConfigMaps("kube-system").Get(ctx, "cilium-config", metav1.GetOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return nil, err
+	}
+	if err == nil {
+		for _, key := range []string{"cluster-pool-ipv4-cidr", "cluster-pool-ipv6-cidr"} {
+			out = append(out, splitList(cm.Data[key])...)
+		}
+		if len(out) > 0 {
+			return out, nil
+		}
+	}
+	nodes, err := s.dyn.Resource(ciliumNodeGVR).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for _, it := range nodes.Items {
+		cidrs, _, _ := unstructured.NestedStringSlice(it.Object, "spec", "ipam", "podCIDRs")
+		out = append(out, cidrs...)
+	}
+	return out, nil
+}
+
+// kubeProxyClusterCIDR reads clusterCIDR from the kube-proxy configuration. It
+// is the pod range kube-proxy uses to tell cluster-internal traffic apart, not
+// the service range.
+func (s *cidrScan) kubeProxyClusterCIDR(ctx context.Context) ([]string, error) {
+	cm, err := s.cs.CoreV1().ConfigMaps("kube-system").Get(ctx, "kube-proxy", metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	var out []string
+	for _, key := range []string{"config.conf", "kube-proxy.conf"} {
+		raw := strings.TrimSpace(cm.Data[key])
+		if raw == "" {
+			continue
+		}
+		var m map[string]any
+		if err := yaml.Unmarshal([]byte(raw), &m); err != nil {
+			continue
+		}
+		if c, ok := m["clusterCIDR"].(string); ok {
+			out = append(out, splitList(c)...)
+		}
+		if arr, ok := m["clusterCIDRs"].([]any); ok {
+			for _, v := range arr {
+				if c, ok := v.(string); ok {
+					out = append(out, c)
+				}
+			}
+		}
+	}
+	return out, nil
+}
+
+func (s *cidrScan) kubeadmPodSubnet(ctx context.Context) ([]string, error) {
+	n, err := s.readKubeadm(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return splitList(n.PodSubnet), nil
+}
+
+// listFirstServed lists the first of gvrs the cluster serves; the last NotFound
+// is returned when none is.
+func listFirstServed(ctx context.Context, dyn dynamic.Interface, gvrs []schema.GroupVersionResource) ([]unstructured.Unstructured, error) {
+	var err error
+	for _, gvr := range gvrs {
+		var ul *unstructured.UnstructuredList
+		ul, err = dyn.Resource(gvr).List(ctx, metav1.ListOptions{})
+		if err == nil {
+			return ul.Items, nil
+		}
+		if !apierrors.IsNotFound(err) {
+			return nil, err
+		}
+	}
+	return nil, err
+}
@@ -1,8 +1,6 @@
 package tools
 
 import (
-	"strings"
-
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/client-go/tools/clientcmd"
 )
@@ -55,19 +53,3 @@ func extractAPIServerFromKubeconfig(kubeconfig []byte) string {
 	}
 	return ""
 }
-
-// looksBase64 is a heuristic check if bytes look like base64 encoded data
-func looksBase64(b []byte) bool {
-	s := strings.TrimSpace(string(b))
-	if len(s) < 16 {
-		return false
-	}
-	// quick heuristic
-	for _, r := range s {
-		if (r >= 'A' && r <= 'Z') || (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '+' || r == '/' || r == '=' || r == '\n' {
-			continue
-		}
-		return false
-	}
-	return true
-}
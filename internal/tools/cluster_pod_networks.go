@@ -0,0 +1,294 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/netip"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Multus writes what it actually attached to a pod in these annotations; the
+// second is the name used before the NPWG spec settled on the first.
+const (
+	networkStatusAnnotation       = "k8s.v1.cni.cncf.io/network-status"
+	legacyNetworkStatusAnnotation = "k8s.v1.cni.cncf.io/networks-status"
+)
+
+// Live network sources, reported in ScanIssue.Source.
+const (
+	sourcePods          = "pods"
+	sourceNFDeployments = "nfdeployments"
+	sourceNetworkStatus = "network-status" // a pod's network-status annotation
+)
+
+var nfDeploymentGVR = schema.GroupVersionResource{Group: "workload.nephio.org", Version: "v1alpha1", Resource: "nfdeployments"}
+
+// PodNetworkStatus is what Multus attached to a pod.
+type PodNetworkStatus struct {
+	Namespace  string         `json:"namespace"`
+	Name       string         `json:"name"`
+	NF         string         `json:"nf,omitempty"` // NFDeployment the pod belongs to
+	Node       string         `json:"node,omitempty"`
+	Interfaces []PodInterface `json:"interfaces"`
+}
+
+// PodInterface is one entry of a pod's network-status annotation.
+type PodInterface struct {
+	Interface string   `json:"interface,omitempty"` // name inside the pod (n2, net1)
+	Network   string   `json:"network"`             // NAD reference (namespace/name) or the cluster network
+	IPs       []string `json:"ips,omitempty"`
+	MAC       string   `json:"mac,omitempty"`
+	Default   bool     `json:"default,omitempty"` // the cluster (pod) network
+}
+
+// NFLiveStatus compares the interfaces an NFDeployment declares with what its
+// pods got.
+type NFLiveStatus struct {
+	Namespace  string              `json:"namespace"`
+	Name       string              `json:"name"`
+	Pods       []string            `json:"pods,omitempty"`
+	Interfaces []NFInterfaceStatus `json:"interfaces,omitempty"`
+	// Mismatch is true when a declared address is not on any pod, or a declared
+	// interface is missing
+	Mismatch bool `json:"mismatch"`
+}
+
+// NFInterfaceStatus is one declared interface of an NF against the live pods.
+type NFInterfaceStatus struct {
+	Name        string   `json:"name"`
+	DeclaredIPs []string `json:"declaredIps,omitempty"`
+	LiveIPs     []string `json:"liveIps,omitempty"`
+	// Status: match, mismatch (declared IPs not live), missing (no pod has the
+	// interface) or no-pods
+	Status string `json:"status"`
+}
+
+// multusNetworkStatus is one element of the network-status annotation.
+type multusNetworkStatus struct {
+	Name      string   `json:"name"`
+	Interface string   `json:"interface"`
+	IPs       []string `json:"ips"`
+	MAC       string   `json:"mac"`
+	Default   bool     `json:"default"`
+}
+
+// scanLiveNetworks reads the network-status of pods in namespace and compares
+// it with the NFDeployments there. Only pods with a secondary (non-default)
+// network are returned.
+func scanLiveNetworks(ctx context.Context, dyn dynamic.Interface, cs *kubernetes.Clientset, namespace string, report func(source string, err error)) ([]PodNetworkStatus, []NFLiveStatus) {
+	if cs == nil {
+		return nil, nil
+	}
+	pl, err := cs.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		report(sourcePods, err)
+		return nil, nil
+	}
+	var nfdObjs []unstructured.Unstructured
+	if dyn != nil {
+		ul, err := dyn.Resource(nfDeploymentGVR).Namespace(namespace).List(ctx, metav1.ListOptions{})
+		if err != nil {
+			report(sourceNFDeployments, err)
+		} else {
+			nfdObjs = ul.Items
+		}
+	}
+	return liveNetworks(pl.Items, nfdObjs, report)
+}
+
+// liveNetworks is scanLiveNetworks on listed pods and NFDeployments.
+func liveNetworks(podList []corev1.Pod, nfdObjs []unstructured.Unstructured, report func(source string, err error)) ([]PodNetworkStatus, []NFLiveStatus) {
+	nfds := make([]nfDeclared, 0, len(nfdObjs))
+	for _, u := range nfdObjs {
+		nfds = append(nfds, nfDeclared{
+			namespace:  u.GetNamespace(),
+			name:       u.GetName(),
+			interfaces: extractNetworkInterfaces(u.Object),
+		})
+	}
+
+	var pods []PodNetworkStatus
+	for i := range podList {
+		p := &podList[i]
+		st, err := podNetworkStatus(p)
+		if err != nil {
+			report(sourceNetworkStatus, invalidArgument(p.Namespace+"/"+p.Name, "%v", err))
+			continue
+		}
+		if !hasSecondary(st) {
+			continue
+		}
+		ps := PodNetworkStatus{Namespace: p.Namespace, Name: p.Name, Node: p.Spec.NodeName, Interfaces: st}
+		for _, nf := range nfds {
+			if nf.owns(p) {
+				ps.NF = nf.name
+				break
+			}
+		}
+		pods = append(pods, ps)
+	}
+	sort.Slice(pods, func(i, j int) bool {
+		if pods[i].Namespace != pods[j].Namespace {
+			return pods[i].Namespace < pods[j].Namespace
+		}
+		return pods[i].Name < pods[j].Name
+	})
+
+	nfs := make([]NFLiveStatus, 0, len(nfds))
+	for _, nf := range nfds {
+		nfs = append(nfs, nf.compare(pods))
+	}
+	sort.Slice(nfs, func(i, j int) bool {
+		if nfs[i].Namespace != nfs[j].Namespace {
+			return nfs[i].Namespace < nfs[j].Namespace
+		}
+		return nfs[i].Name < nfs[j].Name
+	})
+	return pods, nfs
+}
+
+// podNetworkStatus parses the pod's network-status annotation; nil when absent.
+func podNetworkStatus(p *corev1.Pod) ([]PodInterface, error) {
+	raw, ok := p.Annotations[networkStatusAnnotation]
+	if !ok {
+		raw, ok = p.Annotations[legacyNetworkStatusAnnotation]
+	}
+	if !ok || strings.TrimSpace(raw) == "" {
+		return nil, nil
+	}
+	var st []multusNetworkStatus
+	if err := json.Unmarshal([]byte(raw), &st); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", networkStatusAnnotation, err)
+	}
+	out := make([]PodInterface, 0, len(st))
+	for _, s := range st {
+		pi := PodInterface{Interface: s.Interface, Network: s.Name, MAC: s.MAC, Default: s.Default}
+		for _, ip := range s.IPs {
+			if a, err := netip.ParseAddr(strings.TrimSpace(ip)); err == nil {
+				pi.IPs = append(pi.IPs, a.String())
+			}
+		}
+		out = append(out, pi)
+	}
+	return out, nil
+}
+
+func hasSecondary(st []PodInterface) bool {
+	for _, pi := range st {
+		if !pi.Default {
+			return true
+		}
+	}
+	return false
+}
+
+// nfDeclared is an NFDeployment and the interfaces it declares.
+type nfDeclared struct {
+	namespace, name string
+	interfaces      []NetworkInterface
+}
+
+// owns reports whether p belongs to the NF: a workload label or the owning
+// Deployment/StatefulSet/DaemonSet carries the NFDeployment name.
+func (nf nfDeclared) owns(p *corev1.Pod) bool {
+	if p.Namespace != nf.namespace {
+		return false
+	}
+	for _, k := range []string{"app.kubernetes.io/instance", "app.kubernetes.io/name", "app", "nf"} {
+		if p.Labels[k] == nf.name {
+			return true
+		}
+	}
+	for _, o := range p.OwnerReferences {
+		name := o.Name
+		if o.Kind == "ReplicaSet" {
+			// <deployment>-<pod-template-hash>
+			if i := strings.LastIndex(name, "-"); i > 0 {
+				name = name[:i]
+			}
+		}
+		if name == nf.name {
+			return true
+		}
+	}
+	return false
+}
+
+// compare matches each declared interface with the live interfaces of the NF's
+// pods, by pod interface name or by NAD name (<name> or <nf>-<name>).
+func (nf nfDeclared) compare(pods []PodNetworkStatus) NFLiveStatus {
+	out := NFLiveStatus{Namespace: nf.namespace, Name: nf.name}
+	var mine []PodNetworkStatus
+	for _, p := range pods {
+		if p.Namespace == nf.namespace && p.NF == nf.name {
+			mine = append(mine, p)
+			out.Pods = append(out.Pods, p.Name)
+		}
+	}
+	for _, di := range nf.interfaces {
+		st := NFInterfaceStatus{Name: di.Name, DeclaredIPs: declaredHostIPs(di)}
+		found := false
+		live := map[string]bool{}
+		for _, p := range mine {
+			for _, pi := range p.Interfaces {
+				if pi.Default || !liveInterfaceMatches(pi, di.Name) {
+					continue
+				}
+				found = true
+				for _, ip := range pi.IPs {
+					live[ip] = true
+				}
+			}
+		}
+		for ip := range live {
+			st.LiveIPs = append(st.LiveIPs, ip)
+		}
+		sort.Strings(st.LiveIPs)
+		switch {
+		case len(mine) == 0:
+			st.Status = "no-pods"
+		case !found:
+			st.Status = "missing"
+			out.Mismatch = true
+		default:
+			st.Status = "match"
+			for _, ip := range st.DeclaredIPs {
+				if !live[ip] {
+					st.Status = "mismatch"
+					out.Mismatch = true
+					break
+				}
+			}
+		}
+		out.Interfaces = append(out.Interfaces, st)
+	}
+	return out
+}
+
+// declaredHostIPs returns the interface addresses of a declared interface: the
+// address part of its CIDRs (10.0.0.5/24 -> 10.0.0.5), skipping network
+// prefixes such as 10.0.0.0/24.
+func declaredHostIPs(iface NetworkInterface) []string {
+	var out []string
+	for _, c := range iface.CIDRs {
+		p, err := netip.ParsePrefix(c)
+		if err != nil || (p.Bits() < p.Addr().BitLen() && p.Addr() == p.Masked().Addr()) {
+			continue
+		}
+		out = append(out, p.Addr().String())
+	}
+	return dedupSorted(out)
+}
+
+func liveInterfaceMatches(pi PodInterface, name string) bool {
+	return pi.Interface == name || networkNameMatches(pi.Network, name)
+}
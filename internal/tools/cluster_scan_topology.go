@@ -2,38 +2,45 @@ package tools
 
 import (
 	"context"
-	"encoding/base64"
+	"errors"
 	"fmt"
 	"sort"
 	"strings"
+	"sync"
+	"time"
 
+	"nfreconfig-mcp-server/internal/config"
 	"nfreconfig-mcp-server/internal/kube"
 
 	"github.com/modelcontextprotocol/go-sdk/mcp"
-	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
-	"k8s.io/client-go/tools/clientcmd"
-	"sigs.k8s.io/yaml"
 )
 
 func init() { registerTool(ClusterScanTopology()) }
 
 type ClusterScanTopologyParams struct {
 	// Optional: filter by cluster name (exact match or prefix)
-	ClusterName string `json:"clusterName,omitempty"`
+	ClusterName string `json:"clusterName,omitempty" description:"Cluster name filter (exact match or prefix)." example:"5g-edge"`
 
 	// Optional: if true, list all available clusters
-	ListAll bool `json:"listAll,omitempty"`
+	ListAll bool `json:"listAll,omitempty" description:"List all available clusters."`
 
 	// Optional: also scan for network topology (CIDRs/IPs) in the cluster
-	IncludeTopology bool `json:"includeTopology,omitempty"`
+	IncludeTopology bool `json:"includeTopology,omitempty" description:"Also scan network topology (pod/service CIDRs, NAD interfaces, IPs)."`
 
 	// Optional: namespace filter for topology scan
-	Namespace string `json:"namespace,omitempty"`
+	Namespace string `json:"namespace,omitempty" description:"Namespace filter for the topology scan; empty means all namespaces."`
+
+	// Optional: management cluster context
+	Context string `json:"context,omitempty" description:"Management cluster kube context; defaults to the -mgmt-context flag, then kube.managementContext from the server config, then the current context."`
+
+	// Optional: fleet scan bounds
+	Concurrency    int    `json:"concurrency,omitempty" description:"How many clusters to scan at once; defaults to scan.clusterConcurrency from the server config (4)." example:"8"`
+	ClusterTimeout string `json:"clusterTimeout,omitempty" description:"Deadline for one cluster (Go duration); defaults to scan.clusterTimeout from the server config (60s)." example:"30s"`
 }
 
 type ClusterTopologyInfo struct {
@@ -53,12 +60,25 @@ type ClusterTopologyInfo struct {
 
 	// Network topology (if requested)
 	NetworkInfo *ClusterNetworkInfo `json:"networkInfo,omitempty"`
+
+	// Reachability of the cluster's API server, probed before the topology scan
+	Reachability *kube.Reachability `json:"reachability,omitempty"`
+	// Errors name the sources that could not be read, so missing topology is
+	// not mistaken for an empty one; Warnings are sources that are simply absent
+	// (CRD not installed, no kube-proxy ConfigMap).
+	Errors   []ScanIssue `json:"errors,omitempty"`
+	Warnings []ScanIssue `json:"warnings,omitempty"`
+	// DurationMs is how long this cluster's secret read and scan took
+	DurationMs int64 `json:"durationMs,omitempty"`
 }
 
 type ClusterNetworkInfo struct {
-	// Pod/Service CIDRs from cluster configuration
-	PodCIDRs     []string `json:"podCidrs,omitempty"`
-	ServiceCIDRs []string `json:"serviceCidrs,omitempty"`
+	// Pod/Service CIDRs from cluster configuration, and the source each was
+	// taken from (see getClusterCIDRs)
+	PodCIDRs          []string `json:"podCidrs,omitempty"`
+	PodCIDRSource     string   `json:"podCidrSource,omitempty"`
+	ServiceCIDRs      []string `json:"serviceCidrs,omitempty"`
+	ServiceCIDRSource string   `json:"serviceCidrSource,omitempty"`
 
 	// Discovered network interfaces from NADs and other resources
 	NetworkInterfaces []NetworkInterface `json:"networkInterfaces,omitempty"`
@@ -66,6 +86,11 @@ type ClusterNetworkInfo struct {
 	// All discovered IPs and CIDRs (flat lists)
 	AllCIDRs []string `json:"allCidrs,omitempty"`
 	AllIPs   []string `json:"allIps,omitempty"`
+
+	// What is actually attached: pods with secondary networks from their Multus
+	// network-status, and per NFDeployment the declared vs live addresses
+	Pods []PodNetworkStatus `json:"pods,omitempty"`
+	NFs  []NFLiveStatus     `json:"nfs,omitempty"`
 }
 
 type ClusterScanTopologyResult struct {
@@ -73,18 +98,109 @@ type ClusterScanTopologyResult struct {
 	Clusters []ClusterTopologyInfo `json:"clusters"`
 	Total    int                   `json:"total"`
 	Message  string                `json:"message,omitempty"`
+	// Errors and Warnings for management cluster sources (CAPI clusters,
+	// repositories); per-cluster ones are on each cluster.
+	Errors   []ScanIssue `json:"errors,omitempty"`
+	Warnings []ScanIssue `json:"warnings,omitempty"`
+}
+
+// ScanIssue is a source a scan could not read and why.
+type ScanIssue struct {
+	// Source is one of: clients, api-server, kubeconfig-secret, nads, nfconfigs,
+	// nodes, kube-proxy-configmap, capi-clusters, repositories, deadline, or a
+	// CIDR source (servicecidrs, kube-apiserver-pod, kubeadm-config,
+	// service-ip-probe, kubernetes-service, calico-ippools, cilium), a live
+	// network source (pods, nfdeployments, network-status), or an IPAM source
+	// (ipclaims, ipprefixes, networkinstances, interfaces, repo-files).
+	Source  string    `json:"source"`
+	Code    ErrorCode `json:"code"`
+	Message string    `json:"message"`
+	Target  string    `json:"target,omitempty"`  // the object at fault, when known
+	Cluster string    `json:"cluster,omitempty"` // for fleet-wide tools, the cluster it was read from
+}
+
+// Scan sources named in ScanIssue.
+const (
+	sourceClients          = "clients"
+	sourceAPIServer        = "api-server"
+	sourceKubeconfigSecret = "kubeconfig-secret"
+	sourceNADs             = "nads"
+	sourceNFConfigs        = "nfconfigs"
+	sourceNodes            = "nodes"
+	sourceKubeProxy        = "kube-proxy-configmap"
+	sourceCAPIClusters     = "capi-clusters"
+	sourceRepositories     = "repositories"
+	sourceDeadline         = "deadline"
+)
+
+// optionalSources may legitimately be absent from a cluster: a NotFound from
+// them (CRD not installed, no kube-proxy, no CAPI) is a warning, not an error.
+var optionalSources = map[string]bool{
+	sourceNADs:         true,
+	sourceNFConfigs:    true,
+	sourceKubeProxy:    true,
+	sourceCAPIClusters: true,
+	sourceRepositories: true,
+
+	sourceServiceCIDRObjects: true,
+	sourceKubeadmConfig:      true,
+	sourceCalicoIPPools:      true,
+	sourceCilium:             true,
+	sourceNFDeployments:      true,
+	sourceIPClaims:           true,
+	sourceIPPrefixes:         true,
+	sourceNetworkInstances:   true,
+	sourceInterfaces:         true,
+}
+
+// newScanIssue classifies err for source.
+func newScanIssue(source string, err error) (issue ScanIssue, warning bool) {
+	te := asToolError(err)
+	issue = ScanIssue{Source: source, Code: te.Code, Message: te.Message, Target: te.Target}
+	return issue, te.Code == CodeNotFound && optionalSources[source]
+}
+
+func (info *ClusterTopologyInfo) addIssue(source string, err error) {
+	if issue, warning := newScanIssue(source, err); warning {
+		info.Warnings = append(info.Warnings, issue)
+	} else {
+		info.Errors = append(info.Errors, issue)
+	}
+}
+
+func (r *ClusterScanTopologyResult) addIssue(source string, err error) {
+	if issue, warning := newScanIssue(source, err); warning {
+		r.Warnings = append(r.Warnings, issue)
+	} else {
+		r.Errors = append(r.Errors, issue)
+	}
 }
 
 func ClusterScanTopology() MCPTool[ClusterScanTopologyParams, ClusterScanTopologyResult] {
 	return MCPTool[ClusterScanTopologyParams, ClusterScanTopologyResult]{
 		Name:        "cluster_scan_topology",
 		Description: "Discover clusters with their Git repositories and network topology. Use for Phase 1 discovery: find target clusters (core/edge/regional), get current IP/CIDR allocations, pod/service CIDRs, and associated git URLs. Example: {\"clusterName\":\"regional\", \"includeTopology\":true} returns cluster info with networkInterfaces (name, IPs, CIDRs), podCidrs, serviceCidrs, and gitURL.",
+		Timeout:     5 * time.Minute,
 		Handler: func(ctx context.Context, cc *mcp.ServerSession, params *mcp.CallToolParamsFor[ClusterScanTopologyParams]) (*mcp.CallToolResultFor[ClusterScanTopologyResult], error) {
 			clusterName := strings.TrimSpace(params.Arguments.ClusterName)
 			listAll := params.Arguments.ListAll
 			includeTopology := params.Arguments.IncludeTopology
 			namespace := strings.TrimSpace(params.Arguments.Namespace)
 
+			cfg := config.Current()
+			concurrency := params.Arguments.Concurrency
+			if concurrency <= 0 {
+				concurrency = cfg.Scan.ClusterConcurrency
+			}
+			clusterTimeout := config.Duration(cfg.Scan.ClusterTimeout, time.Minute)
+			if v := strings.TrimSpace(params.Arguments.ClusterTimeout); v != "" {
+				d, err := time.ParseDuration(v)
+				if err != nil || d <= 0 {
+					return toolErr[ClusterScanTopologyResult](invalidArgument("clusterTimeout", "invalid duration %q", v))
+				}
+				clusterTimeout = d
+			}
+
 			// Load kubeconfig
 			_, raw, err := kube.LoadRawConfig()
 			if err != nil {
@@ -92,13 +208,13 @@ func ClusterScanTopology() MCPTool[ClusterScanTopologyParams, ClusterScanTopolog
 			}
 
 			// Build clients for management cluster
-			dyn, err := kube.BuildDynamicClient(raw.CurrentContext)
+			mgmtCtx, err := defaultMgmtContext(params.Arguments.Context)
 			if err != nil {
-				return toolErr[ClusterScanTopologyResult](fmt.Errorf("build dynamic client: %w", err))
+				return toolErr[ClusterScanTopologyResult](err)
 			}
-			cs, err := kube.BuildClientset(raw.CurrentContext)
+			mgmt, err := kube.DefaultPool().Management(ctx, mgmtCtx)
 			if err != nil {
-				return toolErr[ClusterScanTopologyResult](fmt.Errorf("build clientset: %w", err))
+				return toolErr[ClusterScanTopologyResult](fmt.Errorf("build clients: %w", err))
 			}
 
 			result := ClusterScanTopologyResult{
@@ -108,17 +224,22 @@ func ClusterScanTopology() MCPTool[ClusterScanTopologyParams, ClusterScanTopolog
 			if clusterName != "" {
 				result.Query = clusterName
 			}
+			matches := func(name string) bool {
+				return listAll || clusterName == "" || strings.Contains(strings.ToLower(name), strings.ToLower(clusterName))
+			}
+
+			// Porch repositories are listed once and matched per cluster
+			repos, err := listRepositories(ctx, mgmt.Dynamic, mgmt.Typed.Discovery())
+			if err != nil {
+				result.addIssue(sourceRepositories, err)
+			}
 
 			// 1. Collect clusters from kubeconfig contexts
 			ctxNames := make([]string, 0, len(raw.Contexts))
 			for name := range raw.Contexts {
-				if !listAll && clusterName != "" {
-					// Filter by name (exact or prefix match)
-					if !strings.Contains(strings.ToLower(name), strings.ToLower(clusterName)) {
-						continue
-					}
+				if matches(name) {
+					ctxNames = append(ctxNames, name)
 				}
-				ctxNames = append(ctxNames, name)
 			}
 			sort.Strings(ctxNames)
 
@@ -136,85 +257,69 @@ func ClusterScanTopology() MCPTool[ClusterScanTopologyParams, ClusterScanTopolog
 				}
 
 				// Try to find associated git repo (by naming convention)
-				gitInfo := findGitRepoForCluster(ctx, dyn, cs.Discovery(), name)
+				gitInfo := matchGitRepo(repos, name)
 				info.GitRepoName = gitInfo.Name
 				info.GitURL = gitInfo.URL
 
-				// Optionally scan topology
-				if includeTopology {
-					netInfo, err := scanClusterTopology(ctx, name, namespace)
-					if err == nil && netInfo != nil {
-						info.NetworkInfo = netInfo
-					}
-				}
-
 				result.Clusters = append(result.Clusters, info)
 			}
 
 			// 2. Collect CAPI clusters from management cluster
-			capiGVR := schema.GroupVersionResource{
-				Group:    "cluster.x-k8s.io",
-				Version:  "v1beta1",
-				Resource: "clusters",
+			capi := kube.NewWorkloadClusterResolver(mgmt)
+			capi.PreferUserKubeconfig = cfg.Kube.PreferUserKubeconfig
+			// kubeconfig secrets are read as the server, like workload tools do
+			secrets := capi
+			if serverMgmt, err := kube.DefaultPool().ManagementAsServer(mgmtCtx); err == nil {
+				secrets = kube.NewWorkloadClusterResolver(serverMgmt)
+				secrets.PreferUserKubeconfig = capi.PreferUserKubeconfig
 			}
-
-			ul, err := dyn.Resource(capiGVR).Namespace("").List(ctx, metav1.ListOptions{})
-			if err == nil && ul != nil {
-				for _, it := range ul.Items {
-					name := it.GetName()
-					ns := it.GetNamespace()
-
-					if !listAll && clusterName != "" {
-						// Filter by name
-						if !strings.Contains(strings.ToLower(name), strings.ToLower(clusterName)) {
-							continue
-						}
-					}
-
-					ready := isCAPIClusterReady(&it)
-					secretName := name + "-kubeconfig"
-					secretRef := ns + "/" + secretName
-
-					info := ClusterTopologyInfo{
-						Name:             name,
-						Kind:             "CAPICluster",
-						Namespace:        ns,
-						Ready:            ready,
-						KubeconfigSecret: secretRef,
-					}
-
-					// Extract API server from kubeconfig secret
-					var kubeBytes []byte
-					sec, secErr := cs.CoreV1().Secrets(ns).Get(ctx, secretName, metav1.GetOptions{})
-					if secErr == nil {
-						kubeBytes = extractKubeconfigFromSecret(sec)
-						if len(kubeBytes) > 0 {
-							if apiServer := extractAPIServerFromKubeconfig(kubeBytes); apiServer != "" {
-								info.APIServer = apiServer
-							}
-						}
-					}
-
-					// Try to find associated git repo
-					gitInfo := findGitRepoForCluster(ctx, dyn, cs.Discovery(), name)
-					info.GitRepoName = gitInfo.Name
-					info.GitURL = gitInfo.URL
-
-					// Optionally scan topology using the CAPI kubeconfig secret
-					if includeTopology {
-						if len(kubeBytes) > 0 {
-							dynC, csC, err := clientsFromKubeconfigBytes(kubeBytes)
-							if err == nil {
-								if netInfo, err2 := scanClusterTopologyWithClients(ctx, dynC, csC, namespace); err2 == nil {
-									info.NetworkInfo = netInfo
-								}
-							}
-						}
+			items, err := capi.List(ctx)
+			if err != nil {
+				result.addIssue(sourceCAPIClusters, err)
+			} else {
+				for i := range items {
+					it := &items[i]
+					if !matches(it.GetName()) {
+						continue
 					}
+					gitInfo := matchGitRepo(repos, it.GetName())
+					result.Clusters = append(result.Clusters, ClusterTopologyInfo{
+						Name:        it.GetName(),
+						Kind:        "CAPICluster",
+						Namespace:   it.GetNamespace(),
+						Ready:       isCAPIClusterReady(it),
+						GitRepoName: gitInfo.Name,
+						GitURL:      gitInfo.URL,
+					})
+				}
+			}
 
-					result.Clusters = append(result.Clusters, info)
+			// 3. Per-cluster work (kubeconfig secret, probe, topology) runs on a
+			// bounded worker pool, each cluster under its own deadline.
+			sem := make(chan struct{}, concurrency)
+			var wg sync.WaitGroup
+			for i := range result.Clusters {
+				info := &result.Clusters[i]
+				if info.Kind == "KubeContext" && !includeTopology {
+					continue
 				}
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					sem <- struct{}{}
+					defer func() { <-sem }()
+
+					start := time.Now()
+					cctx, cancel := context.WithTimeout(ctx, clusterTimeout)
+					defer cancel()
+					scanOneCluster(cctx, info, secrets, includeTopology, namespace)
+					info.DurationMs = time.Since(start).Milliseconds()
+					if errors.Is(cctx.Err(), context.DeadlineExceeded) && ctx.Err() == nil {
+						info.addIssue(sourceDeadline, fmt.Errorf("cluster scan exceeded %s: %w", clusterTimeout, cctx.Err()))
+					}
+				}()
 			}
+			wg.Wait()
 
 			result.Total = len(result.Clusters)
 			if result.Total == 0 {
@@ -233,40 +338,46 @@ type gitRepoInfo struct {
 	URL  string
 }
 
-func findGitRepoForCluster(ctx context.Context, dyn dynamic.Interface, discovery interface {
+// listRepositories lists Porch Repositories on the management cluster.
+func listRepositories(ctx context.Context, dyn dynamic.Interface, discovery interface {
 	ServerPreferredResources() ([]*metav1.APIResourceList, error)
-}, clusterName string) gitRepoInfo {
+}) ([]unstructured.Unstructured, error) {
 	// Try to discover Repository GVR
 	gvr, namespaced, err := discoverRepositoryGVR(discovery)
 	if err != nil {
-		return gitRepoInfo{}
+		return nil, err
 	}
 
-	// List repositories
 	var ul *unstructured.UnstructuredList
 	if namespaced {
 		ul, err = dyn.Resource(gvr).Namespace(metav1.NamespaceAll).List(ctx, metav1.ListOptions{})
 	} else {
 		ul, err = dyn.Resource(gvr).List(ctx, metav1.ListOptions{})
 	}
-	if err != nil || ul == nil {
-		return gitRepoInfo{}
+	if err != nil {
+		return nil, fmt.Errorf("list repositories (gvr=%s): %w", gvr.String(), err)
 	}
+	return ul.Items, nil
+}
 
+// matchGitRepo finds the repository of a cluster by naming convention.
+func matchGitRepo(repos []unstructured.Unstructured, clusterName string) gitRepoInfo {
 	// Look for matching repository by name patterns
-	// Common patterns: "5g-{cluster}", "{cluster}-repo", etc.
+	// Common patterns: "<prefix>{cluster}", "{cluster}-repo", etc.
 	clusterLower := strings.ToLower(clusterName)
+	prefixLower := strings.ToLower(config.Current().Repos.Prefix)
 
-	for _, repo := range ul.Items {
+	for i := range repos {
+		repo := &repos[i]
 		repoName := repo.GetName()
 		repoNameLower := strings.ToLower(repoName)
 
 		// Check for common naming patterns
 		if strings.Contains(repoNameLower, clusterLower) ||
-			strings.HasPrefix(repoNameLower, "5g-"+clusterLower) ||
+			strings.HasPrefix(repoNameLower, prefixLower+clusterLower) ||
 			strings.HasPrefix(repoNameLower, clusterLower) {
 
-			url := extractRepoAddress(&repo)
+			url := extractRepoAddress(repo)
 			if url != "" {
 				return gitRepoInfo{
 					Name: repoName,
@@ -279,21 +390,59 @@ func findGitRepoForCluster(ctx context.Context, dyn dynamic.Interface, discovery
 	return gitRepoInfo{}
 }
 
-// scanClusterTopology connects to a cluster and scans for network topology
-func scanClusterTopology(ctx context.Context, clusterContext string, namespace string) (*ClusterNetworkInfo, error) {
-	// Build clients for the target cluster using context name
-	dyn, err := kube.BuildDynamicClient(clusterContext)
-	if err != nil {
-		return nil, fmt.Errorf("build dynamic client for %s: %w", clusterContext, err)
+// scanOneCluster fills in what needs a round trip for one cluster: the CAPI
+// kubeconfig secret and, if requested, reachability and topology.
+func scanOneCluster(ctx context.Context, info *ClusterTopologyInfo, secrets *kube.WorkloadClusterResolver, includeTopology bool, namespace string) {
+	switch info.Kind {
+	case "KubeContext":
+		c, err := kube.DefaultPool().Management(ctx, info.Name)
+		if err != nil {
+			info.addIssue(sourceClients, fmt.Errorf("build clients for %s: %w", info.Name, err))
+			return
+		}
+		scanReachableCluster(ctx, c, namespace, info)
+
+	case "CAPICluster":
+		sec, err := secrets.KubeconfigSecret(ctx, info.Namespace, info.Name)
+		if err != nil {
+			info.addIssue(sourceKubeconfigSecret, err)
+			return
+		}
+		info.KubeconfigSecret = info.Namespace + "/" + sec.Name
+		kubeBytes, err := kube.KubeconfigFromSecret(sec)
+		if err != nil {
+			info.addIssue(sourceKubeconfigSecret, invalidArgument(info.KubeconfigSecret, "%v", err))
+			return
+		}
+		info.APIServer = extractAPIServerFromKubeconfig(kubeBytes)
+		if !includeTopology {
+			return
+		}
+		wl, err := kube.DefaultPool().FromKubeconfig(ctx, kubeBytes)
+		if err != nil {
+			info.addIssue(sourceClients, fmt.Errorf("build clients: %w", err))
+			return
+		}
+		scanReachableCluster(ctx, wl, namespace, info)
 	}
-	cs, err := kube.BuildClientset(clusterContext)
-	if err != nil {
-		return nil, fmt.Errorf("build clientset for %s: %w", clusterContext, err)
+}
+
+// scanReachableCluster probes the cluster and scans its topology only when its
+// API server answers, so an unreachable cluster costs one dial timeout and is reported
+// instead of silently missing from the result.
+func scanReachableCluster(ctx context.Context, c *kube.Clients, namespace string, info *ClusterTopologyInfo) {
+	r := kube.DefaultPool().Probe(ctx, c)
+	info.Reachability = &r
+	if !r.Reachable {
+		info.Errors = append(info.Errors, ScanIssue{Source: sourceAPIServer, Code: CodeUnavailable, Message: "API server unreachable: " + r.Error})
+		return
 	}
-	return scanClusterTopologyWithClients(ctx, dyn, cs, namespace)
+	info.NetworkInfo = scanClusterTopologyWithClients(ctx, c.Dynamic, c.Typed, namespace, info.addIssue)
 }
 
-func scanClusterTopologyWithClients(ctx context.Context, dyn dynamic.Interface, cs *kubernetes.Clientset, namespace string) (*ClusterNetworkInfo, error) {
+// scanClusterTopologyWithClients reads what it can; sources that fail are passed
+// to report and skipped.
+func scanClusterTopologyWithClients(ctx context.Context, dyn dynamic.Interface, cs *kubernetes.Clientset, namespace string, report func(source string, err error)) *ClusterNetworkInfo {
 	netInfo := &ClusterNetworkInfo{
 		NetworkInterfaces: []NetworkInterface{},
 		AllCIDRs:          []string{},
@@ -316,14 +465,10 @@ func scanClusterTopologyWithClients(ctx context.Context, dyn dynamic.Interface,
 	}
 
 	nadList, err := dyn.Resource(nadGVR).Namespace(ns).List(ctx, metav1.ListOptions{})
-	if err == nil && nadList != nil {
-		for _, nad := range nadList.Items {
-			// Extract network topology from NAD
-			ifaces := extractNetworkInterfaces(nad.Object)
-			netInfo.NetworkInterfaces = append(netInfo.NetworkInterfaces, ifaces...)
-
-			// Also extract flat CIDRs/IPs
-			cidrs, ips := extractAllCIDRsAndIPv4Strings(nad.Object)
+	if err != nil {
+		report(sourceNADs, err)
+	} else {
+		addFlat := func(cidrs, ips []string) {
 			for _, c := range cidrs {
 				if !seenCIDR[c] {
 					netInfo.AllCIDRs = append(netInfo.AllCIDRs, c)
@@ -336,26 +481,19 @@ func scanClusterTopologyWithClients(ctx context.Context, dyn dynamic.Interface,
 					seenIP[ip] = true
 				}
 			}
-
-			// Parse spec.config JSON for additional info
-			spec, _, _ := unstructured.NestedMap(nad.Object, "spec")
-			if cfg, ok := spec["config"].(string); ok && strings.TrimSpace(cfg) != "" {
-				if jm, ok := tryParseJSONConfigString(cfg); ok {
-					c2, i2 := extractAllCIDRsAndIPv4Strings(jm)
-					for _, c := range c2 {
-						if !seenCIDR[c] {
-							netInfo.AllCIDRs = append(netInfo.AllCIDRs, c)
-							seenCIDR[c] = true
-						}
-					}
-					for _, ip := range i2 {
-						if !seenIP[ip] {
-							netInfo.AllIPs = append(netInfo.AllIPs, ip)
-							seenIP[ip] = true
-						}
-					}
-				}
+		}
+		for _, nad := range nadList.Items {
+			// Typed CNI config: addresses, ranges, gateways and routes kept apart
+			if iface, ok := nadNetworkInterface(nad.Object); ok {
+				netInfo.NetworkInterfaces = append(netInfo.NetworkInterfaces, iface)
+				addFlat(nadCIDRsAndIPs(iface))
+				continue
 			}
+
+			// Unparsable spec.config: extract what looks like addresses
+			ifaces := extractNetworkInterfaces(nad.Object)
+			netInfo.NetworkInterfaces = append(netInfo.NetworkInterfaces, ifaces...)
+			addFlat(extractAllCIDRsAndIPs(nad.Object))
 		}
 	}
 
@@ -367,12 +505,14 @@ func scanClusterTopologyWithClients(ctx context.Context, dyn dynamic.Interface,
 	}
 
 	nfList, err := dyn.Resource(nfConfigGVR).Namespace(ns).List(ctx, metav1.ListOptions{})
-	if err == nil && nfList != nil {
+	if err != nil {
+		report(sourceNFConfigs, err)
+	} else {
 		for _, nf := range nfList.Items {
 			ifaces := extractNetworkInterfaces(nf.Object)
 			netInfo.NetworkInterfaces = append(netInfo.NetworkInterfaces, ifaces...)
 
-			cidrs, ips := extractAllCIDRsAndIPv4Strings(nf.Object)
+			cidrs, ips := extractAllCIDRsAndIPs(nf.Object)
 			for _, c := range cidrs {
 				if !seenCIDR[c] {
 					netInfo.AllCIDRs = append(netInfo.AllCIDRs, c)
@@ -388,10 +528,13 @@ func scanClusterTopologyWithClients(ctx context.Context, dyn dynamic.Interface,
 		}
 	}
 
+	// Live attachments (Multus network-status) against declared NFDeployments
+	netInfo.Pods, netInfo.NFs = scanLiveNetworks(ctx, dyn, cs, ns, report)
+
 	// Cluster-level CIDRs (pod/service) best effort
-	podCIDRs, svcCIDRs := getClusterCIDRs(ctx, cs)
-	netInfo.PodCIDRs = podCIDRs
-	netInfo.ServiceCIDRs = svcCIDRs
+	cidrs := getClusterCIDRs(ctx, dyn, cs, report)
+	netInfo.PodCIDRs, netInfo.PodCIDRSource = cidrs.pod, cidrs.podSource
+	netInfo.ServiceCIDRs, netInfo.ServiceCIDRSource = cidrs.service, cidrs.serviceSource
 
 	// Sort results
 	sort.Strings(netInfo.AllCIDRs)
@@ -404,6 +547,9 @@ func scanClusterTopologyWithClients(ctx context.Context, dyn dynamic.Interface,
 			// Merge CIDRs and IPs
 			existing.CIDRs = append(existing.CIDRs, iface.CIDRs...)
 			existing.IPs = append(existing.IPs, iface.IPs...)
+			if existing.NAD == nil {
+				existing.NAD = iface.NAD
+			}
 			ifaceMap[iface.Name] = existing
 		} else {
 			ifaceMap[iface.Name] = iface
@@ -412,114 +558,12 @@ func scanClusterTopologyWithClients(ctx context.Context, dyn dynamic.Interface,
 
 	netInfo.NetworkInterfaces = make([]NetworkInterface, 0, len(ifaceMap))
 	for _, iface := range ifaceMap {
-		sort.Strings(iface.CIDRs)
-		sort.Strings(iface.IPs)
+		finishInterface(&iface)
 		netInfo.NetworkInterfaces = append(netInfo.NetworkInterfaces, iface)
 	}
 	sort.Slice(netInfo.NetworkInterfaces, func(i, j int) bool {
 		return netInfo.NetworkInterfaces[i].Name < netInfo.NetworkInterfaces[j].Name
 	})
 
-	return netInfo, nil
-}
-
-func extractKubeconfigFromSecret(sec *corev1.Secret) []byte {
-	// Common keys: "value" (most CAPI), sometimes "kubeconfig"
-	var kubeBytes []byte
-	if b, ok := sec.Data["value"]; ok && len(b) > 0 {
-		kubeBytes = b
-	} else if b, ok := sec.Data["kubeconfig"]; ok && len(b) > 0 {
-		kubeBytes = b
-	} else if s, ok := sec.StringData["value"]; ok && s != "" {
-		kubeBytes = []byte(s)
-	} else if s, ok := sec.StringData["kubeconfig"]; ok && s != "" {
-		kubeBytes = []byte(s)
-	}
-
-	// Some secrets might store base64 string in Data (rare)
-	if len(kubeBytes) > 0 && looksBase64(kubeBytes) {
-		if dec, e := base64.StdEncoding.DecodeString(strings.TrimSpace(string(kubeBytes))); e == nil {
-			kubeBytes = dec
-		}
-	}
-
-	return kubeBytes
-}
-
-// clientsFromKubeconfigBytes builds dynamic and typed clients from raw kubeconfig bytes.
-func clientsFromKubeconfigBytes(kubeconfig []byte) (dynamic.Interface, *kubernetes.Clientset, error) {
-	if len(kubeconfig) == 0 {
-		return nil, nil, fmt.Errorf("empty kubeconfig bytes")
-	}
-	restCfg, err := clientcmd.RESTConfigFromKubeConfig(kubeconfig)
-	if err != nil {
-		return nil, nil, fmt.Errorf("parse kubeconfig: %w", err)
-	}
-	dyn, err := dynamic.NewForConfig(restCfg)
-	if err != nil {
-		return nil, nil, fmt.Errorf("dynamic client: %w", err)
-	}
-	cs, err := kubernetes.NewForConfig(restCfg)
-	if err != nil {
-		return nil, nil, fmt.Errorf("clientset: %w", err)
-	}
-	return dyn, cs, nil
-}
-
-// getClusterCIDRs extracts pod CIDRs from Nodes and service CIDRs from kube-proxy config (best effort).
-func getClusterCIDRs(ctx context.Context, cs *kubernetes.Clientset) (podCIDRs []string, serviceCIDRs []string) {
-	podSeen := map[string]bool{}
-	if cs != nil {
-		if nl, err := cs.CoreV1().Nodes().List(ctx, metav1.ListOptions{}); err == nil {
-			for i := range nl.Items {
-				n := &nl.Items[i]
-				if c := strings.TrimSpace(n.Spec.PodCIDR); c != "" && !podSeen[c] {
-					podSeen[c] = true
-					podCIDRs = append(podCIDRs, c)
-				}
-				for _, c := range n.Spec.PodCIDRs {
-					c = strings.TrimSpace(c)
-					if c != "" && !podSeen[c] {
-						podSeen[c] = true
-						podCIDRs = append(podCIDRs, c)
-					}
-				}
-			}
-		}
-	}
-
-	svcSeen := map[string]bool{}
-	if cs != nil {
-		if cm, err := cs.CoreV1().ConfigMaps("kube-system").Get(ctx, "kube-proxy", metav1.GetOptions{}); err == nil && cm != nil {
-			for _, key := range []string{"config.conf", "kube-proxy.conf"} {
-				if raw, ok := cm.Data[key]; ok && strings.TrimSpace(raw) != "" {
-					var m map[string]any
-					if yamlErr := yaml.Unmarshal([]byte(raw), &m); yamlErr == nil {
-						if c, ok := m["clusterCIDR"].(string); ok {
-							c = strings.TrimSpace(c)
-							if c != "" && !svcSeen[c] {
-								svcSeen[c] = true
-								serviceCIDRs = append(serviceCIDRs, c)
-							}
-						}
-						if arr, ok := m["clusterCIDRs"].([]any); ok {
-							for _, v := range arr {
-								if s, ok := v.(string); ok {
-									s = strings.TrimSpace(s)
-									if s != "" && !svcSeen[s] {
-										svcSeen[s] = true
-										serviceCIDRs = append(serviceCIDRs, s)
-									}
-								}
-							}
-						}
-					}
-				}
-			}
-		}
-	}
-
-	sort.Strings(podCIDRs)
-	sort.Strings(serviceCIDRs)
-	return podCIDRs, serviceCIDRs
+	return netInfo
 }
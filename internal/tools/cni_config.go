@@ -0,0 +1,418 @@
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// CNIConfig is a NetworkAttachmentDefinition spec.config: a single plugin
+// config or a conflist with plugins[]. Fields the model does not know are kept
+// and written back unchanged by Marshal.
+type CNIConfig struct {
+	CNIVersion string       `json:"cniVersion,omitempty"`
+	Name       string       `json:"name,omitempty"`
+	Plugins    []*CNIPlugin `json:"-"` // a single config is its only plugin
+
+	conflist bool
+	raw      map[string]any
+}
+
+// CNIPlugin is one plugin of a CNI config. Which fields are set depends on
+// Type: master/mode for macvlan and ipvlan, device for host-device, bridge for
+// bridge, vlan for sriov and bridge.
+type CNIPlugin struct {
+	Type   string   `json:"type,omitempty"`
+	Master string   `json:"master,omitempty"`
+	Device string   `json:"device,omitempty"`
+	Bridge string   `json:"bridge,omitempty"`
+	Mode   string   `json:"mode,omitempty"`
+	VLAN   int      `json:"vlan,omitempty"`
+	MTU    int      `json:"mtu,omitempty"`
+	IPAM   *CNIIPAM `json:"-"`
+
+	raw map[string]any
+}
+
+// CNIIPAM is the ipam section of a plugin (static, whereabouts, host-local).
+type CNIIPAM struct {
+	Type string `json:"type,omitempty"`
+
+	// static
+	Addresses []CNIAddress `json:"addresses,omitempty"`
+
+	// whereabouts: one range inline, or several in ipRanges
+	Range      string          `json:"range,omitempty"`
+	RangeStart string          `json:"range_start,omitempty"`
+	RangeEnd   string          `json:"range_end,omitempty"`
+	Exclude    []string        `json:"exclude,omitempty"`
+	IPRanges   []CNIWhereRange `json:"ipRanges,omitempty"`
+
+	// host-local: range sets, or the legacy single subnet
+	Ranges  [][]CNIHostRange `json:"ranges,omitempty"`
+	Subnet  string           `json:"subnet,omitempty"`
+	Start   string           `json:"rangeStart,omitempty"`
+	End     string           `json:"rangeEnd,omitempty"`
+	Gateway string           `json:"gateway,omitempty"` // whereabouts and legacy host-local
+
+	Routes []CNIRoute `json:"routes,omitempty"`
+
+	raw map[string]any
+}
+
+type CNIAddress struct {
+	Address string `json:"address"`
+	Gateway string `json:"gateway,omitempty"`
+}
+
+type CNIWhereRange struct {
+	Range      string   `json:"range"`
+	RangeStart string   `json:"range_start,omitempty"`
+	RangeEnd   string   `json:"range_end,omitempty"`
+	Exclude    []string `json:"exclude,omitempty"`
+	Gateway    string   `json:"gateway,omitempty"`
+}
+
+type CNIHostRange struct {
+	Subnet     string `json:"subnet"`
+	RangeStart string `json:"rangeStart,omitempty"`
+	RangeEnd   string `json:"rangeEnd,omitempty"`
+	Gateway    string `json:"gateway,omitempty"`
+}
+
+type CNIRoute struct {
+	Dst string `json:"dst"`
+	GW  string `json:"gw,omitempty"`
+}
+
+// parseCNIConfig parses a spec.config string (also double-encoded).
+func parseCNIConfig(s string) (*CNIConfig, error) {
+	m, ok := tryParseJSONConfigString(s)
+	if !ok {
+		return nil, fmt.Errorf("spec.config is not a JSON object")
+	}
+	c := &CNIConfig{raw: m}
+	if err := fromMap(m, c); err != nil {
+		return nil, err
+	}
+	if list, ok := m["plugins"].([]any); ok {
+		c.conflist = true
+		for i, p := range list {
+			pm, ok := p.(map[string]any)
+			if !ok {
+				return nil, fmt.Errorf("plugins[%d] is not an object", i)
+			}
+			pl, err := parseCNIPlugin(pm)
+			if err != nil {
+				return nil, fmt.Errorf("plugins[%d]: %w", i, err)
+			}
+			c.Plugins = append(c.Plugins, pl)
+		}
+		return c, nil
+	}
+	pl, err := parseCNIPlugin(m)
+	if err != nil {
+		return nil, err
+	}
+	c.Plugins = []*CNIPlugin{pl}
+	return c, nil
+}
+
+func parseCNIPlugin(m map[string]any) (*CNIPlugin, error) {
+	p := &CNIPlugin{raw: m}
+	if err := fromMap(m, p); err != nil {
+		return nil, err
+	}
+	if im, ok := m["ipam"].(map[string]any); ok {
+		p.IPAM = &CNIIPAM{raw: im}
+		if err := fromMap(im, p.IPAM); err != nil {
+			return nil, fmt.Errorf("ipam: %w", err)
+		}
+	}
+	return p, nil
+}
+
+// Marshal serializes the config with the typed fields applied over the
+// original JSON.
+func (c *CNIConfig) Marshal() (string, error) {
+	var m map[string]any
+	if c.conflist {
+		m = overlay(c.raw, c)
+		plugins := make([]any, 0, len(c.Plugins))
+		for _, p := range c.Plugins {
+			plugins = append(plugins, p.toMap())
+		}
+		m["plugins"] = plugins
+	} else {
+		var p *CNIPlugin
+		if len(c.Plugins) > 0 {
+			p = c.Plugins[0]
+		} else {
+			p = &CNIPlugin{raw: c.raw}
+		}
+		m = overlay(p.toMap(), c)
+	}
+	b, err := json.Marshal(m)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+func (p *CNIPlugin) toMap() map[string]any {
+	m := overlay(p.raw, p)
+	if p.IPAM != nil {
+		m["ipam"] = overlay(p.IPAM.raw, p.IPAM)
+	} else {
+		delete(m, "ipam")
+	}
+	return m
+}
+
+// fromMap decodes m into the json-tagged fields of v.
+func fromMap(m map[string]any, v any) error {
+	b, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+// overlay returns a copy of raw with the json-tagged fields of v applied:
+// set fields replace raw values, zero (omitted) fields remove them.
+func overlay(raw map[string]any, v any) map[string]any {
+	out := make(map[string]any, len(raw))
+	for k, val := range raw {
+		out[k] = val
+	}
+	for _, k := range jsonKeys(v) {
+		delete(out, k)
+	}
+	var typed map[string]any
+	b, _ := json.Marshal(v)
+	_ = json.Unmarshal(b, &typed)
+	for k, val := range typed {
+		out[k] = val
+	}
+	return out
+}
+
+// jsonKeys lists the json names of the serialized fields of the struct v
+// points to.
+func jsonKeys(v any) []string {
+	t := reflect.TypeOf(v).Elem()
+	var keys []string
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if !f.IsExported() || name == "" || name == "-" {
+			continue
+		}
+		keys = append(keys, name)
+	}
+	return keys
+}
+
+// ---------------- NetworkInterface view ----------------
+
+// NADNetwork is what a NAD's CNI config says about the network, in
+// NetworkInterface.NAD.
+type NADNetwork struct {
+	Plugins  []string   `json:"plugins,omitempty"` // plugin types in chain order
+	Master   string     `json:"master,omitempty"`  // parent interface (master, device or bridge)
+	VLAN     int        `json:"vlan,omitempty"`
+	IPAMType string     `json:"ipamType,omitempty"`
+	Ranges   []IPRange  `json:"ranges,omitempty"`
+	Exclude  []string   `json:"exclude,omitempty"`
+	Gateways []string   `json:"gateways,omitempty"`
+	Routes   []CNIRoute `json:"routes,omitempty"`
+}
+
+// IPRange is a pool IPAM allocates from (whereabouts, host-local).
+type IPRange struct {
+	Range      string `json:"range"`
+	RangeStart string `json:"rangeStart,omitempty"`
+	RangeEnd   string `json:"rangeEnd,omitempty"`
+}
+
+// Summary flattens the plugin chain: the first plugin with a parent interface
+// gives master and VLAN, the first with ipam gives the addressing.
+func (c *CNIConfig) Summary() NADNetwork {
+	var n NADNetwork
+	var ipam *CNIIPAM
+	for _, p := range c.Plugins {
+		n.Plugins = append(n.Plugins, p.Type)
+		if n.Master == "" {
+			for _, m := range []string{p.Master, p.Device, p.Bridge} {
+				if m != "" {
+					n.Master = m
+					break
+				}
+			}
+			if n.VLAN == 0 {
+				n.VLAN = p.VLAN
+			}
+			if n.VLAN == 0 && p.Master != "" {
+				// macvlan/ipvlan on a VLAN sub-interface: eth1.100
+				if _, tag, ok := strings.Cut(p.Master, "."); ok {
+					n.VLAN, _ = strconv.Atoi(tag)
+				}
+			}
+		}
+		if ipam == nil && p.IPAM != nil {
+			ipam = p.IPAM
+		}
+	}
+	if ipam == nil {
+		return n
+	}
+	n.IPAMType = ipam.Type
+	n.Routes = ipam.Routes
+	n.Exclude = append(n.Exclude, ipam.Exclude...)
+	addGw := func(g string) {
+		if g = strings.TrimSpace(g); g != "" {
+			n.Gateways = append(n.Gateways, g)
+		}
+	}
+	for _, a := range ipam.Addresses {
+		addGw(a.Gateway)
+	}
+	if ipam.Range != "" {
+		n.Ranges = append(n.Ranges, IPRange{Range: ipam.Range, RangeStart: ipam.RangeStart, RangeEnd: ipam.RangeEnd})
+	}
+	for _, r := range ipam.IPRanges {
+		n.Ranges = append(n.Ranges, IPRange{Range: r.Range, RangeStart: r.RangeStart, RangeEnd: r.RangeEnd})
+		n.Exclude = append(n.Exclude, r.Exclude...)
+		addGw(r.Gateway)
+	}
+	if ipam.Subnet != "" {
+		n.Ranges = append(n.Ranges, IPRange{Range: ipam.Subnet, RangeStart: ipam.Start, RangeEnd: ipam.End})
+	}
+	for _, set := range ipam.Ranges {
+		for _, r := range set {
+			n.Ranges = append(n.Ranges, IPRange{Range: r.Subnet, RangeStart: r.RangeStart, RangeEnd: r.RangeEnd})
+			addGw(r.Gateway)
+		}
+	}
+	addGw(ipam.Gateway)
+	n.Gateways = dedupSorted(n.Gateways)
+	n.Exclude = dedupSorted(n.Exclude)
+	return n
+}
+
+// staticAddresses returns the static IPAM addresses of the chain.
+func (c *CNIConfig) staticAddresses() []CNIAddress {
+	for _, p := range c.Plugins {
+		if p.IPAM != nil && len(p.IPAM.Addresses) > 0 {
+			return p.IPAM.Addresses
+		}
+	}
+	return nil
+}
+
+// nadNetworkInterface describes a NAD from its typed CNI config: the
+// interface is named after the NAD, CIDRs are its static addresses and IPAM
+// ranges, IPs the static addresses, and gateways and routes are kept apart.
+// ok is false when spec.config does not parse.
+func nadNetworkInterface(obj map[string]any) (NetworkInterface, bool) {
+	cfgStr, _, _ := unstructured.NestedString(obj, "spec", "config")
+	cfg, err := parseCNIConfig(cfgStr)
+	if err != nil {
+		return NetworkInterface{}, false
+	}
+	name, _, _ := unstructured.NestedString(obj, "metadata", "name")
+	if name == "" {
+		name = cfg.Name
+	}
+	sum := cfg.Summary()
+	iface := NetworkInterface{Name: name, NAD: &sum}
+	for _, a := range cfg.staticAddresses() {
+		cidrs, ips := findAddrs(a.Address)
+		iface.CIDRs = append(iface.CIDRs, cidrs...)
+		iface.IPs = append(iface.IPs, ips...)
+	}
+	for _, r := range sum.Ranges {
+		if isCIDR(r.Range) {
+			iface.CIDRs = append(iface.CIDRs, r.Range)
+		}
+	}
+	finishInterface(&iface)
+	return iface, true
+}
+
+// nadCIDRsAndIPs is the flat form of nadNetworkInterface for the allCidrs and
+// allIps lists: route destinations are not included, gateways are.
+func nadCIDRsAndIPs(iface NetworkInterface) (cidrs, ips []string) {
+	cidrs = append(cidrs, iface.CIDRs...)
+	ips = append(ips, iface.IPs...)
+	if iface.NAD != nil {
+		ips = append(ips, iface.NAD.Gateways...)
+	}
+	sort.Strings(ips)
+	return cidrs, dedupSorted(ips)
+}
+
+// networkNameMatches reports whether a NAD or CNI network name belongs to the
+// interface iface: equal, or <prefix>-<iface> as in cucp-n2.
+func networkNameMatches(name, iface string) bool {
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:] // namespace/name reference
+	}
+	return iface != "" && (name == iface || strings.HasSuffix(name, "-"+iface))
+}
+
+// addrsForNetwork picks the newIps entry of the network called name: an exact
+// match, else the longest interface name it ends with.
+func addrsForNetwork(name string, newIPs map[string]ifaceAddrs) (ifaceAddrs, bool) {
+	if ip, ok := newIPs[name]; ok {
+		return ip, true
+	}
+	best := ""
+	for iface := range newIPs {
+		if networkNameMatches(name, iface) && len(iface) > len(best) {
+			best = iface
+		}
+	}
+	if best == "" {
+		return ifaceAddrs{}, false
+	}
+	return newIPs[best], true
+}
+
+// setStaticAddresses rewrites the static IPAM addresses and gateways of the
+// chain per family, adding an address of a family the config does not have yet
+// (IPv4-only NAD becoming dual-stack).
+func (c *CNIConfig) setStaticAddresses(ip ifaceAddrs) bool {
+	for _, p := range c.Plugins {
+		if p.IPAM == nil || (p.IPAM.Type != "static" && len(p.IPAM.Addresses) == 0) {
+			continue
+		}
+		changed := false
+		have := map[string]bool{}
+		for i := range p.IPAM.Addresses {
+			a := &p.IPAM.Addresses[i]
+			fam := addrFamily(a.Address)
+			have[fam] = true
+			want := ip.forFamily(fam)
+			if want.Address != "" && a.Address != want.Address {
+				a.Address, changed = want.Address, true
+			}
+			if want.Gateway != "" && a.Gateway != want.Gateway {
+				a.Gateway, changed = want.Gateway, true
+			}
+		}
+		for _, fam := range []string{familyIPv4, familyIPv6} {
+			if want := ip.forFamily(fam); !have[fam] && want.Address != "" {
+				p.IPAM.Addresses = append(p.IPAM.Addresses, CNIAddress{Address: want.Address, Gateway: want.Gateway})
+				changed = true
+			}
+		}
+		return changed
+	}
+	return false
+}
@@ -0,0 +1,497 @@
+package tools
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Formats of configuration embedded in a manifest string.
+const (
+	formatJSON      = "json"
+	formatYAML      = "yaml"
+	formatLibconfig = "libconfig" // OAI gNB/CU/DU .conf
+)
+
+// confLeaf is a scalar setting of an embedded configuration and the byte span
+// of its value as written, quotes included.
+type confLeaf struct {
+	path       []string // keys, and list indices as "[i]"
+	start, end int
+	quote      byte // '"' or '\'' for a quoted string, 0 otherwise
+	editable   bool // the span can be rewritten in place
+	yamlStr    bool // YAML only: the value is a string
+}
+
+func (l confLeaf) pathString() string {
+	p := ""
+	for _, seg := range l.path {
+		if strings.HasPrefix(seg, "[") {
+			p += seg
+		} else {
+			p = pathKey(p, seg)
+		}
+	}
+	return p
+}
+
+// parseEmbedded detects the format of s, JSON, libconfig or a YAML mapping
+// in that order, and returns its leaves; ok is false for anything else.
+func parseEmbedded(s string) (format string, leaves []confLeaf, ok bool) {
+	if t := strings.TrimSpace(s); strings.HasPrefix(t, "{") || strings.HasPrefix(t, "[") {
+		if leaves, err := jsonLeaves(s); err == nil {
+			return formatJSON, leaves, true
+		}
+	}
+	if leaves, err := libconfigLeaves(s); err == nil && len(leaves) > 0 {
+		return formatLibconfig, leaves, true
+	}
+	if leaves, err := yamlLeaves(s); err == nil && len(leaves) > 0 {
+		return formatYAML, leaves, true
+	}
+	return "", nil, false
+}
+
+// leafAt returns the path of the leaf holding offset off, else of the last
+// leaf starting before it (YAML block scalars have no span).
+func leafAt(leaves []confLeaf, off int) (string, bool) {
+	best := -1
+	for i, l := range leaves {
+		if l.start <= off && off < l.end {
+			return l.pathString(), true
+		}
+		if l.start <= off {
+			best = i
+		}
+	}
+	if best < 0 {
+		return "", false
+	}
+	return leaves[best].pathString(), true
+}
+
+// ---- libconfig ----
+
+type libconfigParser struct {
+	s      string
+	i      int
+	leaves []confLeaf
+}
+
+var errLibconfig = errors.New("not libconfig")
+
+// libconfigLeaves parses libconfig settings (name = value; groups { },
+// lists ( ), arrays [ ], #, // and /* */ comments, @include lines).
+func libconfigLeaves(s string) ([]confLeaf, error) {
+	p := &libconfigParser{s: s}
+	if err := p.settings(nil, 0); err != nil {
+		return nil, err
+	}
+	return p.leaves, nil
+}
+
+func (p *libconfigParser) skip() {
+	for p.i < len(p.s) {
+		switch c := p.s[p.i]; {
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n':
+			p.i++
+		case c == '#' || c == '@' || strings.HasPrefix(p.s[p.i:], "//"):
+			for p.i < len(p.s) && p.s[p.i] != '\n' {
+				p.i++
+			}
+		case strings.HasPrefix(p.s[p.i:], "/*"):
+			if j := strings.Index(p.s[p.i+2:], "*/"); j >= 0 {
+				p.i += j + 4
+			} else {
+				p.i = len(p.s)
+			}
+		default:
+			return
+		}
+	}
+}
+
+func isLibconfigNameChar(c byte, first bool) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '*' ||
+		!first && (c >= '0' && c <= '9' || c == '_' || c == '-')
+}
+
+func (p *libconfigParser) settings(path []string, close byte) error {
+	for {
+		p.skip()
+		if p.i >= len(p.s) {
+			if close != 0 {
+				return errLibconfig
+			}
+			return nil
+		}
+		if close != 0 && p.s[p.i] == close {
+			p.i++
+			return nil
+		}
+		start := p.i
+		for p.i < len(p.s) && isLibconfigNameChar(p.s[p.i], p.i == start) {
+			p.i++
+		}
+		if p.i == start {
+			return errLibconfig
+		}
+		name := p.s[start:p.i]
+		p.skip()
+		if p.i >= len(p.s) || p.s[p.i] != '=' && p.s[p.i] != ':' {
+			return errLibconfig
+		}
+		p.i++
+		if err := p.value(append(append([]string(nil), path...), name)); err != nil {
+			return err
+		}
+		p.skip()
+		if p.i < len(p.s) && (p.s[p.i] == ';' || p.s[p.i] == ',') {
+			p.i++
+		}
+	}
+}
+
+func (p *libconfigParser) value(path []string) error {
+	p.skip()
+	if p.i >= len(p.s) {
+		return errLibconfig
+	}
+	switch c := p.s[p.i]; c {
+	case '{':
+		p.i++
+		return p.settings(path, '}')
+	case '(', '[':
+		close := byte(')')
+		if c == '[' {
+			close = ']'
+		}
+		p.i++
+		for n := 0; ; n++ {
+			p.skip()
+			if p.i < len(p.s) && p.s[p.i] == close {
+				p.i++
+				return nil
+			}
+			if err := p.value(append(append([]string(nil), path...), "["+strconv.Itoa(n)+"]")); err != nil {
+				return err
+			}
+			p.skip()
+			if p.i < len(p.s) && p.s[p.i] == ',' {
+				p.i++
+			}
+		}
+	case '"':
+		start := p.i
+		for {
+			// adjacent strings are concatenated
+			p.i++
+			for p.i < len(p.s) && p.s[p.i] != '"' {
+				if p.s[p.i] == '\\' {
+					p.i++
+				}
+				p.i++
+			}
+			if p.i >= len(p.s) {
+				return errLibconfig
+			}
+			p.i++
+			end := p.i
+			p.skip()
+			if p.i >= len(p.s) || p.s[p.i] != '"' {
+				p.i = end
+				break
+			}
+		}
+		p.leaves = append(p.leaves, confLeaf{path: path, start: start, end: p.i, quote: '"', editable: true})
+		return nil
+	}
+	start := p.i
+	for p.i < len(p.s) && !strings.ContainsRune(" \t\r\n,;)]}#/", rune(p.s[p.i])) {
+		p.i++
+	}
+	if !libconfigScalar.MatchString(p.s[start:p.i]) {
+		return errLibconfig
+	}
+	p.leaves = append(p.leaves, confLeaf{path: path, start: start, end: p.i, editable: true})
+	return nil
+}
+
+var libconfigScalar = regexp.MustCompile(`^(?i:true|false|[-+]?(0x[0-9a-f]+|[0-9]+(\.[0-9]*)?(e[-+]?[0-9]+)?|\.[0-9]+(e[-+]?[0-9]+)?)l{0,2})$`)
+
+// ---- JSON ----
+
+// jsonLeaves returns the scalar values of a JSON document.
+func jsonLeaves(s string) ([]confLeaf, error) {
+	type frame struct {
+		array     bool
+		key       string
+		index     int
+		expectKey bool
+	}
+	var stack []*frame
+	var leaves []confLeaf
+	pathOf := func() []string {
+		p := make([]string, 0, len(stack))
+		for _, f := range stack {
+			if f.array {
+				p = append(p, "["+strconv.Itoa(f.index)+"]")
+			} else {
+				p = append(p, f.key)
+			}
+		}
+		return p
+	}
+	next := func() {
+		if len(stack) == 0 {
+			return
+		}
+		if f := stack[len(stack)-1]; f.array {
+			f.index++
+		} else {
+			f.expectKey = true
+		}
+	}
+	dec := json.NewDecoder(strings.NewReader(s))
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			if errors.Is(err, io.EOF) && len(stack) == 0 {
+				return leaves, nil
+			}
+			return nil, err
+		}
+		end := int(dec.InputOffset())
+		switch t := tok.(type) {
+		case json.Delim:
+			switch t {
+			case '{':
+				stack = append(stack, &frame{expectKey: true})
+			case '[':
+				stack = append(stack, &frame{array: true})
+			default:
+				stack = stack[:len(stack)-1]
+				next()
+			}
+			continue
+		case string:
+			if len(stack) > 0 && !stack[len(stack)-1].array && stack[len(stack)-1].expectKey {
+				stack[len(stack)-1].key, stack[len(stack)-1].expectKey = t, false
+				continue
+			}
+		}
+		l := confLeaf{path: pathOf(), end: end, editable: true}
+		if _, ok := tok.(string); ok {
+			l.quote = '"'
+			l.start = strings.LastIndexByte(s[:end-1], '"')
+			for l.start > 0 && s[l.start-1] == '\\' {
+				l.start = strings.LastIndexByte(s[:l.start-1], '"')
+			}
+		} else {
+			l.start = jsonTokenStart(s, end)
+		}
+		leaves = append(leaves, l)
+		next()
+	}
+}
+
+// jsonTokenStart finds the start of the number, true, false or null ending
+// at end.
+func jsonTokenStart(s string, end int) int {
+	i := end
+	for i > 0 && !strings.ContainsRune(" \t\r\n:,[{", rune(s[i-1])) {
+		i--
+	}
+	return i
+}
+
+// ---- YAML ----
+
+// yamlLeaves returns the scalars of a YAML mapping document. Only plain and
+// single-line quoted scalars are editable in place.
+func yamlLeaves(s string) ([]confLeaf, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal([]byte(s), &doc); err != nil {
+		return nil, err
+	}
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("not a YAML mapping")
+	}
+	lineStarts := []int{0}
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\n' {
+			lineStarts = append(lineStarts, i+1)
+		}
+	}
+	var leaves []confLeaf
+	var walk func(n *yaml.Node, path []string)
+	walk = func(n *yaml.Node, path []string) {
+		switch n.Kind {
+		case yaml.DocumentNode:
+			for _, c := range n.Content {
+				walk(c, path)
+			}
+		case yaml.MappingNode:
+			for i := 0; i+1 < len(n.Content); i += 2 {
+				walk(n.Content[i+1], append(append([]string(nil), path...), n.Content[i].Value))
+			}
+		case yaml.SequenceNode:
+			for i, c := range n.Content {
+				walk(c, append(append([]string(nil), path...), "["+strconv.Itoa(i)+"]"))
+			}
+		case yaml.ScalarNode:
+			if n.Line-1 >= len(lineStarts) {
+				return
+			}
+			l := confLeaf{path: path, start: lineStarts[n.Line-1] + n.Column - 1, yamlStr: n.Tag == "!!str"}
+			l.end = l.start
+			switch {
+			case n.Style&yaml.DoubleQuotedStyle != 0:
+				l.quote = '"'
+				l.end, l.editable = quotedEnd(s, l.start, '"')
+			case n.Style&yaml.SingleQuotedStyle != 0:
+				l.quote = '\''
+				l.end, l.editable = quotedEnd(s, l.start, '\'')
+			case n.Style&(yaml.LiteralStyle|yaml.FoldedStyle) == 0 && strings.HasPrefix(s[min(l.start, len(s)):], n.Value):
+				l.end, l.editable = l.start+len(n.Value), true
+			}
+			leaves = append(leaves, l)
+		}
+	}
+	walk(&doc, nil)
+	return leaves, nil
+}
+
+// quotedEnd returns the end of the quoted scalar starting at s[start], when
+// it closes on the same line.
+func quotedEnd(s string, start int, q byte) (int, bool) {
+	if start >= len(s) || s[start] != q {
+		return start, false
+	}
+	for i := start + 1; i < len(s) && s[i] != '\n'; i++ {
+		switch {
+		case q == '"' && s[i] == '\\':
+			i++
+		case s[i] == q && q == '\'' && i+1 < len(s) && s[i+1] == '\'':
+			i++
+		case s[i] == q:
+			return i + 1, true
+		}
+	}
+	return start, false
+}
+
+// ---- key paths ----
+
+// splitKeyPath splits a key path (gNBs[0].amf_ip_address.ipv4,
+// data["du.conf"]) into keys and "[i]" indices.
+func splitKeyPath(p string) []string {
+	var out []string
+	for i := 0; i < len(p); {
+		switch {
+		case p[i] == '.':
+			i++
+		case strings.HasPrefix(p[i:], `["`):
+			j := strings.Index(p[i+2:], `"]`)
+			if j < 0 {
+				return append(out, p[i:])
+			}
+			if k, err := strconv.Unquote(p[i+1 : i+2+j+1]); err == nil {
+				out = append(out, k)
+			} else {
+				out = append(out, p[i+2:i+2+j])
+			}
+			i += j + 4
+		case p[i] == '[':
+			j := strings.IndexByte(p[i:], ']')
+			if j < 0 {
+				return append(out, p[i:])
+			}
+			out = append(out, p[i:i+j+1])
+			i += j + 1
+		default:
+			j := i
+			for j < len(p) && p[j] != '.' && p[j] != '[' {
+				j++
+			}
+			out = append(out, p[i:j])
+			i = j
+		}
+	}
+	return out
+}
+
+// keyPathMatches reports whether query names the end of path. Indices may be
+// left out of the query: amf_ip_address.ipv4 matches
+// gNBs[0].amf_ip_address[0].ipv4.
+func keyPathMatches(path, query []string) bool {
+	hasIndex := false
+	for _, q := range query {
+		hasIndex = hasIndex || strings.HasPrefix(q, "[")
+	}
+	if !hasIndex {
+		keys := make([]string, 0, len(path))
+		for _, p := range path {
+			if !strings.HasPrefix(p, "[") {
+				keys = append(keys, p)
+			}
+		}
+		path = keys
+	}
+	if len(query) == 0 || len(query) > len(path) {
+		return false
+	}
+	tail := path[len(path)-len(query):]
+	for i := range query {
+		if tail[i] != query[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// ---- values ----
+
+var yamlPlainSafe = regexp.MustCompile(`^[A-Za-z0-9_./@+-][A-Za-z0-9_./:@+-]*$`)
+
+// renderLeaf writes v as the new value of l in format: quoted like the old
+// value, and quoted anyway where a bare value would change its type or not
+// parse.
+func renderLeaf(format string, l confLeaf, v string) string {
+	switch format {
+	case formatLibconfig:
+		if l.quote == 0 && libconfigScalar.MatchString(v) {
+			return v
+		}
+		return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(v) + `"`
+	case formatJSON:
+		if l.quote == 0 && json.Valid([]byte(v)) && !strings.ContainsAny(strings.TrimSpace(v)[:1], `{["`) {
+			return strings.TrimSpace(v)
+		}
+		b, _ := json.Marshal(v)
+		return string(b)
+	}
+	switch l.quote {
+	case '\'':
+		return "'" + strings.ReplaceAll(v, "'", "''") + "'"
+	case '"':
+		b, _ := json.Marshal(v)
+		return string(b)
+	}
+	if yamlPlainSafe.MatchString(v) && !strings.HasSuffix(v, ":") {
+		var x any
+		if err := yaml.Unmarshal([]byte(v), &x); err == nil {
+			if _, isStr := x.(string); isStr || !l.yamlStr {
+				return v
+			}
+		}
+	}
+	b, _ := json.Marshal(v)
+	return string(b)
+}
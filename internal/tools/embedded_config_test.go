@@ -0,0 +1,113 @@
+package tools
+
+import (
+	"strings"
+	"testing"
+)
+
+const embeddedManifest = `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: oai-du
+data:
+  # OAI libconfig
+  du.conf: |
+    gNBs = (
+     {
+        gNB_name = "du"; // name
+        amf_ip_address = ( { ipv4 = "192.168.70.132"; active = "yes"; } );
+      }
+    );
+    MACRLCs = ( { local_n_address = "10.10.1.5";  # F1
+                  remote_n_address = "10.10.1.50";
+                  local_n_portc = 500; } );
+  nad.json: '{"ipam":{"addresses":[{"address":"10.10.1.5/24"}]}}'
+  cfg.json: "{\"ip\": \"10.10.1.5\", \"port\": 38472}"
+  app.yaml: |
+    f1:
+      addr: 10.10.1.5   # keep
+      peer: '10.10.1.50'
+`
+
+func TestParseEmbedded(t *testing.T) {
+	tests := []struct {
+		in, format string
+		paths      []string
+	}{
+		{`a = 1; b = { c = "x"; d = ( 1, 2 ); };`, formatLibconfig, []string{"a", "b.c", "b.d[0]", "b.d[1]"}},
+		{`{"a": {"b": [true, "x"]}}`, formatJSON, []string{"a.b[0]", "a.b[1]"}},
+		{"a:\n  b: x\n  c: [1, 2]\n", formatYAML, []string{"a.b", "a.c[0]", "a.c[1]"}},
+	}
+	for _, tt := range tests {
+		format, leaves, ok := parseEmbedded(tt.in)
+		if !ok || format != tt.format {
+			t.Fatalf("parseEmbedded(%q) = %q, %v; want %q", tt.in, format, ok, tt.format)
+		}
+		var paths []string
+		for _, l := range leaves {
+			paths = append(paths, l.pathString())
+		}
+		if strings.Join(paths, " ") != strings.Join(tt.paths, " ") {
+			t.Errorf("parseEmbedded(%q) paths = %v; want %v", tt.in, paths, tt.paths)
+		}
+	}
+}
+
+func TestPatchEmbeddedConfig(t *testing.T) {
+	edits := []EmbeddedEdit{
+		{Key: "MACRLCs.local_n_address", Value: "10.10.1.10"},
+		{Key: "amf_ip_address.ipv4", Value: "192.168.70.140"},
+		{Key: "local_n_portc", Value: "501"},
+		{Field: `data["nad.json"]`, Key: "address", Value: "10.10.1.10/24"},
+		{Key: "ip", Value: "10.10.1.10"},
+		{Key: "f1.addr", Value: "10.10.1.10"},
+		{Key: "f1.peer", Value: "10.10.1.60"},
+	}
+	p, err := patchEmbeddedConfig([]byte(embeddedManifest), PatchTarget{}, edits, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := strings.NewReplacer(
+		`local_n_address = "10.10.1.5";`, `local_n_address = "10.10.1.10";`,
+		`"192.168.70.132"`, `"192.168.70.140"`,
+		`local_n_portc = 500;`, `local_n_portc = 501;`,
+		`"10.10.1.5/24"`, `"10.10.1.10/24"`,
+		`\"ip\": \"10.10.1.5\"`, `\"ip\": \"10.10.1.10\"`,
+		`addr: 10.10.1.5 `, `addr: 10.10.1.10 `,
+		`'10.10.1.50'`, `'10.10.1.60'`,
+	).Replace(embeddedManifest)
+	if string(p.out) != want {
+		t.Errorf("patched manifest:\n%s\nwant:\n%s", p.out, want)
+	}
+	if p.reformatted || len(p.edits) != len(edits) {
+		t.Errorf("reformatted = %v, %d edits; want false, %d", p.reformatted, len(p.edits), len(edits))
+	}
+}
+
+func TestPatchEmbeddedConfigSameLeafTwice(t *testing.T) {
+	edits := []EmbeddedEdit{
+		{Key: "remote_n_address", Value: "10.10.1.60"},
+		{Key: "MACRLCs.remote_n_address", Value: "10.10.1.60"},
+	}
+	p, err := patchEmbeddedConfig([]byte(embeddedManifest), PatchTarget{}, edits, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Count(string(p.out), `remote_n_address = "10.10.1.60";`); got != 1 || len(p.edits) != 1 {
+		t.Errorf("got %d rewritten values and %d edits; want 1 and 1", got, len(p.edits))
+	}
+
+	edits[1].Value = "10.10.1.70"
+	if _, err := patchEmbeddedConfig([]byte(embeddedManifest), PatchTarget{}, edits, false); err == nil {
+		t.Error("conflicting values for one key: want an error")
+	}
+}
+
+func TestApplyTextEditsOverlap(t *testing.T) {
+	if _, err := applyTextEdits("0123456789", []textEdit{{2, 6, "x"}, {4, 8, "y"}}); err == nil {
+		t.Error("overlapping edits: want an error")
+	}
+	if got, err := applyTextEdits("0123456789", []textEdit{{2, 4, "x"}, {6, 8, "y"}}); err != nil || got != "01x45y89" {
+		t.Errorf("applyTextEdits = %q, %v", got, err)
+	}
+}
@@ -0,0 +1,277 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os/exec"
+	"strings"
+	"syscall"
+
+	"nfreconfig-mcp-server/internal/config"
+	"nfreconfig-mcp-server/internal/identity"
+	"nfreconfig-mcp-server/internal/kube"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// ErrorCode is a stable, machine-readable error class. Agents branch on it
+// (retry later vs. fix the input) instead of parsing messages.
+type ErrorCode string
+
+const (
+	CodeNotFound        ErrorCode = "NOT_FOUND"
+	CodeForbidden       ErrorCode = "FORBIDDEN"
+	CodeConflict        ErrorCode = "CONFLICT"
+	CodeInvalidArgument ErrorCode = "INVALID_ARGUMENT"
+	CodeUnavailable     ErrorCode = "UNAVAILABLE"
+	CodeTimeout         ErrorCode = "TIMEOUT"
+	CodeCancelled       ErrorCode = "CANCELLED"
+	CodeInternal        ErrorCode = "INTERNAL"
+)
+
+// ToolError is the error model shared by failed tool calls and per-item results of batch tools.
+type ToolError struct {
+	Code      ErrorCode `json:"code"`
+	Message   string    `json:"message"`
+	Retryable bool      `json:"retryable"`
+	Target    string    `json:"target,omitempty"` // field, file, repo or object the error is about
+	Hint      string    `json:"hint,omitempty"`   // what the caller can do about it
+
+	cause error
+}
+
+func (e *ToolError) Error() string {
+	if e.Target != "" {
+		return fmt.Sprintf("%s: %s (%s)", e.Code, e.Message, e.Target)
+	}
+	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
+
+func (e *ToolError) Unwrap() error { return e.cause }
+
+// ToolErrorResult is the structured content of a tool call that ended with isError=true.
+type ToolErrorResult struct {
+	Error *ToolError `json:"error"`
+}
+
+func missingField(field string) *ToolError {
+	return &ToolError{
+		Code:    CodeInvalidArgument,
+		Message: "missing required field: " + field,
+		Target:  field,
+	}
+}
+
+func invalidArgument(field, format string, args ...any) *ToolError {
+	return &ToolError{
+		Code:    CodeInvalidArgument,
+		Message: fmt.Sprintf(format, args...),
+		Target:  field,
+	}
+}
+
+// toolError classifies err and fills in target when the classification did not set one.
+func toolError(err error, target string) *ToolError {
+	if err == nil {
+		return nil
+	}
+	te := asToolError(err)
+	if te.Target == "" && target != "" {
+		cp := *te
+		cp.Target = target
+		te = &cp
+	}
+	return te
+}
+
+// asToolError maps Kubernetes API errors, git/subprocess failures, network and
+// filesystem errors onto the error model. Already-typed errors pass through.
+func asToolError(err error) *ToolError {
+	if err == nil {
+		return nil
+	}
+	var te *ToolError
+	if errors.As(err, &te) {
+		if err == error(te) {
+			return te
+		}
+		// Keep the outer context ("git clone failed: ...") but the inner classification.
+		cp := *te
+		cp.Message = strings.TrimSuffix(err.Error(), te.Error()) + te.Message
+		cp.cause = err
+		return &cp
+	}
+
+	out := &ToolError{Code: CodeInternal, Message: err.Error(), cause: err}
+
+	var ce *cmdError
+	var amb *kube.AmbiguousClusterError
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		out.Code, out.Retryable = CodeTimeout, true
+	case errors.Is(err, context.Canceled):
+		out.Code = CodeCancelled
+	case errors.As(err, &ce):
+		classifyCmdError(ce, out)
+	case errors.Is(err, kube.ErrNoIdentity):
+		out.Code = CodeForbidden
+		out.Hint = "call through the authenticating proxy, or set an identity with session_set_identity"
+	case errors.Is(err, identity.ErrGroupNotAllowed):
+		out.Code = CodeForbidden
+		out.Hint = "impersonate a group listed in kube.impersonation.allowedGroups; system: groups are never allowed"
+	case errors.Is(err, identity.ErrUserNotAllowed):
+		out.Code = CodeForbidden
+		out.Hint = "impersonate a regular user, or list the system: user in kube.impersonation.allowedSystemUsers"
+	case errors.As(err, &amb):
+		out.Code = CodeInvalidArgument
+		out.Target = amb.Name
+		out.Hint = "pass the cluster as namespace/name, e.g. " + amb.Namespaces[0] + "/" + amb.Name
+	case isAPIStatus(err):
+		classifyAPIError(err, out)
+	case errors.Is(err, exec.ErrNotFound):
+		out.Code = CodeUnavailable
+		out.Hint = "install the missing binary in the server image"
+	case errors.Is(err, fs.ErrNotExist):
+		out.Code = CodeNotFound
+	case errors.Is(err, fs.ErrPermission):
+		out.Code = CodeForbidden
+	case isNetTimeout(err):
+		out.Code, out.Retryable = CodeTimeout, true
+	case errors.Is(err, syscall.ECONNREFUSED), errors.Is(err, syscall.EHOSTUNREACH), isNetError(err):
+		out.Code, out.Retryable = CodeUnavailable, true
+	}
+	return out
+}
+
+func isAPIStatus(err error) bool {
+	var st apierrors.APIStatus
+	return errors.As(err, &st)
+}
+
+func classifyAPIError(err error, out *ToolError) {
+	switch {
+	case apierrors.IsNotFound(err), apierrors.IsGone(err):
+		out.Code = CodeNotFound
+	case apierrors.IsForbidden(err):
+		out.Code = CodeForbidden
+		out.Hint = "check the RBAC permissions of the server's identity on the target cluster"
+		if config.Current().Kube.Impersonation.Enabled {
+			out.Hint = "check the RBAC permissions of the impersonated caller (the server itself when the call has no identity) on the target cluster, and that the server may impersonate it"
+		}
+	case apierrors.IsUnauthorized(err):
+		out.Code = CodeForbidden
+		out.Hint = "the credentials for the target cluster were rejected; refresh the kubeconfig"
+	case apierrors.IsConflict(err), apierrors.IsAlreadyExists(err):
+		out.Code, out.Retryable = CodeConflict, true
+		out.Hint = "the object changed concurrently; re-read it and retry"
+	case apierrors.IsInvalid(err), apierrors.IsBadRequest(err), apierrors.IsMethodNotSupported(err),
+		apierrors.IsNotAcceptable(err), apierrors.IsUnsupportedMediaType(err), apierrors.IsRequestEntityTooLargeError(err):
+		out.Code = CodeInvalidArgument
+	case apierrors.IsTimeout(err), apierrors.IsServerTimeout(err):
+		out.Code, out.Retryable = CodeTimeout, true
+	case apierrors.IsTooManyRequests(err), apierrors.IsServiceUnavailable(err),
+		apierrors.IsInternalError(err), apierrors.IsUnexpectedServerError(err):
+		out.Code, out.Retryable = CodeUnavailable, true
+	}
+	if _, delay := apierrors.SuggestsClientDelay(err); delay {
+		out.Retryable = true
+	}
+}
+
+func isNetTimeout(err error) bool {
+	var ne net.Error
+	return errors.As(err, &ne) && ne.Timeout()
+}
+
+func isNetError(err error) bool {
+	var oe *net.OpError
+	var de *net.DNSError
+	return errors.As(err, &oe) || errors.As(err, &de)
+}
+
+// cmdError is a failed subprocess run with its exit code and combined output.
+type cmdError struct {
+	Name     string
+	Args     []string
+	ExitCode int // -1 if the process did not exit normally
+	Output   string
+	err      error
+	ctxErr   error // set when the process was killed because ctx ended
+}
+
+func newCmdError(ctx context.Context, name string, args []string, out []byte, err error) *cmdError {
+	ce := &cmdError{Name: name, Args: args, ExitCode: -1, Output: string(out), err: err, ctxErr: ctx.Err()}
+	var ee *exec.ExitError
+	if errors.As(err, &ee) {
+		ce.ExitCode = ee.ExitCode()
+	}
+	return ce
+}
+
+func (e *cmdError) Error() string {
+	return fmt.Sprintf("%s %s: %v\n%s", e.Name, strings.Join(e.Args, " "), e.err, e.Output)
+}
+
+func (e *cmdError) Unwrap() error { return e.err }
+
+// classifyCmdError recognises the git failure modes we see in practice. git reports
+// almost everything with exit code 128, so the output text is what distinguishes them.
+func classifyCmdError(ce *cmdError, out *ToolError) {
+	o := strings.ToLower(ce.Output)
+	switch {
+	case errors.Is(ce.ctxErr, context.DeadlineExceeded):
+		out.Code, out.Retryable = CodeTimeout, true
+	case errors.Is(ce.ctxErr, context.Canceled):
+		out.Code = CodeCancelled
+	case containsAny(o, "authentication failed", "could not read username", "could not read password",
+		"http basic: access denied", "the requested url returned error: 401", "the requested url returned error: 403",
+		"permission denied"):
+		out.Code = CodeForbidden
+		out.Hint = "provide username/password with push/pull access to the repository"
+	case containsAny(o, "repository not found", "does not appear to be a git repository",
+		"couldn't find remote ref", "the requested url returned error: 404", "did not match any file(s) known to git",
+		"not found in upstream", "pathspec"):
+		out.Code = CodeNotFound
+	case containsAny(o, "[rejected]", "non-fast-forward", "fetch first", "failed to push some refs", "[remote rejected]"):
+		out.Code, out.Retryable = CodeConflict, true
+		out.Hint = "the remote branch moved; re-clone with pull=true, re-apply the patch and push again"
+	case containsAny(o, "could not resolve host", "failed to connect", "connection refused", "connection timed out",
+		"connection reset", "operation timed out", "unable to access", "the remote end hung up unexpectedly",
+		"the requested url returned error: 5"):
+		out.Code, out.Retryable = CodeUnavailable, true
+	case containsAny(o, "not a git repository"):
+		out.Code = CodeInvalidArgument
+		out.Hint = "workdir must be a directory returned by git_clone_repos"
+	case ce.ExitCode == -1:
+		// killed by a signal (usually context cancellation)
+		out.Code, out.Retryable = CodeUnavailable, true
+	}
+}
+
+func containsAny(s string, subs ...string) bool {
+	for _, sub := range subs {
+		if strings.Contains(s, sub) {
+			return true
+		}
+	}
+	return false
+}
+
+// toolErrorResult renders a ToolError as an MCP error result with structured content.
+func toolErrorResult(te *ToolError) *mcp.CallToolResultFor[any] {
+	payload := ToolErrorResult{Error: te}
+	text := te.Error()
+	if b, err := json.MarshalIndent(payload, "", "  "); err == nil {
+		text = string(b)
+	}
+	return &mcp.CallToolResultFor[any]{
+		Content:           []mcp.Content{&mcp.TextContent{Text: text}},
+		StructuredContent: payload,
+		IsError:           true,
+	}
+}
@@ -13,6 +13,9 @@ import (
 	"sync"
 	"time"
 
+	"nfreconfig-mcp-server/internal/config"
+	"nfreconfig-mcp-server/internal/telemetry"
+
 	"github.com/modelcontextprotocol/go-sdk/mcp"
 )
 
@@ -20,28 +23,28 @@ func init() { registerTool(GitCloneOrOpenMany()) }
 
 // NamedRepo is a repo identity coming from repos_get_url: name (cluster/repo name) + URL.
 type NamedRepo struct {
-	Name string `json:"name"`
-	URL  string `json:"url"`
+	Name string `json:"name" description:"Short repo name used to key results and workdirs." example:"cucp"`
+	URL  string `json:"url" description:"Git clone URL." example:"http://gitea.example.com/nephio/5g-cucp.git"`
 }
 
 type GitCloneOrOpenManyParams struct {
-	Repos       []NamedRepo `json:"repos"`                 // required
-	Ref         string      `json:"ref,omitempty"`         // default "main"
-	Depth       int         `json:"depth,omitempty"`       // default 1
-	Pull        bool        `json:"pull,omitempty"`        // default false unless provided (set true in calls)
-	Root        string      `json:"root,omitempty"`        // default "$HOME/.cache/nfreconfig-mcp-server/git-cache"
-	Concurrency int         `json:"concurrency,omitempty"` // default 4
+	Repos       []NamedRepo `json:"repos" description:"Repositories to clone or reuse."`
+	Ref         string      `json:"ref,omitempty" description:"Branch, tag or commit to check out; defaults to git.ref from the server config (main)."`
+	Depth       int         `json:"depth,omitempty" description:"Clone depth; defaults to git.depth from the server config (1)."`
+	Pull        bool        `json:"pull,omitempty" description:"Fetch and reset existing clones to the remote ref."`
+	Root        string      `json:"root,omitempty" description:"Cache directory for clones; defaults to git.cacheRoot from the server config ($HOME/.cache/nfreconfig-mcp-server/git-cache)."`
+	Concurrency int         `json:"concurrency,omitempty" description:"Maximum parallel clones; defaults to git.cloneConcurrency from the server config (4)."`
 }
 
 type GitRepoCloneResult struct {
-	Name    string `json:"name"`
-	URL     string `json:"url"`
-	Ref     string `json:"ref"`
-	Workdir string `json:"workdir,omitempty"`
-	Head    string `json:"head,omitempty"`
-	Updated bool   `json:"updated,omitempty"`
-	Exists  bool   `json:"exists,omitempty"`
-	Error   string `json:"error,omitempty"`
+	Name    string     `json:"name"`
+	URL     string     `json:"url"`
+	Ref     string     `json:"ref"`
+	Workdir string     `json:"workdir,omitempty"`
+	Head    string     `json:"head,omitempty"`
+	Updated bool       `json:"updated,omitempty"`
+	Exists  bool       `json:"exists,omitempty"`
+	Error   *ToolError `json:"error,omitempty"`
 }
 
 type GitCloneOrOpenManyResult struct {
@@ -54,7 +57,8 @@ type GitCloneOrOpenManyResult struct {
 func GitCloneOrOpenMany() MCPTool[GitCloneOrOpenManyParams, GitCloneOrOpenManyResult] {
 	return MCPTool[GitCloneOrOpenManyParams, GitCloneOrOpenManyResult]{
 		Name:        "git_clone_repos",
-		Description: "Clone git repositories to local workdirs. Reuses existing valid repos or clones fresh. Use before scanning/patching manifests. Returns workdir paths for each repo. Example: {\"repos\":[{\"name\":\"cucp\",\"url\":\"http://gitea.com/nephio/5g-cucp.git\",\"branch\":\"main\"}], \"baseDir\":\"/tmp/work\"}.",
+		Description: "Clone git repositories to local workdirs. Reuses existing valid repos or clones fresh. Use before scanning/patching manifests. Returns workdir paths for each repo. Example: {\"repos\":[{\"name\":\"cucp\",\"url\":\"http://gitea.com/nephio/5g-cucp.git\"}], \"ref\":\"main\", \"pull\":true}.",
+		Timeout:     10 * time.Minute,
 		Handler: func(ctx context.Context, cc *mcp.ServerSession, params *mcp.CallToolParamsFor[GitCloneOrOpenManyParams]) (*mcp.CallToolResultFor[GitCloneOrOpenManyResult], error) {
 			start := time.Now()
 
@@ -68,7 +72,7 @@ func GitCloneOrOpenMany() MCPTool[GitCloneOrOpenManyParams, GitCloneOrOpenManyRe
 				repos = append(repos, r)
 			}
 			if len(repos) == 0 {
-				return toolErr[GitCloneOrOpenManyResult](fmt.Errorf("missing required field: repos (non-empty array of {name,url})"))
+				return toolErr[GitCloneOrOpenManyResult](invalidArgument("repos", "missing required field: repos (non-empty array of {name,url})"))
 			}
 
 			// Ensure git exists once
@@ -76,25 +80,21 @@ func GitCloneOrOpenMany() MCPTool[GitCloneOrOpenManyParams, GitCloneOrOpenManyRe
 				return toolErr[GitCloneOrOpenManyResult](fmt.Errorf("git binary not found in PATH: %w", err))
 			}
 
+			cfg := config.Current()
+
 			ref := strings.TrimSpace(params.Arguments.Ref)
 			if ref == "" {
-				ref = "main"
+				ref = cfg.Git.Ref
 			}
 
 			depth := params.Arguments.Depth
 			if depth <= 0 {
-				depth = 1
+				depth = cfg.Git.Depth
 			}
 
-			// Default root: user-writable cache dir
 			root := strings.TrimSpace(params.Arguments.Root)
 			if root == "" {
-				home := strings.TrimSpace(os.Getenv("HOME"))
-				if home != "" {
-					root = filepath.Join(home, ".cache", "nfreconfig-mcp-server", "git-cache")
-				} else {
-					root = "/tmp/nfreconfig-mcp-server/git-cache"
-				}
+				root = cfg.Git.CacheRoot
 			}
 			if err := os.MkdirAll(root, 0o755); err != nil {
 				return toolErr[GitCloneOrOpenManyResult](fmt.Errorf("create root dir %q: %w", root, err))
@@ -102,7 +102,7 @@ func GitCloneOrOpenMany() MCPTool[GitCloneOrOpenManyParams, GitCloneOrOpenManyRe
 
 			concurrency := params.Arguments.Concurrency
 			if concurrency <= 0 {
-				concurrency = 4
+				concurrency = cfg.Git.CloneConcurrency
 			}
 			if concurrency > len(repos) {
 				concurrency = len(repos)
@@ -123,6 +123,10 @@ func GitCloneOrOpenMany() MCPTool[GitCloneOrOpenManyParams, GitCloneOrOpenManyRe
 						<-sem
 						wg.Done()
 					}()
+					if !cfg.RepoAllowed(repos[i].URL) {
+						results[i] = GitRepoCloneResult{Name: repos[i].Name, URL: repos[i].URL, Ref: ref, Error: repoNotAllowed(repos[i].URL)}
+						return
+					}
 					results[i] = cloneOrOpenOneNamed(ctx, root, repos[i], ref, depth, pull)
 				}()
 			}
@@ -173,14 +177,14 @@ func cloneOrOpenOneNamed(ctx context.Context, root string, repo NamedRepo, ref s
 		args = append(args, url, workdir)
 
 		if err := runCmd(ctx, "", "git", args...); err != nil {
-			res.Error = fmt.Sprintf("git clone failed: %v", err)
+			res.Error = toolError(fmt.Errorf("git clone failed: %w", err), repo.URL)
 			return res
 		}
 
 		// checkout SHA if needed
 		if looksLikeCommitSHA(ref) {
 			if err := runCmd(ctx, workdir, "git", "checkout", ref); err != nil {
-				res.Error = fmt.Sprintf("git checkout %s failed: %v", ref, err)
+				res.Error = toolError(fmt.Errorf("git checkout %s failed: %w", ref, err), repo.URL)
 				return res
 			}
 		}
@@ -190,14 +194,19 @@ func cloneOrOpenOneNamed(ctx context.Context, root string, repo NamedRepo, ref s
 		// Verify origin matches requested URL (avoid wrong reuse)
 		if origin, err := gitOriginURL(ctx, workdir); err == nil && origin != "" {
 			if !sameRepoURL(origin, url) {
-				res.Error = fmt.Sprintf("origin mismatch (have=%q want=%q) workdir=%s", origin, url, workdir)
+				res.Error = &ToolError{
+					Code:    CodeConflict,
+					Message: fmt.Sprintf("origin mismatch (have=%q want=%q) workdir=%s", origin, url, workdir),
+					Target:  repo.URL,
+					Hint:    "use a different root or a distinct repo name for this URL",
+				}
 				return res
 			}
 		}
 
 		if pull {
 			if err := runCmd(ctx, workdir, "git", "fetch", "--all", "--prune"); err != nil {
-				res.Error = fmt.Sprintf("git fetch failed: %v", err)
+				res.Error = toolError(fmt.Errorf("git fetch failed: %w", err), repo.URL)
 				return res
 			}
 			res.Updated = true
@@ -205,7 +214,7 @@ func cloneOrOpenOneNamed(ctx context.Context, root string, repo NamedRepo, ref s
 
 		if looksLikeCommitSHA(ref) {
 			if err := runCmd(ctx, workdir, "git", "checkout", ref); err != nil {
-				res.Error = fmt.Sprintf("git checkout %s failed: %v", ref, err)
+				res.Error = toolError(fmt.Errorf("git checkout %s failed: %w", ref, err), repo.URL)
 				return res
 			}
 		} else {
@@ -224,7 +233,7 @@ func cloneOrOpenOneNamed(ctx context.Context, root string, repo NamedRepo, ref s
 
 	head, err := gitHeadSHA(ctx, workdir)
 	if err != nil {
-		res.Error = fmt.Sprintf("read HEAD failed: %v", err)
+		res.Error = toolError(fmt.Errorf("read HEAD failed: %w", err), repo.URL)
 		return res
 	}
 	res.Head = head
@@ -233,6 +242,15 @@ func cloneOrOpenOneNamed(ctx context.Context, root string, repo NamedRepo, ref s
 
 // ----------------- helpers -----------------
 
+func repoNotAllowed(url string) *ToolError {
+	return &ToolError{
+		Code:    CodeForbidden,
+		Message: "repository is not in repos.allowed of the server config",
+		Target:  url,
+		Hint:    "add the URL (or a prefix ending in / or *) to repos.allowed",
+	}
+}
+
 func hashKey(s string) string {
 	h := sha1.Sum([]byte(strings.TrimSpace(s)))
 	return hex.EncodeToString(h[:])
@@ -256,34 +274,43 @@ func dirLooksLikeGitRepo(dir string) bool {
 	return err == nil && st != nil
 }
 
-func runCmd(ctx context.Context, workdir string, name string, args ...string) error {
-	cmd := exec.CommandContext(ctx, name, args...)
+func runCmd(ctx context.Context, workdir string, name string, args ...string) (err error) {
+	ctx, span := startExecSpan(ctx, workdir, name, args...)
+	defer func() { telemetry.RecordError(span, err); span.End() }()
+
+	cmd := commandContext(ctx, name, args...)
 	if workdir != "" {
 		cmd.Dir = workdir
 	}
 	out, err := cmd.CombinedOutput()
 	if err != nil {
-		return fmt.Errorf("%s %s: %w\n%s", name, strings.Join(args, " "), err, string(out))
+		return newCmdError(ctx, name, args, out, err)
 	}
 	return nil
 }
 
-func gitHeadSHA(ctx context.Context, workdir string) (string, error) {
-	cmd := exec.CommandContext(ctx, "git", "rev-parse", "HEAD")
+func gitHeadSHA(ctx context.Context, workdir string) (_ string, err error) {
+	ctx, span := startExecSpan(ctx, workdir, "git", "rev-parse")
+	defer func() { telemetry.RecordError(span, err); span.End() }()
+
+	cmd := commandContext(ctx, "git", "rev-parse", "HEAD")
 	cmd.Dir = workdir
 	out, err := cmd.CombinedOutput()
 	if err != nil {
-		return "", fmt.Errorf("git rev-parse HEAD: %w\n%s", err, string(out))
+		return "", newCmdError(ctx, "git", []string{"rev-parse", "HEAD"}, out, err)
 	}
 	return strings.TrimSpace(string(out)), nil
 }
 
-func gitOriginURL(ctx context.Context, workdir string) (string, error) {
-	cmd := exec.CommandContext(ctx, "git", "remote", "get-url", "origin")
+func gitOriginURL(ctx context.Context, workdir string) (_ string, err error) {
+	ctx, span := startExecSpan(ctx, workdir, "git", "remote")
+	defer func() { telemetry.RecordError(span, err); span.End() }()
+
+	cmd := commandContext(ctx, "git", "remote", "get-url", "origin")
 	cmd.Dir = workdir
 	out, err := cmd.CombinedOutput()
 	if err != nil {
-		return "", fmt.Errorf("git remote get-url origin: %w\n%s", err, string(out))
+		return "", newCmdError(ctx, "git", []string{"remote", "get-url", "origin"}, out, err)
 	}
 	return strings.TrimSpace(string(out)), nil
 }
@@ -12,34 +12,37 @@ import (
 	"sync"
 	"time"
 
+	"nfreconfig-mcp-server/internal/config"
+	"nfreconfig-mcp-server/internal/telemetry"
+
 	"github.com/modelcontextprotocol/go-sdk/mcp"
 )
 
 func init() { registerTool(GitCommitPushMany()) }
 
 type GitCommitPushTarget struct {
-	Name    string `json:"name"`    // repo name
-	Workdir string `json:"workdir"` // cloned directory
-	URL     string `json:"url,omitempty"`
+	Name    string `json:"name" description:"Repo name, as returned by git_clone_repos."`
+	Workdir string `json:"workdir" description:"Cloned directory, as returned by git_clone_repos."`
+	URL     string `json:"url,omitempty" description:"Remote URL; used to verify the origin of the workdir."`
 }
 
 type GitCommitPushManyParams struct {
-	Targets     []GitCommitPushTarget `json:"targets"`               // required
-	Branch      string                `json:"branch,omitempty"`      // default "main"
-	Message     string                `json:"message"`               // required
-	Username    string                `json:"username,omitempty"`    // for HTTP auth
-	Password    string                `json:"password,omitempty"`    // for HTTP auth
-	Concurrency int                   `json:"concurrency,omitempty"` // default 3
+	Targets     []GitCommitPushTarget `json:"targets" description:"Repositories to commit and push."`
+	Branch      string                `json:"branch,omitempty" description:"Branch to push to; defaults to git.ref from the server config (main)."`
+	Message     string                `json:"message" description:"Commit message."`
+	Username    string                `json:"username,omitempty" description:"Username for HTTP auth; when username and password are empty, credentials configured for the repo URL are used."`
+	Password    string                `json:"password,omitempty" description:"Password or token for HTTP auth."`
+	Concurrency int                   `json:"concurrency,omitempty" description:"Maximum parallel pushes; defaults to git.pushConcurrency from the server config (3)."`
 }
 
 type GitCommitPushResult struct {
-	Name      string `json:"name"`
-	Workdir   string `json:"workdir"`
-	Branch    string `json:"branch"`
-	Committed bool   `json:"committed"`
-	Pushed    bool   `json:"pushed"`
-	Head      string `json:"head,omitempty"`
-	Error     string `json:"error,omitempty"`
+	Name      string     `json:"name"`
+	Workdir   string     `json:"workdir"`
+	Branch    string     `json:"branch"`
+	Committed bool       `json:"committed"`
+	Pushed    bool       `json:"pushed"`
+	Head      string     `json:"head,omitempty"`
+	Error     *ToolError `json:"error,omitempty"`
 }
 
 type GitCommitPushManyResult struct {
@@ -51,20 +54,23 @@ func GitCommitPushMany() MCPTool[GitCommitPushManyParams, GitCommitPushManyResul
 	return MCPTool[GitCommitPushManyParams, GitCommitPushManyResult]{
 		Name:        "git_commit_push",
 		Description: "Stage, commit (if changes), and push many repos. Supports HTTP auth using temporary GIT_ASKPASS.",
+		Timeout:     10 * time.Minute,
 		Handler: func(ctx context.Context, cc *mcp.ServerSession, params *mcp.CallToolParamsFor[GitCommitPushManyParams]) (*mcp.CallToolResultFor[GitCommitPushManyResult], error) {
 			start := time.Now()
 
 			if len(params.Arguments.Targets) == 0 {
-				return toolErr[GitCommitPushManyResult](fmt.Errorf("missing required field: targets"))
+				return toolErr[GitCommitPushManyResult](missingField("targets"))
 			}
 			msg := strings.TrimSpace(params.Arguments.Message)
 			if msg == "" {
-				return toolErr[GitCommitPushManyResult](fmt.Errorf("missing required field: message"))
+				return toolErr[GitCommitPushManyResult](missingField("message"))
 			}
 
+			cfg := config.Current()
+
 			branch := strings.TrimSpace(params.Arguments.Branch)
 			if branch == "" {
-				branch = "main"
+				branch = cfg.Git.Ref
 			}
 
 			if _, err := exec.LookPath("git"); err != nil {
@@ -73,7 +79,7 @@ func GitCommitPushMany() MCPTool[GitCommitPushManyParams, GitCommitPushManyResul
 
 			con := params.Arguments.Concurrency
 			if con <= 0 {
-				con = 3
+				con = cfg.Git.PushConcurrency
 			}
 			if con > len(params.Arguments.Targets) {
 				con = len(params.Arguments.Targets)
@@ -81,12 +87,12 @@ func GitCommitPushMany() MCPTool[GitCommitPushManyParams, GitCommitPushManyResul
 
 			askpassPath := ""
 			if params.Arguments.Username != "" || params.Arguments.Password != "" {
-				p, err := writeAskPassScript(params.Arguments.Username, params.Arguments.Password)
+				p, cleanup, err := writeAskPassScript(params.Arguments.Username, params.Arguments.Password)
 				if err != nil {
 					return toolErr[GitCommitPushManyResult](err)
 				}
 				askpassPath = p
-				defer os.Remove(p)
+				defer cleanup()
 			}
 
 			results := make([]GitCommitPushResult, len(params.Arguments.Targets))
@@ -101,7 +107,7 @@ func GitCommitPushMany() MCPTool[GitCommitPushManyParams, GitCommitPushManyResul
 				go func() {
 					defer func() { <-sem; wg.Done() }()
 					t := params.Arguments.Targets[i]
-					results[i] = commitPushOne(ctx, t, branch, msg, askpassPath)
+					results[i] = commitPushOne(ctx, cfg, t, branch, msg, askpassPath)
 				}()
 			}
 
@@ -115,7 +121,7 @@ func GitCommitPushMany() MCPTool[GitCommitPushManyParams, GitCommitPushManyResul
 	}
 }
 
-func commitPushOne(ctx context.Context, t GitCommitPushTarget, branch, msg, askpassPath string) GitCommitPushResult {
+func commitPushOne(ctx context.Context, cfg *config.Config, t GitCommitPushTarget, branch, msg, askpassPath string) GitCommitPushResult {
 	res := GitCommitPushResult{
 		Name:    strings.TrimSpace(t.Name),
 		Workdir: cleanPath(t.Workdir),
@@ -123,16 +129,43 @@ func commitPushOne(ctx context.Context, t GitCommitPushTarget, branch, msg, askp
 	}
 
 	if res.Workdir == "" {
-		res.Error = "empty workdir"
+		res.Error = invalidArgument("workdir", "empty workdir for target %q", res.Name)
+		return res
+	}
+
+	remote := strings.TrimSpace(t.URL)
+	if origin, err := gitOut(ctx, res.Workdir, "", "remote", "get-url", "origin"); err == nil {
+		remote = strings.TrimSpace(origin)
+	}
+	if remote != "" && !cfg.RepoAllowed(remote) {
+		res.Error = repoNotAllowed(remote)
 		return res
 	}
 
+	// No explicit credentials: use the ones configured for this remote, if any.
+	if askpassPath == "" && remote != "" {
+		user, pass, ok, err := cfg.CredentialsFor(remote)
+		if err != nil {
+			res.Error = &ToolError{Code: CodeInternal, Message: err.Error(), Target: remote, Hint: "check the credentials section of the server config"}
+			return res
+		}
+		if ok {
+			p, cleanup, err := writeAskPassScript(user, pass)
+			if err != nil {
+				res.Error = toolError(err, res.Workdir)
+				return res
+			}
+			defer cleanup()
+			askpassPath = p
+		}
+	}
+
 	// checkout branch (best effort)
 	_ = runGit(ctx, res.Workdir, askpassPath, "checkout", branch)
 
 	// stage
 	if err := runGit(ctx, res.Workdir, askpassPath, "add", "-A"); err != nil {
-		res.Error = err.Error()
+		res.Error = toolError(err, res.Workdir)
 		return res
 	}
 
@@ -148,14 +181,14 @@ func commitPushOne(ctx context.Context, t GitCommitPushTarget, branch, msg, askp
 
 	// commit
 	if err := runGit(ctx, res.Workdir, askpassPath, "commit", "-m", msg); err != nil {
-		res.Error = err.Error()
+		res.Error = toolError(err, res.Workdir)
 		return res
 	}
 	res.Committed = true
 
 	// push
 	if err := runGit(ctx, res.Workdir, askpassPath, "push", "origin", branch); err != nil {
-		res.Error = err.Error()
+		res.Error = toolError(err, res.Workdir)
 		return res
 	}
 	res.Pushed = true
@@ -165,7 +198,10 @@ func commitPushOne(ctx context.Context, t GitCommitPushTarget, branch, msg, askp
 	return res
 }
 
-func writeAskPassScript(user, pass string) (string, error) {
+// writeAskPassScript writes a GIT_ASKPASS helper holding the credentials. The
+// returned cleanup removes it; shutdown cleanup removes it too if the call is
+// interrupted.
+func writeAskPassScript(user, pass string) (string, func(), error) {
 	// random file name
 	var b [8]byte
 	_, _ = rand.Read(b[:])
@@ -173,7 +209,7 @@ func writeAskPassScript(user, pass string) (string, error) {
 
 	dir := filepath.Join(os.TempDir(), "nfreconfig-mcp-server")
 	if err := os.MkdirAll(dir, 0o755); err != nil {
-		return "", err
+		return "", nil, err
 	}
 	path := filepath.Join(dir, name)
 
@@ -185,14 +221,19 @@ case "$1" in
 esac
 `, user, pass)
 
+	cleanup := trackTempFile(path)
 	if err := os.WriteFile(path, []byte(script), 0o700); err != nil {
-		return "", err
+		cleanup()
+		return "", nil, err
 	}
-	return path, nil
+	return path, cleanup, nil
 }
 
-func runGit(ctx context.Context, dir, askpass string, args ...string) error {
-	cmd := exec.CommandContext(ctx, "git", args...)
+func runGit(ctx context.Context, dir, askpass string, args ...string) (err error) {
+	ctx, span := startExecSpan(ctx, dir, "git", args...)
+	defer func() { telemetry.RecordError(span, err); span.End() }()
+
+	cmd := commandContext(ctx, "git", args...)
 	cmd.Dir = dir
 	if askpass != "" {
 		cmd.Env = append(os.Environ(),
@@ -202,13 +243,16 @@ func runGit(ctx context.Context, dir, askpass string, args ...string) error {
 	}
 	out, err := cmd.CombinedOutput()
 	if err != nil {
-		return fmt.Errorf("git %s: %w\n%s", strings.Join(args, " "), err, string(out))
+		return newCmdError(ctx, "git", args, out, err)
 	}
 	return nil
 }
 
-func gitOut(ctx context.Context, dir, askpass string, args ...string) (string, error) {
-	cmd := exec.CommandContext(ctx, "git", args...)
+func gitOut(ctx context.Context, dir, askpass string, args ...string) (_ string, err error) {
+	ctx, span := startExecSpan(ctx, dir, "git", args...)
+	defer func() { telemetry.RecordError(span, err); span.End() }()
+
+	cmd := commandContext(ctx, "git", args...)
 	cmd.Dir = dir
 	if askpass != "" {
 		cmd.Env = append(os.Environ(),
@@ -218,7 +262,7 @@ func gitOut(ctx context.Context, dir, askpass string, args ...string) (string, e
 	}
 	out, err := cmd.CombinedOutput()
 	if err != nil {
-		return string(out), fmt.Errorf("git %s: %w\n%s", strings.Join(args, " "), err, string(out))
+		return string(out), newCmdError(ctx, "git", args, out, err)
 	}
 	return string(out), nil
 }
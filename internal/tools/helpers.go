@@ -25,6 +25,7 @@ func toolOK[T any](payload T) *mcp.CallToolResultFor[T] {
 	}
 }
 
+// toolErr fails the call; withToolErrors renders err as a structured ToolError.
 func toolErr[T any](err error) (*mcp.CallToolResultFor[T], error) {
-	return nil, fmt.Errorf("tool error: %w", err)
+	return nil, asToolError(err)
 }
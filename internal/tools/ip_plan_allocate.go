@@ -0,0 +1,391 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+func init() { registerTool(IPPlanAllocate()) }
+
+// maxPlanCount bounds IPPlanAllocateParams.Count; maxPlanSteps bounds the
+// addresses looked at per subnet.
+const (
+	maxPlanCount = 256
+	maxPlanSteps = 1 << 20
+)
+
+// Where a planned gateway came from, in PlannedSubnet.GatewaySource.
+const (
+	gatewayFromNAD      = "nad"
+	gatewayFromDeclared = "declared"
+	gatewayAssumed      = "assumed"
+)
+
+type IPPlanAllocateParams struct {
+	Cluster    string              `json:"cluster,omitempty" description:"Target cluster: a kubeconfig context, or a workload (CAPI) cluster as name or namespace/name. Its NADs, NFDeployments, NFConfigs, live pod addresses and pod/service CIDRs are taken into account." example:"5g-edge"`
+	Interfaces []string            `json:"interfaces" description:"Interfaces to plan addresses for." example:"[\"n2\",\"f1c\",\"e1\"]"`
+	Repos      []RepoWorkdir       `json:"repos,omitempty" description:"Cloned repositories whose NFDeployment, NAD, NFConfig, Config, IPClaim and ConfigMap addresses count as used, and whose NADs give the subnets."`
+	Subnets    map[string][]string `json:"subnets,omitempty" description:"Subnet (CIDR) per interface, at most one per family, for interfaces without a NAD or to override it." example:"{\"f1c\":[\"10.20.1.0/24\"]}"`
+	Family     string              `json:"family,omitempty" description:"Only plan this address family; by default every family the interface has a subnet for." enum:"ipv4,ipv6"`
+	Count      int                 `json:"count,omitempty" description:"Free addresses to return per interface and family; newIps takes the first." default:"1"`
+	Exclude    []string            `json:"exclude,omitempty" description:"More addresses or CIDRs not to hand out." example:"[\"10.20.1.240/28\"]"`
+	Namespace  string              `json:"namespace,omitempty" description:"Only read the target cluster's objects and pods in this namespace; all namespaces when empty."`
+	Context    string              `json:"context,omitempty" description:"Management cluster kube context, to resolve a workload cluster; defaults to the -mgmt-context flag, then kube.managementContext from the server config, then the current context."`
+}
+
+// PlannedSubnet is the plan for one family of an interface.
+type PlannedSubnet struct {
+	Family     string `json:"family"`
+	Subnet     string `json:"subnet"`
+	Source     string `json:"source"` // param, or the NAD or interface it was taken from
+	RangeStart string `json:"rangeStart,omitempty"`
+	RangeEnd   string `json:"rangeEnd,omitempty"`
+	Gateway    string `json:"gateway,omitempty"`
+	// GatewaySource: nad, declared (a gateway already configured in the subnet)
+	// or assumed (first host, nothing configured)
+	GatewaySource string `json:"gatewaySource,omitempty"`
+	// Addresses are free addresses in CIDR form, lowest first
+	Addresses []string   `json:"addresses,omitempty"`
+	Warnings  []string   `json:"warnings,omitempty"`
+	Error     *ToolError `json:"error,omitempty"`
+}
+
+// PlannedInterface is the plan for one interface.
+type PlannedInterface struct {
+	Name    string          `json:"name"`
+	Subnets []PlannedSubnet `json:"subnets,omitempty"`
+	Error   *ToolError      `json:"error,omitempty"`
+}
+
+type IPPlanAllocateResult struct {
+	Cluster    string             `json:"cluster,omitempty"`
+	Interfaces []PlannedInterface `json:"interfaces"`
+	// NewIPs is the first planned address and gateway per interface and family,
+	// to pass as newIps to manifest_patch_cucp_ips
+	NewIPs        map[string]IPInfo `json:"newIps"`
+	UsedAddresses int               `json:"usedAddresses"`
+	PodCIDRs      []string          `json:"podCidrs,omitempty"`
+	ServiceCIDRs  []string          `json:"serviceCidrs,omitempty"`
+	// Errors name sources that could not be read: addresses in them were not
+	// considered taken
+	Errors   []ScanIssue `json:"errors,omitempty"`
+	Warnings []ScanIssue `json:"warnings,omitempty"`
+}
+
+func (r *IPPlanAllocateResult) addIssue(source string, err error) {
+	if issue, warning := newScanIssue(source, err); warning {
+		r.Warnings = append(r.Warnings, issue)
+	} else {
+		r.Errors = append(r.Errors, issue)
+	}
+}
+
+func IPPlanAllocate() MCPTool[IPPlanAllocateParams, IPPlanAllocateResult] {
+	return MCPTool[IPPlanAllocateParams, IPPlanAllocateResult]{
+		Name:        "ip_plan_allocate",
+		Description: "Plan free addresses for NF interfaces. Collects the addresses in use from cloned repos (NFDeployment, NAD, NFConfig, Config, IPClaim, ConfigMap) and the target cluster (NADs, NFDeployments, NFConfigs, live pod addresses), finds each interface's subnet from its NAD (IPAM range, exclusions, gateway) or the subnets parameter, and returns free addresses that avoid gateways, network/broadcast addresses, exclusions and pod/service CIDRs. newIps in the result can be passed as is to manifest_patch_cucp_ips. Example: {\"cluster\":\"5g-edge\",\"interfaces\":[\"n2\",\"f1c\",\"e1\"],\"repos\":[{\"name\":\"cucp\",\"workdir\":\"/work/cucp\"}]}.",
+		Timeout:     2 * time.Minute,
+		Handler: func(ctx context.Context, cc *mcp.ServerSession, params *mcp.CallToolParamsFor[IPPlanAllocateParams]) (*mcp.CallToolResultFor[IPPlanAllocateResult], error) {
+			a := params.Arguments
+			var ifaces []string
+			for _, i := range a.Interfaces {
+				if i = strings.TrimSpace(i); i != "" {
+					ifaces = append(ifaces, i)
+				}
+			}
+			if len(ifaces) == 0 {
+				return toolErr[IPPlanAllocateResult](missingField("interfaces"))
+			}
+			cluster := strings.TrimSpace(a.Cluster)
+			if cluster == "" && len(a.Repos) == 0 {
+				return toolErr[IPPlanAllocateResult](invalidArgument("cluster", "nothing to plan against: give a cluster, repos or both"))
+			}
+			family := strings.TrimSpace(a.Family)
+			if family != "" && family != familyIPv4 && family != familyIPv6 {
+				return toolErr[IPPlanAllocateResult](invalidArgument("family", "unknown family %q (ipv4 or ipv6)", family))
+			}
+			count := a.Count
+			if count <= 0 {
+				count = 1
+			}
+			if count > maxPlanCount {
+				return toolErr[IPPlanAllocateResult](invalidArgument("count", "at most %d addresses per interface", maxPlanCount))
+			}
+			subnets := map[string][]netip.Prefix{}
+			for iface, list := range a.Subnets {
+				iface = strings.TrimSpace(iface)
+				for _, s := range list {
+					p, err := netip.ParsePrefix(strings.TrimSpace(s))
+					if err != nil {
+						return toolErr[IPPlanAllocateResult](invalidArgument("subnets."+iface, "%q is not a CIDR", s))
+					}
+					subnets[iface] = append(subnets[iface], p.Masked())
+				}
+			}
+			var exclude []netip.Prefix
+			for _, s := range a.Exclude {
+				p, err := parseAddrOrPrefix(s)
+				if err != nil {
+					return toolErr[IPPlanAllocateResult](invalidArgument("exclude", "%q is not an address or CIDR", s))
+				}
+				exclude = append(exclude, p)
+			}
+
+			out := IPPlanAllocateResult{Cluster: cluster, NewIPs: map[string]IPInfo{}}
+			var inv addrInventory
+			if cluster != "" {
+				c, err := clusterClients(ctx, a.Context, cluster)
+				if err != nil {
+					return toolErr[IPPlanAllocateResult](err)
+				}
+				inv.addCluster(ctx, cluster, c, strings.TrimSpace(a.Namespace), out.addIssue)
+			}
+			for _, r := range a.Repos {
+				r.Name, r.Workdir = strings.TrimSpace(r.Name), cleanPath(r.Workdir)
+				if r.Workdir != "" {
+					inv.addRepo(r, out.addIssue)
+				}
+			}
+
+			used := inv.usedSet()
+			out.UsedAddresses = len(used)
+			for _, rc := range inv.reserved {
+				if rc.kind == "pod" {
+					out.PodCIDRs = append(out.PodCIDRs, rc.prefix.String())
+				} else {
+					out.ServiceCIDRs = append(out.ServiceCIDRs, rc.prefix.String())
+				}
+			}
+
+			for _, iface := range ifaces {
+				pi := PlannedInterface{Name: iface}
+				cands := inv.subnetCandidates(iface, subnets[iface])
+				ip := IPInfo{}
+				for _, fam := range []string{familyIPv4, familyIPv6} {
+					if family != "" && fam != family {
+						continue
+					}
+					c, others := pickCandidate(cands, fam)
+					if c == nil {
+						continue
+					}
+					ps := inv.planSubnet(iface, *c, exclude, used, count)
+					for _, o := range others {
+						ps.Warnings = append(ps.Warnings, fmt.Sprintf("%s from %s differs from the subnet used", o.prefix, o.source))
+					}
+					if len(ps.Addresses) > 0 {
+						ag := &AddrGateway{Address: ps.Addresses[0], Gateway: ps.Gateway}
+						if fam == familyIPv6 {
+							ip.IPv6 = ag
+						} else {
+							ip.IPv4 = ag
+						}
+					}
+					pi.Subnets = append(pi.Subnets, ps)
+				}
+				if len(pi.Subnets) == 0 {
+					pi.Error = &ToolError{
+						Code:    CodeNotFound,
+						Message: fmt.Sprintf("no subnet found for interface %s", iface),
+						Target:  iface,
+						Hint:    fmt.Sprintf("pass it in subnets.%s, or include the repo holding its NAD", iface),
+					}
+				}
+				if ip.IPv4 != nil || ip.IPv6 != nil {
+					out.NewIPs[iface] = ip
+				}
+				out.Interfaces = append(out.Interfaces, pi)
+			}
+			return toolOK(out), nil
+		},
+	}
+}
+
+// subnetCandidate is a subnet an interface could be in and what says so.
+type subnetCandidate struct {
+	prefix     netip.Prefix
+	start, end netip.Addr // IPAM range bounds, when the NAD has them
+	gateways   []string
+	exclude    []string
+	source     string
+	repo       bool
+}
+
+// subnetCandidates lists the subnets of iface, most authoritative first: the
+// subnets parameter, then NAD IPAM ranges and static addresses, then the
+// prefixes of addresses declared on the interface; repo ones before cluster
+// ones.
+func (inv *addrInventory) subnetCandidates(iface string, explicit []netip.Prefix) []subnetCandidate {
+	var out []subnetCandidate
+	for _, p := range explicit {
+		out = append(out, subnetCandidate{prefix: p, source: "param"})
+	}
+	for _, n := range inv.nadsFor(iface) {
+		src := "nad " + n.loc.where()
+		var gws, excl []string
+		if n.iface.NAD != nil {
+			gws, excl = n.iface.NAD.Gateways, n.iface.NAD.Exclude
+			for _, r := range n.iface.NAD.Ranges {
+				p, err := netip.ParsePrefix(r.Range)
+				if err != nil {
+					continue
+				}
+				c := subnetCandidate{prefix: p.Masked(), gateways: gws, exclude: excl, source: src}
+				c.start, _ = netip.ParseAddr(r.RangeStart)
+				c.end, _ = netip.ParseAddr(r.RangeEnd)
+				out = append(out, c)
+			}
+		}
+		for _, s := range n.iface.CIDRs {
+			if p, err := netip.ParsePrefix(s); err == nil && p.Addr() != p.Masked().Addr() {
+				out = append(out, subnetCandidate{prefix: p.Masked(), gateways: gws, exclude: excl, source: src})
+			}
+		}
+	}
+	var declared []subnetCandidate
+	for _, u := range inv.uses {
+		if u.Role != roleAddress || u.CIDR == "" || !networkNameMatches(u.Interface, iface) {
+			continue
+		}
+		if p, err := netip.ParsePrefix(u.CIDR); err == nil && p.Bits() < p.Addr().BitLen() {
+			declared = append(declared, subnetCandidate{prefix: p.Masked(), source: "interface " + u.where(), repo: u.Repo != ""})
+		}
+	}
+	// repos hold the state being planned; the cluster what is running now
+	sort.SliceStable(declared, func(i, j int) bool { return declared[i].repo && !declared[j].repo })
+	return append(out, declared...)
+}
+
+// pickCandidate returns the first candidate of family and the later ones
+// naming a different subnet.
+func pickCandidate(cands []subnetCandidate, family string) (*subnetCandidate, []subnetCandidate) {
+	var first *subnetCandidate
+	var others []subnetCandidate
+	seen := map[netip.Prefix]bool{}
+	for i := range cands {
+		c := &cands[i]
+		if addrFamily(c.prefix.String()) != family {
+			continue
+		}
+		if first == nil {
+			first = c
+			seen[c.prefix] = true
+			continue
+		}
+		if !seen[c.prefix] {
+			seen[c.prefix] = true
+			others = append(others, *c)
+		}
+	}
+	return first, others
+}
+
+// planSubnet picks count free addresses of c, lowest first, and marks them
+// used so later interfaces sharing the subnet get others.
+func (inv *addrInventory) planSubnet(iface string, c subnetCandidate, exclude []netip.Prefix, used map[netip.Addr]bool, count int) PlannedSubnet {
+	p := c.prefix
+	ps := PlannedSubnet{Family: addrFamily(p.String()), Subnet: p.String(), Source: c.source}
+	fail := func(code ErrorCode, format string, args ...any) PlannedSubnet {
+		ps.Error = &ToolError{Code: code, Message: fmt.Sprintf(format, args...), Target: iface}
+		return ps
+	}
+
+	// usable hosts: no network address, and no broadcast on IPv4 below /31
+	first, last := p.Addr(), lastAddr(p)
+	if p.Bits() < p.Addr().BitLen()-1 {
+		first = first.Next()
+		if p.Addr().Is4() {
+			last = last.Prev()
+		}
+	}
+	if c.start.IsValid() && p.Contains(c.start) && c.start.Compare(first) > 0 {
+		first = c.start
+		ps.RangeStart = c.start.String()
+	}
+	if c.end.IsValid() && p.Contains(c.end) && c.end.Compare(last) < 0 {
+		last = c.end
+		ps.RangeEnd = c.end.String()
+	}
+
+	// gateway: from the NAD, else one configured in the subnet, else the first host
+	var gw netip.Addr
+	for _, g := range c.gateways {
+		if a, err := netip.ParseAddr(g); err == nil && p.Contains(a) {
+			gw, ps.GatewaySource = a, gatewayFromNAD
+			break
+		}
+	}
+	if !gw.IsValid() {
+		var gws []netip.Addr
+		for _, u := range inv.uses {
+			if a, err := netip.ParseAddr(u.Address); err == nil && u.Role == roleGateway && p.Contains(a) {
+				if networkNameMatches(u.Interface, iface) {
+					gws = append([]netip.Addr{a}, gws...)
+				} else {
+					gws = append(gws, a)
+				}
+			}
+		}
+		if len(gws) > 0 {
+			gw, ps.GatewaySource = gws[0], gatewayFromDeclared
+		}
+	}
+	if !gw.IsValid() {
+		gw, ps.GatewaySource = p.Addr().Next(), gatewayAssumed
+		ps.Warnings = append(ps.Warnings, fmt.Sprintf("no gateway configured in %s; assumed %s", p, gw))
+	}
+	ps.Gateway = gw.String()
+
+	blocked := append([]netip.Prefix(nil), exclude...)
+	for _, s := range c.exclude {
+		if b, err := parseAddrOrPrefix(s); err == nil {
+			blocked = append(blocked, b)
+		}
+	}
+	for _, rc := range inv.reserved {
+		if !rc.prefix.Overlaps(p) {
+			continue
+		}
+		if rc.prefix.Bits() <= p.Bits() {
+			return fail(CodeConflict, "subnet %s is inside the %s %s CIDR %s", p, rc.cluster, rc.kind, rc.prefix)
+		}
+		ps.Warnings = append(ps.Warnings, fmt.Sprintf("subnet %s overlaps the %s %s CIDR %s; those addresses are skipped", p, rc.cluster, rc.kind, rc.prefix))
+		blocked = append(blocked, rc.prefix)
+	}
+	sort.Slice(blocked, func(i, j int) bool { return blocked[i].Addr().Less(blocked[j].Addr()) })
+
+	a := first
+	for steps := 0; a.IsValid() && a.Compare(last) <= 0 && len(ps.Addresses) < count && steps < maxPlanSteps; steps++ {
+		if b, ok := blockedBy(blocked, a); ok {
+			a = lastAddr(b).Next()
+			continue
+		}
+		if a != gw && !used[a] {
+			ps.Addresses = append(ps.Addresses, netip.PrefixFrom(a, p.Bits()).String())
+			used[a] = true
+		}
+		a = a.Next()
+	}
+	if len(ps.Addresses) == 0 {
+		return fail(CodeConflict, "no free address left in %s", p)
+	}
+	if len(ps.Addresses) < count {
+		ps.Warnings = append(ps.Warnings, fmt.Sprintf("only %d of %d addresses free in %s", len(ps.Addresses), count, p))
+	}
+	return ps
+}
+
+func blockedBy(blocked []netip.Prefix, a netip.Addr) (netip.Prefix, bool) {
+	for _, b := range blocked {
+		if b.Contains(a) {
+			return b, true
+		}
+	}
+	return netip.Prefix{}, false
+}
@@ -0,0 +1,187 @@
+package tools
+
+import (
+	"net/netip"
+	"sort"
+	"strings"
+)
+
+// Address families as reported in AddrEntry.Family.
+const (
+	familyIPv4 = "ipv4"
+	familyIPv6 = "ipv6"
+)
+
+// AddrEntry is one address or CIDR with its family.
+type AddrEntry struct {
+	Address string `json:"address"`
+	Family  string `json:"family"` // ipv4 or ipv6
+	CIDR    bool   `json:"cidr,omitempty"`
+}
+
+// addrFamily returns the family of an IP or CIDR, or "" if s is neither.
+func addrFamily(s string) string {
+	s = strings.TrimSpace(s)
+	var a netip.Addr
+	if p, err := netip.ParsePrefix(s); err == nil {
+		a = p.Addr()
+	} else if a, err = netip.ParseAddr(s); err != nil {
+		return ""
+	}
+	if a.Unmap().Is4() {
+		return familyIPv4
+	}
+	return familyIPv6
+}
+
+func isCIDR(s string) bool {
+	_, err := netip.ParsePrefix(strings.TrimSpace(s))
+	return err == nil
+}
+
+func isIP(s string) bool {
+	_, err := netip.ParseAddr(strings.TrimSpace(s))
+	return err == nil
+}
+
+// addrTokenSep splits free text into candidate address tokens. ':' '.' '/' and
+// '%' stay inside tokens so IPv6 addresses, prefixes and zones survive.
+func addrTokenSep(r rune) bool {
+	switch {
+	case r >= '0' && r <= '9', r >= 'a' && r <= 'f', r >= 'A' && r <= 'F':
+		return false
+	case r == ':', r == '.', r == '/', r == '%':
+		return false
+	}
+	return true
+}
+
+// findAddrs returns the CIDRs and IPs of both families found in s. The address
+// of a CIDR is returned as an IP too, and host:port, [v6]:port and URLs yield
+// the IP; zones are dropped.
+func findAddrs(s string) (cidrs, ips []string) {
+	for i := 0; i < len(s); {
+		if addrTokenSep(rune(s[i])) {
+			i++
+			continue
+		}
+		j := i
+		for j < len(s) && !addrTokenSep(rune(s[j])) {
+			j++
+		}
+		tok := s[i:j]
+		glued := i > 0 && isWordByte(s[i-1])
+		i = j
+		if !strings.ContainsAny(tok, ".:") {
+			continue
+		}
+		// hex letters stay in tokens, so the end of a scheme or key can be
+		// glued on (c://10.0.0.5:8080/ from sctp://, f:10.0.0.1 from
+		// amf:10.0.0.1, 6:fd00::1 from ipv6:fd00::1): try what follows each
+		// ':' or '/', first when the token continues a word
+		var cands []string
+		for k := 1; k < len(tok); k++ {
+			if tok[k-1] == ':' || tok[k-1] == '/' {
+				cands = append(cands, tok[k:])
+			}
+		}
+		if glued {
+			cands = append(cands, tok)
+		} else {
+			cands = append([]string{tok}, cands...)
+		}
+		for _, t := range cands {
+			if c, ip, ok := parseAddrToken(t); ok {
+				if c != "" {
+					cidrs = append(cidrs, c)
+				}
+				ips = append(ips, ip)
+				break
+			}
+		}
+	}
+	return cidrs, ips
+}
+
+func isWordByte(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_'
+}
+
+// parseAddrToken parses tok as a CIDR, IP or IP:port, also without trailing
+// punctuation ("... via 10.0.0.1.", the / ending a URL's host).
+func parseAddrToken(tok string) (cidr, ip string, ok bool) {
+	for _, t := range []string{tok, strings.TrimRight(tok, ".:/")} {
+		if p, err := netip.ParsePrefix(t); err == nil {
+			return p.String(), p.Addr().String(), true
+		}
+		if a, err := netip.ParseAddr(t); err == nil {
+			return "", a.WithZone("").String(), true
+		}
+		if ap, err := netip.ParseAddrPort(t); err == nil {
+			return "", ap.Addr().WithZone("").String(), true
+		}
+	}
+	return "", "", false
+}
+
+// addrEntries lists cidrs then ips with their families.
+func addrEntries(cidrs, ips []string) []AddrEntry {
+	out := make([]AddrEntry, 0, len(cidrs)+len(ips))
+	for _, c := range cidrs {
+		if f := addrFamily(c); f != "" {
+			out = append(out, AddrEntry{Address: c, Family: f, CIDR: true})
+		}
+	}
+	for _, ip := range ips {
+		if f := addrFamily(ip); f != "" {
+			out = append(out, AddrEntry{Address: ip, Family: f})
+		}
+	}
+	return out
+}
+
+// finishInterface dedups and sorts the addresses of iface and fills Addresses.
+func finishInterface(iface *NetworkInterface) {
+	iface.CIDRs = dedupSorted(iface.CIDRs)
+	iface.IPs = dedupSorted(iface.IPs)
+	iface.Addresses = addrEntries(iface.CIDRs, iface.IPs)
+}
+
+func dedupSorted(in []string) []string {
+	if len(in) == 0 {
+		return in
+	}
+	sort.Strings(in)
+	out := in[:1]
+	for _, s := range in[1:] {
+		if s != out[len(out)-1] {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// lastAddr returns the highest address of p (the IPv4 broadcast address).
+func lastAddr(p netip.Prefix) netip.Addr {
+	p = p.Masked()
+	b := p.Addr().AsSlice()
+	for i := p.Bits(); i < len(b)*8; i++ {
+		b[i/8] |= 0x80 >> (i % 8)
+	}
+	a, _ := netip.AddrFromSlice(b)
+	return a
+}
+
+// parseAddrOrPrefix parses a CIDR, or an IP as its single-address prefix.
+func parseAddrOrPrefix(s string) (netip.Prefix, error) {
+	s = strings.TrimSpace(s)
+	if p, err := netip.ParsePrefix(s); err == nil {
+		return p.Masked(), nil
+	}
+	a, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	a = a.Unmap()
+	return netip.PrefixFrom(a, a.BitLen()), nil
+}
@@ -0,0 +1,39 @@
+package tools
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFindAddrs(t *testing.T) {
+	tests := []struct {
+		in         string
+		cidrs, ips string
+	}{
+		{"10.0.0.1", "", "10.0.0.1"},
+		{"fd00::1", "", "fd00::1"},
+		{"10.0.0.5/24", "10.0.0.5/24", "10.0.0.5"},
+		{"fd00:10::/64", "fd00:10::/64", "fd00:10::"},
+		{"via 10.0.0.1.", "", "10.0.0.1"},
+		{"10.0.0.5:38412", "", "10.0.0.5"},
+		{"[fd00::5]:2152", "", "fd00::5"},
+		{"fe80::1%eth0", "", "fe80::1"},
+		{"http://10.0.0.5:8080/nnrf", "", "10.0.0.5"},
+		{"sctp://192.168.1.5:38412", "", "192.168.1.5"},
+		{"http://[fd00::5]:8080/", "", "fd00::5"},
+		{"amf:10.0.0.1", "", "10.0.0.1"},
+		{"address:10.0.0.1", "", "10.0.0.1"},
+		{"ipv6:fd00::1", "", "fd00::1"},
+		{"amf=10.0.0.1,smf=10.0.0.2", "", "10.0.0.1 10.0.0.2"},
+		{"version 1.2.3, 12:30:45, aa:bb:cc:dd:ee:ff", "", ""},
+	}
+	for _, tt := range tests {
+		cidrs, ips := findAddrs(tt.in)
+		if got := strings.Join(cidrs, " "); got != tt.cidrs {
+			t.Errorf("findAddrs(%q) cidrs = %q; want %q", tt.in, got, tt.cidrs)
+		}
+		if got := strings.Join(ips, " "); got != tt.ips {
+			t.Errorf("findAddrs(%q) ips = %q; want %q", tt.in, got, tt.ips)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"nfreconfig-mcp-server/internal/config"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// Shutdown sequence (driven by cmd/server):
+//
+//  1. Drain: new tool calls are rejected with UNAVAILABLE; in-flight calls keep
+//     running until they finish or the drain deadline passes.
+//  2. Past the deadline, in-flight calls are cancelled. Subprocesses get SIGTERM
+//     first so git can release its locks before being killed.
+//  3. Cleanup hooks remove temporary files (askpass scripts, .tmp manifests)
+//     that interrupted calls left behind.
+
+var (
+	draining atomic.Bool
+	inflight atomic.Int64
+
+	// hardStop is cancelled when the drain deadline passes.
+	hardStop, cancelHardStop = context.WithCancel(context.Background())
+)
+
+// subprocessGrace is how long a cancelled subprocess has between SIGTERM and SIGKILL.
+const subprocessGrace = 10 * time.Second
+
+// withLifecycle tracks the call for draining and bounds it by the tool timeout.
+// MCP cancellation (notifications/cancelled) already cancels ctx in the SDK.
+func withLifecycle[I, O any](name string, timeout time.Duration, h func(context.Context, *mcp.ServerSession, *mcp.CallToolParamsFor[I]) (*mcp.CallToolResultFor[O], error)) func(context.Context, *mcp.ServerSession, *mcp.CallToolParamsFor[I]) (*mcp.CallToolResultFor[O], error) {
+	return func(ctx context.Context, cc *mcp.ServerSession, params *mcp.CallToolParamsFor[I]) (*mcp.CallToolResultFor[O], error) {
+		inflight.Add(1)
+		defer inflight.Add(-1)
+		if draining.Load() {
+			return nil, &ToolError{
+				Code:      CodeUnavailable,
+				Message:   "server is shutting down",
+				Retryable: true,
+				Target:    name,
+				Hint:      "retry against a new server instance",
+			}
+		}
+
+		ctx, cancel := context.WithTimeout(ctx, config.Current().ToolTimeout(name, timeout))
+		defer cancel()
+		stop := context.AfterFunc(hardStop, cancel)
+		defer stop()
+
+		return h(ctx, cc, params)
+	}
+}
+
+// Drain stops accepting tool calls and waits for in-flight ones. When ctx ends
+// first, the remaining calls are cancelled and given subprocessGrace to return.
+// Cleanup hooks run in either case.
+func Drain(ctx context.Context) error {
+	draining.Store(true)
+	defer runCleanup()
+
+	if waitIdle(ctx) {
+		return nil
+	}
+	n := inflight.Load()
+	cancelHardStop()
+	grace, cancel := context.WithTimeout(context.Background(), subprocessGrace+time.Second)
+	defer cancel()
+	waitIdle(grace)
+	return fmt.Errorf("drain: cancelled %d in-flight tool call(s): %w", n, ctx.Err())
+}
+
+// waitIdle reports whether all tool calls finished before ctx ended.
+func waitIdle(ctx context.Context) bool {
+	t := time.NewTicker(100 * time.Millisecond)
+	defer t.Stop()
+	for inflight.Load() > 0 {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-t.C:
+		}
+	}
+	return true
+}
+
+// ---------------- cleanup hooks ----------------
+
+var (
+	tempMu    sync.Mutex
+	tempFiles = map[string]struct{}{}
+)
+
+// trackTempFile registers path for removal on shutdown. The returned func
+// removes the file and unregisters it; call it once the file is no longer needed
+// (or has been renamed away, in which case the remove is a no-op).
+func trackTempFile(path string) func() {
+	tempMu.Lock()
+	tempFiles[path] = struct{}{}
+	tempMu.Unlock()
+	return func() {
+		_ = os.Remove(path)
+		tempMu.Lock()
+		delete(tempFiles, path)
+		tempMu.Unlock()
+	}
+}
+
+func runCleanup() {
+	tempMu.Lock()
+	defer tempMu.Unlock()
+	for p := range tempFiles {
+		_ = os.Remove(p)
+		delete(tempFiles, p)
+	}
+}
+
+// ---------------- subprocesses ----------------
+
+// commandContext is exec.CommandContext with a gentler cancel: SIGTERM, then
+// SIGKILL after subprocessGrace. A SIGKILLed git push can leave index.lock and
+// half-written refs behind.
+func commandContext(ctx context.Context, name string, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Cancel = func() error { return cmd.Process.Signal(syscall.SIGTERM) }
+	cmd.WaitDelay = subprocessGrace
+	return cmd
+}
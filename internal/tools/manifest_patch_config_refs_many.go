@@ -3,7 +3,9 @@ package tools
 import (
 	"context"
 	"fmt"
+	"net/netip"
 	"path/filepath"
+	"sort"
 	"strings"
 
 	"github.com/modelcontextprotocol/go-sdk/mcp"
@@ -12,30 +14,78 @@ import (
 func init() { registerTool(ManifestPatchConfigRefsMany()) }
 
 type ManifestPatchConfigRefsManyParams struct {
-	Targets    []PatchTarget     `json:"targets"`    // DU/CUUP Config YAMLs (kind=Config)
-	OldNeedles []string          `json:"oldNeedles"` // strings to replace (e.g., old CUCP IPs/CIDRs you extracted)
-	NewRepl    map[string]string `json:"newRepl"`    // map old->new (explicit replacements)
-	DryRun     bool              `json:"dryRun,omitempty"`
+	Targets    []PatchTarget     `json:"targets" description:"DU/CUUP Config manifests (kind=Config) to update."`
+	OldNeedles []string          `json:"oldNeedles,omitempty" description:"Old values (IPs, CIDRs, hostnames) that must not remain after patching; checked in every target together with the newRepl keys. Without newRepl, only reports where they occur."`
+	NewRepl    map[string]string `json:"newRepl,omitempty" description:"Replacements, old value -> new value: IP -> IP, CIDR -> CIDR, hostname -> hostname or IP. Whole tokens only, so 10.10.1.5 leaves 10.10.1.50 alone; an IP is also replaced in host:port, URLs and CIDRs (10.10.1.5/24 keeps its /24). All replacements apply in one pass, so {A:B, B:C} moves A to B and B to C; cycles are rejected." example:"{\"10.10.1.5\":\"10.10.1.10\"}"`
+	OnResidual string            `json:"onResidual,omitempty" description:"When an old value remains after patching: warn reports it; fail leaves the file unwritten with a CONFLICT error." enum:"warn,fail" default:"warn"`
+	DryRun     bool              `json:"dryRun,omitempty" description:"Report changes without writing files."`
+}
+
+// ResidualRef is an old value still present in a target.
+type ResidualRef struct {
+	Needle string `json:"needle"`
+	Path   string `json:"path"`  // field holding it, prefixed with [n] for document n of a multi-document file
+	Match  string `json:"match"` // the token as written
+}
+
+type ConfigRefsPatchResult struct {
+	Repo    string     `json:"repo"`
+	File    string     `json:"file"`
+	Changed bool       `json:"changed"`
+	Error   *ToolError `json:"error,omitempty"`
+	// Replacements counts the occurrences replaced per old value
+	Replacements map[string]int `json:"replacements,omitempty"`
+	// Residual lists the old values left after patching
+	Residual []ResidualRef `json:"residual,omitempty"`
 }
 
 type ManifestPatchConfigRefsManyResult struct {
-	Results []PatchResult `json:"results"`
+	Results  []ConfigRefsPatchResult `json:"results"`
+	Counts   map[string]int          `json:"counts"` // occurrences replaced per old value, over all targets
+	Warnings []string                `json:"warnings,omitempty"`
 }
 
 func ManifestPatchConfigRefsMany() MCPTool[ManifestPatchConfigRefsManyParams, ManifestPatchConfigRefsManyResult] {
 	return MCPTool[ManifestPatchConfigRefsManyParams, ManifestPatchConfigRefsManyResult]{
 		Name:        "manifest_patch_config_refs",
-		Description: "Update DU/CUUP Config manifests that reference old CUCP IPs. Use in Phase 4 to propagate CUCP changes to dependent DU/CUUP. Performs string replacement across all YAML fields. Example: {\"targets\":[{\"repo\":\"du\",\"workdir\":\"/work/du\",\"file\":\"config.yaml\"}], \"newRepl\":{\"10.10.1.5\":\"10.10.1.10\",\"192.168.10.0/24\":\"192.168.20.0/24\"}}.",
+		Description: "Update DU/CUUP Config manifests that reference old CUCP IPs. Use in Phase 4 to propagate CUCP changes to dependent DU/CUUP; repo_find_references gives the targets. Replaces whole IP/CIDR/hostname tokens in all string fields of every YAML document in the file (10.10.1.5 never touches 10.10.1.50), in one deterministic pass, counts replacements per old value, and verifies that no old value (newRepl keys and oldNeedles) remains. Example: {\"targets\":[{\"repo\":\"du\",\"workdir\":\"/work/du\",\"file\":\"config.yaml\"}], \"newRepl\":{\"10.10.1.5\":\"10.10.1.10\",\"192.168.10.0/24\":\"192.168.20.0/24\"}, \"onResidual\":\"fail\"}.",
 		Handler: func(ctx context.Context, cc *mcp.ServerSession, params *mcp.CallToolParamsFor[ManifestPatchConfigRefsManyParams]) (*mcp.CallToolResultFor[ManifestPatchConfigRefsManyResult], error) {
 			if len(params.Arguments.Targets) == 0 {
-				return toolErr[ManifestPatchConfigRefsManyResult](fmt.Errorf("missing required field: targets"))
+				return toolErr[ManifestPatchConfigRefsManyResult](missingField("targets"))
 			}
 			if len(params.Arguments.NewRepl) == 0 && len(params.Arguments.OldNeedles) == 0 {
-				return toolErr[ManifestPatchConfigRefsManyResult](fmt.Errorf("need at least one of: newRepl or oldNeedles"))
+				return toolErr[ManifestPatchConfigRefsManyResult](invalidArgument("newRepl", "need at least one of: newRepl or oldNeedles"))
+			}
+			failResidual := false
+			switch strings.TrimSpace(params.Arguments.OnResidual) {
+			case "", "warn":
+			case "fail":
+				failResidual = true
+			default:
+				return toolErr[ManifestPatchConfigRefsManyResult](invalidArgument("onResidual", "must be warn or fail, got %q", params.Arguments.OnResidual))
 			}
 
-			out := ManifestPatchConfigRefsManyResult{Results: make([]PatchResult, 0, len(params.Arguments.Targets))}
-			repl := params.Arguments.NewRepl
+			reps, warnings, err := parseReplacements(params.Arguments.NewRepl)
+			if err != nil {
+				return toolErr[ManifestPatchConfigRefsManyResult](err)
+			}
+			olds := make([]needle, len(reps))
+			for i, r := range reps {
+				olds[i] = r.old
+			}
+			verify, err := residualNeedles(reps, params.Arguments.OldNeedles)
+			if err != nil {
+				return toolErr[ManifestPatchConfigRefsManyResult](err)
+			}
+
+			out := ManifestPatchConfigRefsManyResult{
+				Results:  make([]ConfigRefsPatchResult, 0, len(params.Arguments.Targets)),
+				Counts:   map[string]int{},
+				Warnings: warnings,
+			}
+			for _, r := range reps {
+				out.Counts[r.old.raw] = 0
+			}
 
 			for _, t := range params.Arguments.Targets {
 				repo := strings.TrimSpace(t.Repo)
@@ -43,42 +93,78 @@ func ManifestPatchConfigRefsMany() MCPTool[ManifestPatchConfigRefsManyParams, Ma
 				file := filepath.ToSlash(strings.TrimSpace(t.File))
 				abs := absJoin(workdir, file)
 
-				r := PatchResult{Repo: repo, File: file}
+				r := ConfigRefsPatchResult{Repo: repo, File: file}
 
-				u, _, err := readYAMLFile(abs)
+				docs, err := readYAMLDocuments(abs)
 				if err != nil {
-					r.Error = fmt.Sprintf("read yaml: %v", err)
+					r.Error = toolError(fmt.Errorf("read yaml: %w", err), file)
 					out.Results = append(out.Results, r)
 					continue
 				}
 
-				obj := u.Object
 				changed := false
+				hits := map[string]int{}
 
-				// Replace across all string fields
-				walkAny(obj, func(_ []string, key string, parent map[string]any, val any) {
-					s, ok := val.(string)
-					if !ok || s == "" {
-						return
+				// Replace whole tokens across all string fields of every
+				// document, lists included
+				if len(reps) > 0 {
+					for _, obj := range docs {
+						rewriteStrings(obj, "", func(_ string, s string) string {
+							ns := replaceTokens(s, reps, olds, hits)
+							if ns != s {
+								changed = true
+							}
+							return ns
+						})
 					}
-					orig := s
+				}
+				for old, n := range hits {
+					out.Counts[old] += n
+				}
+				if len(hits) > 0 {
+					r.Replacements = hits
+				}
 
-					// explicit old->new replacements
-					for old, nw := range repl {
-						if old != "" && strings.Contains(s, old) {
-							s = strings.ReplaceAll(s, old, nw)
-						}
+				// Old values left behind, e.g. in a form the patch does not rewrite
+				for i, obj := range docs {
+					root := ""
+					if len(docs) > 1 {
+						root = fmt.Sprintf("[%d]", i)
 					}
-					// optional needle-only mode (replace with nothing? we don't do that)
-					if s != orig {
-						parent[key] = s
-						changed = true
+					rewriteStrings(obj, root, func(p string, s string) string {
+						for _, tm := range matchNeedles(s, verify) {
+							r.Residual = append(r.Residual, ResidualRef{Needle: verify[tm.needle].raw, Path: p, Match: s[tm.tokStart:tm.tokEnd]})
+						}
+						return s
+					})
+				}
+				sort.Slice(r.Residual, func(i, j int) bool {
+					if r.Residual[i].Path != r.Residual[j].Path {
+						return r.Residual[i].Path < r.Residual[j].Path
 					}
+					return r.Residual[i].Needle < r.Residual[j].Needle
 				})
+				if len(r.Residual) > 0 {
+					left := make([]string, 0, len(r.Residual))
+					for _, rr := range r.Residual {
+						left = append(left, rr.Needle+" at "+rr.Path)
+					}
+					if failResidual && len(reps) > 0 {
+						r.Error = &ToolError{
+							Code:    CodeConflict,
+							Message: "old values remain after patching: " + strings.Join(left, ", "),
+							Target:  file,
+							Hint:    "add them to newRepl, or patch the fields by hand; the file was not written",
+						}
+						out.Results = append(out.Results, r)
+						continue
+					}
+					out.Warnings = append(out.Warnings, fmt.Sprintf("%s:%s still references %s", repo, file, strings.Join(left, ", ")))
+				}
 
 				if changed && !params.Arguments.DryRun {
-					if err := writeYAMLFile(abs, obj); err != nil {
-						r.Error = fmt.Sprintf("write yaml: %v", err)
+					if err := writeYAMLDocuments(abs, docs); err != nil {
+						r.Error = toolError(fmt.Errorf("write yaml: %w", err), file)
 						out.Results = append(out.Results, r)
 						continue
 					}
@@ -87,7 +173,169 @@ func ManifestPatchConfigRefsMany() MCPTool[ManifestPatchConfigRefsManyParams, Ma
 				out.Results = append(out.Results, r)
 			}
 
+			for _, r := range reps {
+				if out.Counts[r.old.raw] == 0 {
+					out.Warnings = append(out.Warnings, fmt.Sprintf("%s was not found in any target", r.old.raw))
+				}
+			}
 			return toolOK(out), nil
 		},
 	}
 }
+
+// replacement is one newRepl entry.
+type replacement struct {
+	old needle
+	new string
+}
+
+// needleKey is the canonical form of a needle, so 10.10.1.5 and a
+// differently written IPv6 address compare equal.
+func needleKey(n needle) string {
+	switch {
+	case n.prefix.IsValid():
+		return n.prefix.String()
+	case n.addr.IsValid():
+		return n.addr.String()
+	}
+	return n.host
+}
+
+// parseReplacements validates newRepl and orders it, CIDRs before IPs before
+// hostnames, so the most specific needle claims a token. Replacement cycles
+// are an error; chains are reported as warnings since each occurrence is
+// replaced only once.
+func parseReplacements(m map[string]string) ([]replacement, []string, error) {
+	reps := make([]replacement, 0, len(m))
+	next := map[string]string{} // old key -> new key
+	for old, nw := range m {
+		n, err := parseNeedle(old)
+		if err != nil {
+			return nil, nil, invalidArgument("newRepl", "old value %q: need an IP, CIDR or hostname", old)
+		}
+		nw = strings.TrimSpace(nw)
+		nn, err := parseNeedle(nw)
+		switch {
+		case err != nil:
+			return nil, nil, invalidArgument("newRepl", "new value %q for %s: need an IP, CIDR or hostname", nw, old)
+		case n.addr.IsValid() && !nn.addr.IsValid():
+			return nil, nil, invalidArgument("newRepl", "new value %q for %s must be an IP: the prefix length or port around an IP is kept", nw, old)
+		case n.prefix.IsValid() && !nn.prefix.IsValid():
+			return nil, nil, invalidArgument("newRepl", "new value %q for %s must be a CIDR", nw, old)
+		}
+		k := needleKey(n)
+		if _, dup := next[k]; dup {
+			return nil, nil, invalidArgument("newRepl", "%s is given twice in different notations", old)
+		}
+		next[k] = needleKey(nn)
+		reps = append(reps, replacement{old: n, new: nw})
+	}
+	rank := func(n needle) int {
+		switch {
+		case n.prefix.IsValid():
+			return 0
+		case n.addr.IsValid():
+			return 1
+		}
+		return 2
+	}
+	sort.Slice(reps, func(i, j int) bool {
+		if ri, rj := rank(reps[i].old), rank(reps[j].old); ri != rj {
+			return ri < rj
+		}
+		return reps[i].old.raw < reps[j].old.raw
+	})
+
+	var warnings []string
+	for _, r := range reps {
+		start := needleKey(r.old)
+		chain := []string{start}
+		for k := next[start]; ; k = next[k] {
+			chain = append(chain, k)
+			if k == start {
+				return nil, nil, invalidArgument("newRepl", "replacement cycle: %s", strings.Join(chain, " -> "))
+			}
+			if _, ok := next[k]; !ok || len(chain) > len(next) {
+				break // end of the chain, or a cycle found from its own start
+			}
+		}
+		if len(chain) > 2 {
+			warnings = append(warnings, fmt.Sprintf("replacement chain %s: each occurrence is replaced once, so %s becomes %s", strings.Join(chain, " -> "), r.old.raw, r.new))
+		}
+	}
+	return reps, warnings, nil
+}
+
+// residualNeedles are the old values to look for after patching: the newRepl
+// keys and oldNeedles, except those that are new values too.
+func residualNeedles(reps []replacement, oldNeedles []string) ([]needle, error) {
+	isNew := map[string]bool{}
+	for _, r := range reps {
+		if nn, err := parseNeedle(r.new); err == nil {
+			isNew[needleKey(nn)] = true
+		}
+	}
+	seen := map[string]bool{}
+	var out []needle
+	add := func(n needle) {
+		if k := needleKey(n); !seen[k] && !isNew[k] {
+			seen[k] = true
+			out = append(out, n)
+		}
+	}
+	for _, r := range reps {
+		add(r.old)
+	}
+	for _, s := range oldNeedles {
+		if strings.TrimSpace(s) == "" {
+			continue
+		}
+		n, err := parseNeedle(s)
+		if err != nil {
+			return nil, invalidArgument("oldNeedles", "invalid needle %q: need an IP, CIDR or hostname", s)
+		}
+		add(n)
+	}
+	return out, nil
+}
+
+// replaceTokens replaces the whole tokens of s matching olds with the new
+// values of reps (the same order), counting hits per old value.
+func replaceTokens(s string, reps []replacement, olds []needle, hits map[string]int) string {
+	tms := matchNeedles(s, olds)
+	if len(tms) == 0 {
+		return s
+	}
+	var b strings.Builder
+	last := 0
+	for _, tm := range tms {
+		b.WriteString(s[last:tm.start])
+		nw := reps[tm.needle].new
+		if a, err := netip.ParseAddr(nw); err == nil && a.Is6() && tm.end < tm.tokEnd && s[tm.end] == ':' {
+			nw = "[" + nw + "]" // an IPv6 host:port needs brackets
+		}
+		b.WriteString(nw)
+		last = tm.end
+		hits[reps[tm.needle].old.raw]++
+	}
+	b.WriteString(s[last:])
+	return b.String()
+}
+
+// rewriteStrings replaces every string in v, lists included, with fn of it
+// and its field path.
+func rewriteStrings(v any, p string, fn func(p, s string) string) any {
+	switch x := v.(type) {
+	case map[string]any:
+		for k, c := range x {
+			x[k] = rewriteStrings(c, pathKey(p, k), fn)
+		}
+	case []any:
+		for i, c := range x {
+			x[i] = rewriteStrings(c, pathIndex(p, i), fn)
+		}
+	case string:
+		return fn(p, x)
+	}
+	return v
+}
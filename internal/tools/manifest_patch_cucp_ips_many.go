@@ -15,30 +15,114 @@ import (
 func init() { registerTool(ManifestPatchCucpIPsMany()) }
 
 type IPInfo struct {
-	Address string `json:"address,omitempty"` // CIDR e.g. 192.168.10.88/24
-	Gateway string `json:"gateway,omitempty"` // e.g. 192.168.10.1
+	Address string `json:"address,omitempty" description:"Interface address in CIDR form, IPv4 or IPv6; shorthand for the ipv4 or ipv6 block of its family." example:"192.168.10.88/24"`
+	Gateway string `json:"gateway,omitempty" description:"Gateway IP, same family as address." example:"192.168.10.1"`
+
+	IPv4 *AddrGateway `json:"ipv4,omitempty" description:"IPv4 addressing of the interface."`
+	IPv6 *AddrGateway `json:"ipv6,omitempty" description:"IPv6 addressing of the interface (dual-stack)."`
+}
+
+// AddrGateway is the addressing of one family of an interface.
+type AddrGateway struct {
+	Address string `json:"address,omitempty" description:"Interface address in CIDR form." example:"fd00:10:3::10/64"`
+	Gateway string `json:"gateway,omitempty" description:"Gateway IP." example:"fd00:10:3::1"`
+}
+
+// ifaceAddrs is an IPInfo resolved per family.
+type ifaceAddrs struct {
+	v4, v6 AddrGateway
+}
+
+// forFamily returns the addressing for family; "" (value of unknown family)
+// picks IPv4 unless only IPv6 was given.
+func (a ifaceAddrs) forFamily(family string) AddrGateway {
+	if family == familyIPv6 || (family == "" && a.v4 == (AddrGateway{})) {
+		return a.v6
+	}
+	return a.v4
+}
+
+// resolveIPInfo checks the addressing of iface and sorts it by family.
+func resolveIPInfo(iface string, ip IPInfo) (ifaceAddrs, error) {
+	return resolveIPInfoAt("newIps", iface, ip)
+}
+
+// resolveIPInfoAt is resolveIPInfo for addressing given in parameter field.
+func resolveIPInfoAt(field, iface string, ip IPInfo) (ifaceAddrs, error) {
+	var out ifaceAddrs
+	set := func(field string, ag AddrGateway, want string) error {
+		if ag == (AddrGateway{}) {
+			return nil
+		}
+		if ag.Address != "" && !isCIDR(ag.Address) {
+			return invalidArgument(field+".address", "%q is not a CIDR (address/prefix)", ag.Address)
+		}
+		if ag.Gateway != "" && !isIP(ag.Gateway) {
+			return invalidArgument(field+".gateway", "%q is not an IP address", ag.Gateway)
+		}
+		fam := addrFamily(ag.Address)
+		if fam == "" {
+			fam = addrFamily(ag.Gateway)
+		}
+		if g := addrFamily(ag.Gateway); ag.Address != "" && g != "" && g != fam {
+			return invalidArgument(field+".gateway", "gateway %s is %s but address %s is %s", ag.Gateway, g, ag.Address, fam)
+		}
+		if want != "" && fam != want {
+			return invalidArgument(field, "%s block holds %s addressing", want, fam)
+		}
+		dst := &out.v4
+		if fam == familyIPv6 {
+			dst = &out.v6
+		}
+		if *dst != (AddrGateway{}) {
+			return invalidArgument(field, "%s addressing given twice", fam)
+		}
+		*dst = ag
+		return nil
+	}
+	base := field + "." + iface
+	if err := set(base, AddrGateway{Address: strings.TrimSpace(ip.Address), Gateway: strings.TrimSpace(ip.Gateway)}, ""); err != nil {
+		return out, err
+	}
+	if ip.IPv4 != nil {
+		if err := set(base+".ipv4", AddrGateway{Address: strings.TrimSpace(ip.IPv4.Address), Gateway: strings.TrimSpace(ip.IPv4.Gateway)}, familyIPv4); err != nil {
+			return out, err
+		}
+	}
+	if ip.IPv6 != nil {
+		if err := set(base+".ipv6", AddrGateway{Address: strings.TrimSpace(ip.IPv6.Address), Gateway: strings.TrimSpace(ip.IPv6.Gateway)}, familyIPv6); err != nil {
+			return out, err
+		}
+	}
+	if out == (ifaceAddrs{}) {
+		return out, invalidArgument(base, "no address or gateway given")
+	}
+	return out, nil
 }
 
 type PatchTarget struct {
-	Repo      string `json:"repo"`
-	Workdir   string `json:"workdir"`
-	File      string `json:"file"`
-	Kind      string `json:"kind,omitempty"` // optional, but helps
-	Name      string `json:"name,omitempty"`
-	Namespace string `json:"namespace,omitempty"`
+	Repo      string `json:"repo" description:"Repo name, as returned by git_clone_repos."`
+	Workdir   string `json:"workdir" description:"Cloned directory, as returned by git_clone_repos."`
+	File      string `json:"file" description:"Repo-relative path of the manifest."`
+	Kind      string `json:"kind,omitempty" description:"Object kind in the file; optional, selects the patch strategy." enum:"NFDeployment,NetworkAttachmentDefinition,Config,NFConfig,ConfigMap"`
+	Name      string `json:"name,omitempty" description:"Object name; optional."`
+	Namespace string `json:"namespace,omitempty" description:"Object namespace; optional."`
 }
 
 type ManifestPatchCucpIPsManyParams struct {
-	Targets []PatchTarget     `json:"targets"` // CUCP NFDeployment + CUCP NADs
-	NewIPs  map[string]IPInfo `json:"newIps"`  // keys: n2,f1c,e1 (or whatever you use)
-	DryRun  bool              `json:"dryRun,omitempty"`
+	Targets []PatchTarget     `json:"targets" description:"CUCP NFDeployment and NetworkAttachmentDefinition manifests."`
+	NewIPs  map[string]IPInfo `json:"newIps,omitempty" description:"New addressing per interface name (e.g. n2, f1c, e1); IPv4, IPv6 or both. Required unless ipClaims is given."`
+	DryRun  bool              `json:"dryRun,omitempty" description:"Report changes without writing files."`
+
+	IPClaims map[string][]string `json:"ipClaims,omitempty" description:"Nephio IPClaims (name or namespace/name) per interface name whose allocated prefix (status.prefix) and gateway become that interface's addressing, one claim per address family. Claims are read from the targets' workdirs, then from the management cluster." example:"{\"n3\":[\"upf-n3-v4\",\"upf-n3-v6\"]}"`
+	Context  string              `json:"context,omitempty" description:"Management cluster kube context for ipClaims; defaults to the -mgmt-context flag, then kube.managementContext from the server config, then the current context."`
 }
 
 type PatchResult struct {
-	Repo    string `json:"repo"`
-	File    string `json:"file"`
-	Changed bool   `json:"changed"`
-	Error   string `json:"error,omitempty"`
+	Repo    string     `json:"repo"`
+	File    string     `json:"file"`
+	Changed bool       `json:"changed"`
+	Error   *ToolError `json:"error,omitempty"`
 }
 
 type ManifestPatchCucpIPsManyResult struct {
@@ -48,13 +132,17 @@ type ManifestPatchCucpIPsManyResult struct {
 func ManifestPatchCucpIPsMany() MCPTool[ManifestPatchCucpIPsManyParams, ManifestPatchCucpIPsManyResult] {
 	return MCPTool[ManifestPatchCucpIPsManyParams, ManifestPatchCucpIPsManyResult]{
 		Name:        "manifest_patch_cucp_ips",
-		Description: "Update CUCP NFDeployment and NAD manifests with new IP allocations per interface. Use in Phase 3 to apply planned IPs to CUCP manifests. Patches address/gateway fields for each interface (n2, n3, n4, n6) including NAD spec.config JSON. Example: {\"targets\":[{\"repo\":\"cucp\",\"workdir\":\"/work/cucp\",\"file\":\"nfdeploy.yaml\",\"kind\":\"NFDeployment\"}], \"newIps\":{\"n2\":{\"address\":\"10.10.1.10/24\",\"gateway\":\"10.10.1.1\"}}}.",
+		Description: "Update CUCP NFDeployment and NAD manifests with new IP allocations per interface. Use in Phase 3 to apply planned IPs to CUCP manifests; with ipClaims the addressing is taken from Nephio IPClaim allocations instead of being typed in. Patches address/gateway fields for each interface (n2, n3, n4, n6) including NAD spec.config JSON, per address family: ipv4 and ipv6 blocks are patched separately and an ipv6 block is added next to ipv4 when IPv6 addressing is given. Example: {\"targets\":[{\"repo\":\"cucp\",\"workdir\":\"/work/cucp\",\"file\":\"nfdeploy.yaml\",\"kind\":\"NFDeployment\"}], \"newIps\":{\"n2\":{\"address\":\"10.10.1.10/24\",\"gateway\":\"10.10.1.1\"},\"n3\":{\"ipv4\":{\"address\":\"10.10.3.10/24\"},\"ipv6\":{\"address\":\"fd00:10:3::10/64\",\"gateway\":\"fd00:10:3::1\"}}}}. For other NFs, VLANs, network instances and routes use manifest_patch_nf_interfaces.",
 		Handler: func(ctx context.Context, cc *mcp.ServerSession, params *mcp.CallToolParamsFor[ManifestPatchCucpIPsManyParams]) (*mcp.CallToolResultFor[ManifestPatchCucpIPsManyResult], error) {
 			if len(params.Arguments.Targets) == 0 {
-				return toolErr[ManifestPatchCucpIPsManyResult](fmt.Errorf("missing required field: targets"))
+				return toolErr[ManifestPatchCucpIPsManyResult](missingField("targets"))
+			}
+			if len(params.Arguments.NewIPs) == 0 && len(params.Arguments.IPClaims) == 0 {
+				return toolErr[ManifestPatchCucpIPsManyResult](missingField("newIps"))
 			}
-			if len(params.Arguments.NewIPs) == 0 {
-				return toolErr[ManifestPatchCucpIPsManyResult](fmt.Errorf("missing required field: newIps"))
+			newIPs, err := resolveNewIPs(ctx, "newIps", params.Arguments.NewIPs, params.Arguments.IPClaims, params.Arguments.Targets, params.Arguments.Context)
+			if err != nil {
+				return toolErr[ManifestPatchCucpIPsManyResult](err)
 			}
 
 			out := ManifestPatchCucpIPsManyResult{Results: make([]PatchResult, 0, len(params.Arguments.Targets))}
@@ -69,7 +157,7 @@ func ManifestPatchCucpIPsMany() MCPTool[ManifestPatchCucpIPsManyParams, Manifest
 
 				u, _, err := readYAMLFile(abs)
 				if err != nil {
-					r.Error = fmt.Sprintf("read yaml: %v", err)
+					r.Error = toolError(fmt.Errorf("read yaml: %w", err), file)
 					out.Results = append(out.Results, r)
 					continue
 				}
@@ -83,14 +171,14 @@ func ManifestPatchCucpIPsMany() MCPTool[ManifestPatchCucpIPsManyParams, Manifest
 
 				// 1) Patch NFDeployment: update any keys named address/gateway under an interface context.
 				if kind == "NFDeployment" {
-					changed = patchByInterfaceContext(obj, params.Arguments.NewIPs) || changed
+					changed = patchByInterfaceContext(obj, newIPs) || changed
 				}
 
 				// 2) Patch NAD: spec.config is JSON string; update inside if contains address/gateway-like fields.
 				if kind == "NetworkAttachmentDefinition" {
-					ch, e := patchNADSpecConfig(obj, params.Arguments.NewIPs)
+					ch, e := patchNADSpecConfig(obj, newIPs)
 					if e != nil {
-						r.Error = e.Error()
+						r.Error = toolError(e, file)
 						out.Results = append(out.Results, r)
 						continue
 					}
@@ -99,7 +187,7 @@ func ManifestPatchCucpIPsMany() MCPTool[ManifestPatchCucpIPsManyParams, Manifest
 
 				if changed && !params.Arguments.DryRun {
 					if err := writeYAMLFile(abs, obj); err != nil {
-						r.Error = fmt.Sprintf("write yaml: %v", err)
+						r.Error = toolError(fmt.Errorf("write yaml: %w", err), file)
 						out.Results = append(out.Results, r)
 						continue
 					}
@@ -113,8 +201,51 @@ func ManifestPatchCucpIPsMany() MCPTool[ManifestPatchCucpIPsManyParams, Manifest
 	}
 }
 
+// resolveNewIPs checks the addressing given in parameter field and merges in
+// that of ipClaims, read from the targets' workdirs, then the management
+// cluster.
+func resolveNewIPs(ctx context.Context, field string, ips map[string]IPInfo, claims map[string][]string, targets []PatchTarget, mgmtContext string) (map[string]ifaceAddrs, error) {
+	newIPs := make(map[string]ifaceAddrs, len(ips))
+	for name, ip := range ips {
+		a, err := resolveIPInfoAt(field, name, ip)
+		if err != nil {
+			return nil, err
+		}
+		newIPs[strings.TrimSpace(name)] = a
+	}
+	if len(claims) == 0 {
+		return newIPs, nil
+	}
+	workdirs := make([]string, 0, len(targets))
+	for _, t := range targets {
+		workdirs = append(workdirs, cleanPath(t.Workdir))
+	}
+	claimed, err := resolveIPClaims(ctx, claims, dedupSorted(workdirs), mgmtContext)
+	if err != nil {
+		return nil, err
+	}
+	for name, ip := range claimed {
+		a, err := resolveIPInfo(name, ip)
+		if err != nil {
+			return nil, err
+		}
+		have := newIPs[name]
+		if (have.v4 != AddrGateway{} && a.v4 != AddrGateway{}) || (have.v6 != AddrGateway{} && a.v6 != AddrGateway{}) {
+			return nil, invalidArgument("ipClaims."+name, "interface %s gets the same address family from %s and ipClaims", name, field)
+		}
+		if a.v4 != (AddrGateway{}) {
+			have.v4 = a.v4
+		}
+		if a.v6 != (AddrGateway{}) {
+			have.v6 = a.v6
+		}
+		newIPs[name] = have
+	}
+	return newIPs, nil
+}
+
 // Heuristic: whenever we find map containing "name": <iface> and keys address/gateway nearby.
-func patchByInterfaceContext(obj map[string]any, newIPs map[string]IPInfo) bool {
+func patchByInterfaceContext(obj map[string]any, newIPs map[string]ifaceAddrs) bool {
 	changed := false
 	walkAny(obj, func(_ []string, key string, parent map[string]any, val any) {
 		// Look for interface "name"
@@ -131,32 +262,55 @@ func patchByInterfaceContext(obj map[string]any, newIPs map[string]IPInfo) bool
 			return
 		}
 
-		// Try to patch siblings directly: address/gateway or ipv4.address/ipv4.gateway
-		if a, ok := parent["address"].(string); ok && ip.Address != "" && a != ip.Address {
-			parent["address"] = ip.Address
-			changed = true
-		}
-		if g, ok := parent["gateway"].(string); ok && ip.Gateway != "" && g != ip.Gateway {
-			parent["gateway"] = ip.Gateway
-			changed = true
-		}
+		// Try to patch siblings directly: address/gateway (by the family of the
+		// current value) or ipv4.address/ipv4.gateway and ipv6.*
+		changed = patchAddrGateway(parent, ip, "") || changed
 
 		if ipv4, ok := parent["ipv4"].(map[string]any); ok {
-			if a, ok := ipv4["address"].(string); ok && ip.Address != "" && a != ip.Address {
-				ipv4["address"] = ip.Address
-				changed = true
-			}
-			if g, ok := ipv4["gateway"].(string); ok && ip.Gateway != "" && g != ip.Gateway {
-				ipv4["gateway"] = ip.Gateway
-				changed = true
-			}
+			changed = patchAddrGateway(ipv4, ip, familyIPv4) || changed
 			parent["ipv4"] = ipv4
 		}
+		if ipv6, ok := parent["ipv6"].(map[string]any); ok {
+			changed = patchAddrGateway(ipv6, ip, familyIPv6) || changed
+			parent["ipv6"] = ipv6
+		} else if _, hasV4 := parent["ipv4"].(map[string]any); hasV4 && ip.v6.Address != "" {
+			// dual-stack: add the IPv6 block next to the existing IPv4 one
+			ipv6 := map[string]any{"address": ip.v6.Address}
+			if ip.v6.Gateway != "" {
+				ipv6["gateway"] = ip.v6.Gateway
+			}
+			parent["ipv6"] = ipv6
+			changed = true
+		}
 	})
 	return changed
 }
 
-func patchNADSpecConfig(obj map[string]any, newIPs map[string]IPInfo) (bool, error) {
+// patchAddrGateway sets the address and gateway strings already present in m.
+// family selects the addressing; "" takes the family of the current address
+// (or gateway) value.
+func patchAddrGateway(m map[string]any, ip ifaceAddrs, family string) bool {
+	a, hasAddr := m["address"].(string)
+	g, hasGw := m["gateway"].(string)
+	if family == "" {
+		if family = addrFamily(a); family == "" {
+			family = addrFamily(g)
+		}
+	}
+	want := ip.forFamily(family)
+	changed := false
+	if hasAddr && want.Address != "" && a != want.Address {
+		m["address"] = want.Address
+		changed = true
+	}
+	if hasGw && want.Gateway != "" && g != want.Gateway {
+		m["gateway"] = want.Gateway
+		changed = true
+	}
+	return changed
+}
+
+func patchNADSpecConfig(obj map[string]any, newIPs map[string]ifaceAddrs) (bool, error) {
 	spec, found, _ := unstructured.NestedMap(obj, "spec")
 	if !found {
 		return false, nil
@@ -165,6 +319,28 @@ func patchNADSpecConfig(obj map[string]any, newIPs map[string]IPInfo) (bool, err
 	if !ok || strings.TrimSpace(cfg) == "" {
 		return false, nil
 	}
+
+	// Static IPAM of a NAD named after the interface (n2, cucp-n2): typed edit
+	name, _, _ := unstructured.NestedString(obj, "metadata", "name")
+	if cc, err := parseCNIConfig(cfg); err == nil {
+		ip, ok := addrsForNetwork(name, newIPs)
+		if !ok {
+			ip, ok = addrsForNetwork(cc.Name, newIPs)
+		}
+		if ok && cc.staticAddresses() != nil {
+			if !cc.setStaticAddresses(ip) {
+				return false, nil
+			}
+			out, err := cc.Marshal()
+			if err != nil {
+				return false, err
+			}
+			spec["config"] = out
+			obj["spec"] = spec
+			return true, nil
+		}
+	}
+
 	jm, ok := tryParseJSONConfigString(cfg)
 	if !ok {
 		// Not JSON, do simple string replace for CIDRs/GWs if present
@@ -191,7 +367,7 @@ func patchNADSpecConfig(obj map[string]any, newIPs map[string]IPInfo) (bool, err
 }
 
 // Brutal but reliable: replace any string equal to old values? Here we just overwrite keys named address/gateway if they look like IP and we can infer iface from nearby name.
-func patchStringFieldsInMap(m map[string]any, newIPs map[string]IPInfo) bool {
+func patchStringFieldsInMap(m map[string]any, newIPs map[string]ifaceAddrs) bool {
 	changed := false
 	// As fallback: if string contains an old CIDR from any iface, replace with new
 	// We don't know old here; so we patch only when key is "address"/"gateway" and value looks like IP.
@@ -201,21 +377,25 @@ func patchStringFieldsInMap(m map[string]any, newIPs map[string]IPInfo) bool {
 			return
 		}
 		s = strings.TrimSpace(s)
-		if key == "address" && cidrRe.MatchString(s) {
+		if key == "address" && isCIDR(s) {
 			// cannot know which iface; skip unless parent has "name"
 			iface, _ := parent["name"].(string)
 			iface = strings.TrimSpace(iface)
-			if ip, ok := newIPs[iface]; ok && ip.Address != "" && s != ip.Address {
-				parent[key] = ip.Address
-				changed = true
+			if ip, ok := newIPs[iface]; ok {
+				if want := ip.forFamily(addrFamily(s)); want.Address != "" && s != want.Address {
+					parent[key] = want.Address
+					changed = true
+				}
 			}
 		}
-		if key == "gateway" && ipv4Re.MatchString(s) {
+		if key == "gateway" && isIP(s) {
 			iface, _ := parent["name"].(string)
 			iface = strings.TrimSpace(iface)
-			if ip, ok := newIPs[iface]; ok && ip.Gateway != "" && s != ip.Gateway {
-				parent[key] = ip.Gateway
-				changed = true
+			if ip, ok := newIPs[iface]; ok {
+				if want := ip.forFamily(addrFamily(s)); want.Gateway != "" && s != want.Gateway {
+					parent[key] = want.Gateway
+					changed = true
+				}
 			}
 		}
 	})
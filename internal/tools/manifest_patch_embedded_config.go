@@ -0,0 +1,394 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"gopkg.in/yaml.v3"
+)
+
+func init() { registerTool(ManifestPatchEmbeddedConfig()) }
+
+// EmbeddedEdit sets one key of a configuration embedded in a manifest string.
+type EmbeddedEdit struct {
+	Field string `json:"field,omitempty" description:"Manifest field holding the configuration, e.g. data[\"gnb.conf\"] or spec.config. Empty: every string field holding JSON, libconfig or YAML." example:"data[\"du.conf\"]"`
+	Key   string `json:"key" description:"Key path in the configuration, matched against the end of the full path; list indices may be left out, so amf_ip_address.ipv4 matches gNBs[0].amf_ip_address[0].ipv4." example:"MACRLCs.remote_n_address"`
+	Value string `json:"value" description:"New value; quoted like the old value, and quoted anyway where a bare value would not parse."`
+	All   bool   `json:"all,omitempty" description:"Set every key the path matches; otherwise a path matching several keys is an error."`
+}
+
+type ManifestPatchEmbeddedConfigParams struct {
+	Targets      []PatchTarget  `json:"targets" description:"ConfigMap or Config manifests holding the configuration. kind, name and namespace select documents of multi-document files."`
+	Edits        []EmbeddedEdit `json:"edits" description:"Keys to set, applied to every target."`
+	AllowMissing bool           `json:"allowMissing,omitempty" description:"Skip an edit whose key is absent from a target with a warning; otherwise the target fails with NOT_FOUND and is not written."`
+	DryRun       bool           `json:"dryRun,omitempty" description:"Report changes without writing files."`
+}
+
+// EmbeddedEditResult is one key set in a target.
+type EmbeddedEditResult struct {
+	Field  string `json:"field"`
+	Format string `json:"format"` // json, libconfig or yaml
+	Key    string `json:"key"`    // full path of the key
+	Old    string `json:"old"`    // values as written
+	New    string `json:"new"`
+}
+
+type EmbeddedPatchResult struct {
+	Repo    string               `json:"repo"`
+	File    string               `json:"file"`
+	Changed bool                 `json:"changed"`
+	Error   *ToolError           `json:"error,omitempty"`
+	Edits   []EmbeddedEditResult `json:"edits,omitempty"`
+	// Reformatted is set when the edits could not be spliced into the file
+	// text and it was re-encoded: comments and key order are kept, layout
+	// may change.
+	Reformatted bool `json:"reformatted,omitempty"`
+}
+
+type ManifestPatchEmbeddedConfigResult struct {
+	Results  []EmbeddedPatchResult `json:"results"`
+	Warnings []string              `json:"warnings,omitempty"`
+}
+
+func ManifestPatchEmbeddedConfig() MCPTool[ManifestPatchEmbeddedConfigParams, ManifestPatchEmbeddedConfigResult] {
+	return MCPTool[ManifestPatchEmbeddedConfigParams, ManifestPatchEmbeddedConfigResult]{
+		Name:        "manifest_patch_embedded_config",
+		Description: "Set keys of configuration files embedded in ConfigMap and Config manifests: OAI libconfig (gnb.conf, cu.conf, du.conf), JSON and YAML. Parses the payload, sets each key by path (GNB_IPV4_ADDRESS_FOR_NG_AMF, local_s_address, amf_ip_address.ipv4) and rewrites only the values, keeping comments and layout of both the payload and the manifest. Safer than manifest_patch_config_refs where the old value is ambiguous or a key must be set whatever it holds. Example: {\"targets\":[{\"repo\":\"du\",\"workdir\":\"/work/du\",\"file\":\"du-config.yaml\"}], \"edits\":[{\"key\":\"MACRLCs.remote_n_address\",\"value\":\"10.10.1.10\"}]}.",
+		Handler: func(ctx context.Context, cc *mcp.ServerSession, params *mcp.CallToolParamsFor[ManifestPatchEmbeddedConfigParams]) (*mcp.CallToolResultFor[ManifestPatchEmbeddedConfigResult], error) {
+			if len(params.Arguments.Targets) == 0 {
+				return toolErr[ManifestPatchEmbeddedConfigResult](missingField("targets"))
+			}
+			if len(params.Arguments.Edits) == 0 {
+				return toolErr[ManifestPatchEmbeddedConfigResult](missingField("edits"))
+			}
+			for i, e := range params.Arguments.Edits {
+				if len(splitKeyPath(strings.TrimSpace(e.Key))) == 0 {
+					return toolErr[ManifestPatchEmbeddedConfigResult](missingField(fmt.Sprintf("edits[%d].key", i)))
+				}
+				if strings.ContainsAny(e.Value, "\r\n") {
+					return toolErr[ManifestPatchEmbeddedConfigResult](invalidArgument(fmt.Sprintf("edits[%d].value", i), "must be a single line"))
+				}
+			}
+
+			out := ManifestPatchEmbeddedConfigResult{Results: make([]EmbeddedPatchResult, 0, len(params.Arguments.Targets))}
+			for _, t := range params.Arguments.Targets {
+				repo := strings.TrimSpace(t.Repo)
+				file := filepath.ToSlash(strings.TrimSpace(t.File))
+				abs := absJoin(cleanPath(t.Workdir), file)
+				r := EmbeddedPatchResult{Repo: repo, File: file}
+
+				b, err := os.ReadFile(abs)
+				if err != nil {
+					r.Error = toolError(fmt.Errorf("read file: %w", err), file)
+					out.Results = append(out.Results, r)
+					continue
+				}
+				p, err := patchEmbeddedConfig(b, t, params.Arguments.Edits, params.Arguments.AllowMissing)
+				if err != nil {
+					r.Error = toolError(err, file)
+					out.Results = append(out.Results, r)
+					continue
+				}
+				for _, w := range p.missing {
+					out.Warnings = append(out.Warnings, fmt.Sprintf("%s:%s has no %s", repo, file, w))
+				}
+				r.Edits, r.Reformatted = p.edits, p.reformatted
+				r.Changed = !bytes.Equal(p.out, b)
+				if r.Changed && !params.Arguments.DryRun {
+					if err := writeFileAtomic(abs, p.out); err != nil {
+						r.Error = toolError(fmt.Errorf("write file: %w", err), file)
+					}
+				}
+				out.Results = append(out.Results, r)
+			}
+			return toolOK(out), nil
+		},
+	}
+}
+
+// embeddedPayload is a manifest string holding a configuration.
+type embeddedPayload struct {
+	node   *yaml.Node
+	field  string
+	format string
+	leaves []confLeaf
+	edits  []textEdit
+}
+
+// textEdit replaces s[start:end] with text.
+type textEdit struct {
+	start, end int
+	text       string
+}
+
+type embeddedPatch struct {
+	out         []byte
+	edits       []EmbeddedEditResult
+	missing     []string // keys of skipped edits
+	reformatted bool
+}
+
+// patchEmbeddedConfig applies edits to the configurations embedded in the
+// YAML documents of b that t selects.
+func patchEmbeddedConfig(b []byte, t PatchTarget, edits []EmbeddedEdit, allowMissing bool) (*embeddedPatch, error) {
+	var docs []*yaml.Node
+	dec := yaml.NewDecoder(bytes.NewReader(b))
+	for {
+		var n yaml.Node
+		if err := dec.Decode(&n); errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, invalidArgument("file", "parse yaml: %v", err)
+		}
+		docs = append(docs, &n)
+	}
+
+	// strings of the selected documents, by field
+	type field struct {
+		path string
+		node *yaml.Node
+	}
+	var fields []field
+	selected := 0
+	for _, doc := range docs {
+		kind, name, namespace := yamlObjectMeta(doc)
+		if t.Kind != "" && !strings.EqualFold(t.Kind, kind) || t.Name != "" && t.Name != name || t.Namespace != "" && t.Namespace != namespace {
+			continue
+		}
+		selected++
+		walkYAMLScalars(doc, "", func(p string, v *yaml.Node) {
+			if v.Tag == "!!str" {
+				fields = append(fields, field{p, v})
+			}
+		})
+	}
+	if selected == 0 {
+		return nil, &ToolError{Code: CodeNotFound, Message: "no document matches the target kind, name and namespace"}
+	}
+
+	payloads := map[*yaml.Node]*embeddedPayload{}
+	var order []*embeddedPayload
+	payloadOf := func(f field) *embeddedPayload {
+		if p, ok := payloads[f.node]; ok {
+			return p
+		}
+		p := &embeddedPayload{node: f.node, field: f.path}
+		if format, leaves, ok := parseEmbedded(f.node.Value); ok {
+			p.format, p.leaves = format, leaves
+		}
+		payloads[f.node] = p
+		order = append(order, p)
+		return p
+	}
+
+	res := &embeddedPatch{}
+	set := map[*embeddedPayload]map[int]string{} // leaf index -> value
+	for _, e := range edits {
+		query := splitKeyPath(strings.TrimSpace(e.Key))
+		want := strings.Join(splitKeyPath(strings.TrimSpace(e.Field)), ".")
+
+		type hit struct {
+			p    *embeddedPayload
+			leaf int
+		}
+		var hits []hit
+		for _, f := range fields {
+			if want != "" && strings.Join(splitKeyPath(f.path), ".") != want {
+				continue
+			}
+			if v := strings.TrimSpace(f.node.Value); want == "" && !strings.Contains(f.node.Value, "\n") && !strings.HasPrefix(v, "{") && !strings.HasPrefix(v, "[") {
+				continue
+			}
+			p := payloadOf(f)
+			if p.format == "" {
+				if want != "" {
+					return nil, invalidArgument("field", "%s holds no JSON, libconfig or YAML configuration", f.path)
+				}
+				continue
+			}
+			for i, l := range p.leaves {
+				if keyPathMatches(l.path, query) {
+					hits = append(hits, hit{p, i})
+				}
+			}
+		}
+
+		switch {
+		case len(hits) == 0 && allowMissing:
+			res.missing = append(res.missing, e.Key)
+			continue
+		case len(hits) == 0:
+			where := "any embedded configuration"
+			if want != "" {
+				where = e.Field
+			}
+			return nil, &ToolError{
+				Code:    CodeNotFound,
+				Message: fmt.Sprintf("key %s not found in %s", e.Key, where),
+				Hint:    "repo_find_references reports the embedded path of a value; set allowMissing to skip absent keys",
+			}
+		case len(hits) > 1 && !e.All:
+			keys := make([]string, len(hits))
+			for i, h := range hits {
+				keys[i] = h.p.field + ": " + h.p.leaves[h.leaf].pathString()
+			}
+			return nil, &ToolError{
+				Code:    CodeInvalidArgument,
+				Message: fmt.Sprintf("key %s matches %d settings: %s", e.Key, len(hits), strings.Join(keys, ", ")),
+				Hint:    "give a longer key path or a field, or set all",
+			}
+		}
+
+		for _, h := range hits {
+			l := h.p.leaves[h.leaf]
+			if !l.editable {
+				return nil, invalidArgument("edits", "%s in %s is a block scalar and cannot be set in place", l.pathString(), h.p.field)
+			}
+			if set[h.p] == nil {
+				set[h.p] = map[int]string{}
+			}
+			if v, dup := set[h.p][h.leaf]; dup {
+				if v != e.Value {
+					return nil, invalidArgument("edits", "%s in %s is set to both %q and %q", l.pathString(), h.p.field, v, e.Value)
+				}
+				continue // another key path of an edit already set it
+			}
+			set[h.p][h.leaf] = e.Value
+			text := renderLeaf(h.p.format, l, e.Value)
+			res.edits = append(res.edits, EmbeddedEditResult{
+				Field:  h.p.field,
+				Format: h.p.format,
+				Key:    l.pathString(),
+				Old:    h.p.node.Value[l.start:l.end],
+				New:    text,
+			})
+			if text != h.p.node.Value[l.start:l.end] {
+				h.p.edits = append(h.p.edits, textEdit{l.start, l.end, text})
+			}
+		}
+	}
+
+	// Splice the new values into the file text; re-encode when a value
+	// cannot be located there (folded or escaped scalars).
+	src := string(b)
+	lineStarts := []int{0}
+	for i := 0; i < len(src); i++ {
+		if src[i] == '\n' {
+			lineStarts = append(lineStarts, i+1)
+		}
+	}
+	var fileEdits []textEdit
+	splice := true
+	for _, p := range order {
+		if len(p.edits) == 0 {
+			continue
+		}
+		sort.Slice(p.edits, func(i, j int) bool { return p.edits[i].start < p.edits[j].start })
+		payload := p.node.Value
+		np, err := applyTextEdits(payload, p.edits)
+		if err != nil {
+			return nil, err
+		}
+		if format, _, ok := parseEmbedded(np); !ok || format != p.format {
+			return nil, &ToolError{Code: CodeInternal, Message: fmt.Sprintf("%s no longer parses as %s after the edits", p.field, p.format)}
+		}
+		for _, e := range p.edits {
+			fe, ok := fileEditFor(src, lineStarts, p.node, payload, e)
+			if !ok {
+				splice = false
+			}
+			fileEdits = append(fileEdits, fe)
+		}
+		p.node.Value = np
+	}
+
+	if splice {
+		sort.Slice(fileEdits, func(i, j int) bool { return fileEdits[i].start < fileEdits[j].start })
+		out, err := applyTextEdits(src, fileEdits)
+		if err != nil {
+			return nil, err
+		}
+		res.out = []byte(out)
+		return res, nil
+	}
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	for _, doc := range docs {
+		if err := enc.Encode(doc); err != nil {
+			return nil, fmt.Errorf("encode yaml: %w", err)
+		}
+	}
+	if err := enc.Close(); err != nil {
+		return nil, fmt.Errorf("encode yaml: %w", err)
+	}
+	res.out, res.reformatted = buf.Bytes(), true
+	return res, nil
+}
+
+// applyTextEdits applies edits, sorted by start, to s; overlapping edits are
+// an error.
+func applyTextEdits(s string, edits []textEdit) (string, error) {
+	var sb strings.Builder
+	last := 0
+	for _, e := range edits {
+		if e.start < last || e.end < e.start || e.end > len(s) {
+			return "", &ToolError{Code: CodeInternal, Message: fmt.Sprintf("overlapping edits at offset %d", e.start)}
+		}
+		sb.WriteString(s[last:e.start])
+		sb.WriteString(e.text)
+		last = e.end
+	}
+	sb.WriteString(s[last:])
+	return sb.String(), nil
+}
+
+// fileEditFor maps edit e of the value of scalar n to the file text: line by
+// line for literal block scalars, by offset for single-line scalars.
+func fileEditFor(src string, lineStarts []int, n *yaml.Node, payload string, e textEdit) (textEdit, bool) {
+	switch {
+	case n.Style&yaml.LiteralStyle != 0:
+		pl := strings.Count(payload[:e.start], "\n")
+		pc := e.start - (strings.LastIndexByte(payload[:e.start], '\n') + 1)
+		pline, _, _ := strings.Cut(payload[e.start-pc:], "\n")
+		fl := n.Line + pl // content starts on the line after the indicator
+		if fl >= len(lineStarts) {
+			return textEdit{}, false
+		}
+		fline, _, _ := strings.Cut(src[lineStarts[fl]:], "\n")
+		fline = strings.TrimSuffix(fline, "\r")
+		if !strings.HasSuffix(fline, pline) {
+			return textEdit{}, false
+		}
+		off := lineStarts[fl] + len(fline) - len(pline) + pc
+		return textEdit{off, off + e.end - e.start, e.text}, true
+	case n.Style&yaml.FoldedStyle != 0 || strings.Contains(payload, "\n") || n.Line-1 >= len(lineStarts):
+		return textEdit{}, false
+	}
+	esc := func(s string) string { return s }
+	off := lineStarts[n.Line-1] + n.Column - 1
+	switch {
+	case n.Style&yaml.DoubleQuotedStyle != 0:
+		esc = strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace
+		off++
+	case n.Style&yaml.SingleQuotedStyle != 0:
+		esc = strings.NewReplacer(`'`, `''`).Replace
+		off++
+	case strings.Contains(e.text, ": ") || strings.Contains(e.text, " #"):
+		return textEdit{}, false // would end a plain scalar
+	}
+	off += len(esc(payload[:e.start]))
+	old := esc(payload[e.start:e.end])
+	if off+len(old) > len(src) || src[off:off+len(old)] != old {
+		return textEdit{}, false
+	}
+	return textEdit{off, off + len(old), esc(e.text)}, true
+}
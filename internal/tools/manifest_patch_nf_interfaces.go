@@ -0,0 +1,652 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func init() { registerTool(ManifestPatchNFInterfaces()) }
+
+// nfInterfaces are the interfaces each NF type has, as interfaceKinds keys.
+var nfInterfaces = map[string][]string{
+	nfAMF:  {"n2"},
+	nfSMF:  {"n4"},
+	nfUPF:  {"n3", "n4", "n6"},
+	nfCUCP: {"n2", "f1c", "e1"},
+	nfCUUP: {"e1", "f1u", "n3"},
+	nfCU:   {"n2", "n3", "f1", "f1c", "f1u"},
+	nfDU:   {"f1", "f1c", "f1u"},
+	nfGNB:  {"n2", "n3"},
+}
+
+// NFInterfaceSpec is the new configuration of one interface; fields left out
+// are not touched.
+type NFInterfaceSpec struct {
+	Address string       `json:"address,omitempty" description:"Interface address in CIDR form, IPv4 or IPv6; shorthand for the ipv4 or ipv6 block of its family." example:"10.10.3.10/24"`
+	Gateway string       `json:"gateway,omitempty" description:"Gateway IP, same family as address." example:"10.10.3.1"`
+	IPv4    *AddrGateway `json:"ipv4,omitempty" description:"IPv4 addressing of the interface."`
+	IPv6    *AddrGateway `json:"ipv6,omitempty" description:"IPv6 addressing of the interface (dual-stack)."`
+
+	VlanID          *int          `json:"vlanId,omitempty" description:"VLAN ID: NFDeployment interface vlanID, NAD vlan (sriov, bridge), vlanId (vlan plugin) or the tag of a macvlan/ipvlan master such as eth1.100." example:"100"`
+	NetworkInstance string        `json:"networkInstance,omitempty" description:"NFDeployment network instance (VRF) the interface belongs to; it is moved out of any other instance, and the instance is created when missing." example:"vpc-internet"`
+	Routes          []StaticRoute `json:"routes,omitempty" description:"Static routes replacing those of the interface: NAD ipam.routes, or a routes list of the interface."`
+}
+
+// StaticRoute is a route of an interface.
+type StaticRoute struct {
+	Dst string `json:"dst" description:"Destination CIDR." example:"10.60.0.0/16"`
+	GW  string `json:"gw,omitempty" description:"Next hop; the interface gateway when empty." example:"10.10.6.1"`
+}
+
+type ManifestPatchNFInterfacesParams struct {
+	Targets    []PatchTarget              `json:"targets" description:"NFDeployment, NetworkAttachmentDefinition, Config and NFConfig manifests of the NF."`
+	NFType     string                     `json:"nfType,omitempty" description:"NF type whose interface schema the keys are checked against; taken from the NFDeployment targets (name, spec.provider) when empty." enum:"AMF,SMF,UPF,CU-CP,CU-UP,CU,DU,gNB"`
+	Interfaces map[string]NFInterfaceSpec `json:"interfaces,omitempty" description:"New configuration per interface (n2, n3, n4, n6, f1, f1c, f1u, e1). A key matches interfaces and NADs of that name, names ending in -<key> (upf-n3) and names of the same 3GPP interface (f1-u for f1u). Required unless ipClaims is given."`
+	Strict     bool                       `json:"strict,omitempty" description:"Fail with NOT_FOUND, writing nothing, when an interface key matches nothing in any target, or a given field (ipv4 or ipv6 addressing, vlanId, networkInstance, routes) has no place in any target; otherwise a warning."`
+	DryRun     bool                       `json:"dryRun,omitempty" description:"Report changes without writing files."`
+
+	IPClaims map[string][]string `json:"ipClaims,omitempty" description:"Nephio IPClaims (name or namespace/name) per interface key whose allocated prefix (status.prefix) and gateway become that interface's addressing, one claim per address family. Claims are read from the targets' workdirs, then from the management cluster." example:"{\"n3\":[\"upf-n3-v4\",\"upf-n3-v6\"]}"`
+	Context  string              `json:"context,omitempty" description:"Management cluster kube context for ipClaims; defaults to the -mgmt-context flag, then kube.managementContext from the server config, then the current context."`
+}
+
+// InterfaceChange is one field changed for an interface key.
+type InterfaceChange struct {
+	Interface string `json:"interface"` // key of interfaces
+	Path      string `json:"path"`
+	Old       string `json:"old,omitempty"` // empty when added
+	New       string `json:"new,omitempty"` // empty when removed
+}
+
+type NFInterfacesPatchResult struct {
+	Repo    string            `json:"repo"`
+	File    string            `json:"file"`
+	Kind    string            `json:"kind,omitempty"`
+	NFType  string            `json:"nfType,omitempty"`
+	Changed bool              `json:"changed"`
+	Error   *ToolError        `json:"error,omitempty"`
+	Matched []string          `json:"matched,omitempty"` // interface keys found in the file
+	Changes []InterfaceChange `json:"changes,omitempty"`
+}
+
+type ManifestPatchNFInterfacesResult struct {
+	Results []NFInterfacesPatchResult `json:"results"`
+	// Unmatched are the interface keys found in no target
+	Unmatched []string `json:"unmatched,omitempty"`
+	Warnings  []string `json:"warnings,omitempty"`
+}
+
+func ManifestPatchNFInterfaces() MCPTool[ManifestPatchNFInterfacesParams, ManifestPatchNFInterfacesResult] {
+	return MCPTool[ManifestPatchNFInterfacesParams, ManifestPatchNFInterfacesResult]{
+		Name:        "manifest_patch_nf_interfaces",
+		Description: "Update the interfaces of any 5G NF (AMF N2, SMF N4, UPF N3/N4/N6, CU-CP N2/F1-C/E1, CU-UP E1/F1-U/N3, DU F1) in its NFDeployment, NAD, Config and NFConfig manifests: IPv4/IPv6 address and gateway (spec.interfaces[].ipv4/ipv6, NAD static IPAM), VLAN ID, network instance (spec.networkInstances) and static routes. Interface keys are checked against the NF type's interface schema; every change is reported with its path and old value, and keys matching nothing are reported (strict: NOT_FOUND, nothing written). Use to relocate UPFs and other NFs; manifest_patch_cucp_ips covers CU-CP addressing only. Example: {\"targets\":[{\"repo\":\"upf\",\"workdir\":\"/work/upf\",\"file\":\"upf.yaml\"}], \"nfType\":\"UPF\", \"interfaces\":{\"n3\":{\"address\":\"10.10.3.20/24\",\"gateway\":\"10.10.3.1\",\"vlanId\":103},\"n6\":{\"address\":\"10.10.6.20/24\",\"networkInstance\":\"vpc-internet\"}}, \"strict\":true}.",
+		Handler: func(ctx context.Context, cc *mcp.ServerSession, params *mcp.CallToolParamsFor[ManifestPatchNFInterfacesParams]) (*mcp.CallToolResultFor[ManifestPatchNFInterfacesResult], error) {
+			a := params.Arguments
+			if len(a.Targets) == 0 {
+				return toolErr[ManifestPatchNFInterfacesResult](missingField("targets"))
+			}
+			if len(a.Interfaces) == 0 && len(a.IPClaims) == 0 {
+				return toolErr[ManifestPatchNFInterfacesResult](missingField("interfaces"))
+			}
+
+			// Addressing per key, from interfaces and ipClaims
+			ips := map[string]IPInfo{}
+			specs := map[string]NFInterfaceSpec{}
+			for key, s := range a.Interfaces {
+				key = strings.TrimSpace(key)
+				specs[key] = s
+				if ip := (IPInfo{Address: s.Address, Gateway: s.Gateway, IPv4: s.IPv4, IPv6: s.IPv6}); ip != (IPInfo{}) {
+					ips[key] = ip
+				}
+				if s.VlanID != nil && (*s.VlanID < 0 || *s.VlanID > 4094) {
+					return toolErr[ManifestPatchNFInterfacesResult](invalidArgument("interfaces."+key+".vlanId", "%d is outside 0-4094", *s.VlanID))
+				}
+				for i, r := range s.Routes {
+					if !isCIDR(r.Dst) || r.GW != "" && !isIP(r.GW) {
+						return toolErr[ManifestPatchNFInterfacesResult](invalidArgument(fmt.Sprintf("interfaces.%s.routes[%d]", key, i), "need dst as a CIDR and gw as an IP"))
+					}
+				}
+			}
+			addrs, err := resolveNewIPs(ctx, "interfaces", ips, a.IPClaims, a.Targets, a.Context)
+			if err != nil {
+				return toolErr[ManifestPatchNFInterfacesResult](err)
+			}
+			for key := range addrs {
+				if _, ok := specs[key]; !ok {
+					specs[key] = NFInterfaceSpec{}
+				}
+			}
+			keys := make([]string, 0, len(specs))
+			for key := range specs {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+
+			declared := ""
+			if a.NFType != "" {
+				if declared = nfType(a.NFType, ""); nfInterfaces[declared] == nil {
+					return toolErr[ManifestPatchNFInterfacesResult](invalidArgument("nfType", "unknown NF type %q", a.NFType))
+				}
+				if err := checkInterfaceKeys(keys, []string{declared}); err != nil {
+					return toolErr[ManifestPatchNFInterfacesResult](err)
+				}
+			}
+
+			// Patch every target in memory first, so strict mode can fail
+			// without writing anything.
+			type patched struct {
+				abs string
+				obj map[string]any
+			}
+			out := ManifestPatchNFInterfacesResult{Results: make([]NFInterfacesPatchResult, 0, len(a.Targets))}
+			files := make([]*patched, len(a.Targets))
+			matched := map[string]bool{}
+			applied := map[string]map[string]bool{} // key -> fields the targets have a place for
+			var types []string
+			for i, t := range a.Targets {
+				file := filepath.ToSlash(strings.TrimSpace(t.File))
+				abs := absJoin(cleanPath(t.Workdir), file)
+				r := NFInterfacesPatchResult{Repo: strings.TrimSpace(t.Repo), File: file}
+
+				u, _, err := readYAMLFile(abs)
+				if err != nil {
+					r.Error = toolError(fmt.Errorf("read yaml: %w", err), file)
+					out.Results = append(out.Results, r)
+					continue
+				}
+				r.Kind = t.Kind
+				if r.Kind == "" {
+					r.Kind = u.GetKind()
+				}
+				if r.Kind == "NFDeployment" {
+					provider, _, _ := unstructured.NestedString(u.Object, "spec", "provider")
+					if r.NFType = nfType(u.GetName(), provider); r.NFType != "" {
+						types = append(types, r.NFType)
+					}
+				}
+
+				p := &interfacePatcher{specs: specs, addrs: addrs, keys: keys, places: map[string]map[string]bool{}}
+				if r.Kind == "NetworkAttachmentDefinition" {
+					if err := p.patchNAD(u.Object); err != nil {
+						r.Error = toolError(err, file)
+						out.Results = append(out.Results, r)
+						continue
+					}
+				} else {
+					p.patchInterfaces(u.Object)
+					if r.Kind == "NFDeployment" {
+						p.patchNetworkInstances(u.Object)
+					}
+				}
+				for key, fields := range p.places {
+					matched[key] = true
+					r.Matched = append(r.Matched, key)
+					if applied[key] == nil {
+						applied[key] = map[string]bool{}
+					}
+					for f := range fields {
+						applied[key][f] = true
+					}
+				}
+				sort.Strings(r.Matched)
+				r.Changes = p.changes
+				r.Changed = len(p.changes) > 0
+				if r.Changed {
+					files[i] = &patched{abs, u.Object}
+				}
+				out.Results = append(out.Results, r)
+			}
+
+			if declared == "" && len(types) > 0 {
+				if err := checkInterfaceKeys(keys, dedupSorted(types)); err != nil {
+					return toolErr[ManifestPatchNFInterfacesResult](err)
+				}
+			}
+			var dropped []string // key: field, for fields no target has a place for
+			for _, key := range keys {
+				if !matched[key] {
+					out.Unmatched = append(out.Unmatched, key)
+					continue
+				}
+				s := specs[key]
+				for _, f := range []struct {
+					name  string
+					given bool
+				}{
+					{familyIPv4, addrs[key].v4 != (AddrGateway{})},
+					{familyIPv6, addrs[key].v6 != (AddrGateway{})},
+					{"vlanId", s.VlanID != nil},
+					{"networkInstance", s.NetworkInstance != ""},
+					{"routes", len(s.Routes) > 0},
+				} {
+					if f.given && !applied[key][f.name] {
+						dropped = append(dropped, key+": "+f.name)
+					}
+				}
+			}
+			if len(out.Unmatched) > 0 {
+				if a.Strict {
+					return toolErr[ManifestPatchNFInterfacesResult](&ToolError{
+						Code:    CodeNotFound,
+						Message: "interfaces match nothing in the targets: " + strings.Join(out.Unmatched, ", "),
+						Hint:    "check the interface names with repo_scan_manifests; no file was written",
+					})
+				}
+				out.Warnings = append(out.Warnings, "interfaces match nothing in the targets: "+strings.Join(out.Unmatched, ", "))
+			}
+			if len(dropped) > 0 {
+				if a.Strict {
+					return toolErr[ManifestPatchNFInterfacesResult](&ToolError{
+						Code:    CodeNotFound,
+						Message: "no target has a place for " + strings.Join(dropped, ", "),
+						Hint:    "add the field to a target, or drop it from the request (a flat address of one family leaves no place for the other); no file was written",
+					})
+				}
+				for _, d := range dropped {
+					out.Warnings = append(out.Warnings, d+" not applied, no target has a place for it")
+				}
+			}
+
+			if !a.DryRun {
+				for i, f := range files {
+					if f == nil || out.Results[i].Error != nil {
+						continue
+					}
+					if err := writeYAMLFile(f.abs, f.obj); err != nil {
+						out.Results[i].Error = toolError(fmt.Errorf("write yaml: %w", err), out.Results[i].File)
+						out.Results[i].Changed = false
+					}
+				}
+			}
+			return toolOK(out), nil
+		},
+	}
+}
+
+// checkInterfaceKeys rejects keys that are not interfaces of any of types.
+func checkInterfaceKeys(keys, types []string) error {
+	allowed := map[string]bool{}
+	var names []string
+	for _, t := range types {
+		for _, i := range nfInterfaces[t] {
+			if !allowed[interfaceKinds[i]] {
+				names = append(names, i)
+			}
+			allowed[interfaceKinds[i]] = true
+		}
+	}
+	if len(allowed) == 0 {
+		return nil // no schema for an unknown type
+	}
+	for _, key := range keys {
+		if !allowed[interfaceKind(key)] {
+			return &ToolError{
+				Code:    CodeInvalidArgument,
+				Message: fmt.Sprintf("%s is not an interface of %s", key, strings.Join(types, ", ")),
+				Target:  "interfaces." + key,
+				Hint:    "interfaces of " + strings.Join(types, ", ") + ": " + strings.Join(names, ", "),
+			}
+		}
+	}
+	return nil
+}
+
+// interfacePatcher applies the interface specs to one manifest, recording the
+// changes and, per key, the fields the manifest has a place for.
+type interfacePatcher struct {
+	specs   map[string]NFInterfaceSpec
+	addrs   map[string]ifaceAddrs
+	keys    []string
+	changes []InterfaceChange
+	places  map[string]map[string]bool
+	names   map[string][]string // key -> matched interface names
+}
+
+// keyFor returns the key of the interface called name: an exact match, the
+// longest key name ends in (upf-n3), else a key of the same 3GPP interface.
+func (p *interfacePatcher) keyFor(name string) (string, bool) {
+	name = strings.TrimSpace(name)
+	best := ""
+	for _, key := range p.keys {
+		if key == name {
+			return key, true
+		}
+		if networkNameMatches(name, key) && len(key) > len(best) {
+			best = key
+		}
+	}
+	if best != "" {
+		return best, true
+	}
+	if kind := interfaceKind(name); kind != "" {
+		for _, key := range p.keys {
+			if interfaceKind(key) == kind {
+				return key, true
+			}
+		}
+	}
+	return "", false
+}
+
+func (p *interfacePatcher) place(key, field string) {
+	if p.places[key] == nil {
+		p.places[key] = map[string]bool{}
+	}
+	p.places[key][field] = true
+}
+
+func (p *interfacePatcher) set(key string, m map[string]any, k, path string, v any) {
+	old, had := m[k]
+	if had && fmt.Sprint(old) == fmt.Sprint(v) {
+		return
+	}
+	m[k] = v
+	c := InterfaceChange{Interface: key, Path: path, New: fmt.Sprint(v)}
+	if had {
+		c.Old = fmt.Sprint(old)
+	}
+	p.changes = append(p.changes, c)
+}
+
+func (p *interfacePatcher) setRoutes(key string, old, new []CNIRoute, path string) {
+	ob, _ := json.Marshal(old)
+	nb, _ := json.Marshal(new)
+	if string(ob) == string(nb) {
+		return
+	}
+	c := InterfaceChange{Interface: key, Path: path, New: string(nb)}
+	if len(old) > 0 {
+		c.Old = string(ob)
+	}
+	p.changes = append(p.changes, c)
+}
+
+func (s NFInterfaceSpec) cniRoutes() []CNIRoute {
+	routes := make([]CNIRoute, 0, len(s.Routes))
+	for _, r := range s.Routes {
+		routes = append(routes, CNIRoute{Dst: strings.TrimSpace(r.Dst), GW: strings.TrimSpace(r.GW)})
+	}
+	return routes
+}
+
+var interfaceFields = []string{"address", "gateway", "ipv4", "ipv6", "vlanID", "vlanId", "vlan"}
+
+// patchInterfaces patches every map that describes a named interface:
+// NFDeployment spec.interfaces[] and the like in Configs.
+func (p *interfacePatcher) patchInterfaces(obj map[string]any) {
+	walkAny(obj, func(path []string, key string, parent map[string]any, val any) {
+		name, ok := val.(string)
+		if key != "name" || !ok {
+			return
+		}
+		isIface := false
+		for _, f := range interfaceFields {
+			_, has := parent[f]
+			isIface = isIface || has
+		}
+		ikey, ok := p.keyFor(name)
+		if !isIface || !ok {
+			return
+		}
+		at := confLeaf{path: path}.pathString()
+		if p.names == nil {
+			p.names = map[string][]string{}
+		}
+		p.names[ikey] = append(p.names[ikey], name)
+		p.patchInterface(ikey, parent, at)
+	})
+}
+
+func (p *interfacePatcher) patchInterface(key string, m map[string]any, at string) {
+	s, ip := p.specs[key], p.addrs[key]
+	p.place(key, "")
+
+	if ip != (ifaceAddrs{}) {
+		// flat address/gateway, by the family of the current value
+		a, _ := m["address"].(string)
+		g, _ := m["gateway"].(string)
+		fam := addrFamily(a)
+		if fam == "" {
+			fam = addrFamily(g)
+		}
+		if fam != "" && ip.forFamily(fam) != (AddrGateway{}) {
+			p.setAddrGateway(key, m, ip.forFamily(fam), at)
+			p.place(key, fam)
+		}
+		for _, fam := range []string{familyIPv4, familyIPv6} {
+			want := ip.forFamily(fam)
+			if fam == familyIPv4 && ip.v4 == (AddrGateway{}) {
+				continue // forFamily falls back to IPv6 for ""
+			}
+			block, ok := m[fam].(map[string]any)
+			switch {
+			case ok:
+				p.setAddrGateway(key, block, want, pathKey(at, fam))
+				p.place(key, fam)
+			case want.Address != "" && (m["address"] == nil || m[otherFamily(fam)] != nil):
+				// the family is new to the interface: add its block
+				block = map[string]any{}
+				p.setAddrGateway(key, block, want, pathKey(at, fam))
+				m[fam] = block
+				p.place(key, fam)
+			}
+			// a flat address of the other family leaves no place for this
+			// one; the handler reports it
+		}
+	}
+
+	if s.VlanID != nil {
+		k := "vlanID"
+		for _, f := range []string{"vlanID", "vlanId", "vlan"} {
+			if _, ok := m[f]; ok {
+				k = f
+				break
+			}
+		}
+		p.set(key, m, k, pathKey(at, k), int64(*s.VlanID))
+		p.place(key, "vlanId")
+	}
+
+	if len(s.Routes) > 0 {
+		if old, ok := m["routes"].([]any); ok {
+			var have []CNIRoute
+			b, _ := json.Marshal(old)
+			_ = json.Unmarshal(b, &have)
+			list := make([]any, 0, len(s.Routes))
+			for _, r := range s.cniRoutes() {
+				e := map[string]any{"dst": r.Dst}
+				if r.GW != "" {
+					e["gw"] = r.GW
+				}
+				list = append(list, e)
+			}
+			p.setRoutes(key, have, s.cniRoutes(), pathKey(at, "routes"))
+			m["routes"] = list
+			p.place(key, "routes")
+		}
+	}
+}
+
+func otherFamily(fam string) string {
+	if fam == familyIPv4 {
+		return familyIPv6
+	}
+	return familyIPv4
+}
+
+func (p *interfacePatcher) setAddrGateway(key string, m map[string]any, want AddrGateway, at string) {
+	if want.Address != "" {
+		p.set(key, m, "address", pathKey(at, "address"), want.Address)
+	}
+	if want.Gateway != "" {
+		p.set(key, m, "gateway", pathKey(at, "gateway"), want.Gateway)
+	}
+}
+
+// patchNetworkInstances moves the matched interfaces of an NFDeployment into
+// the requested spec.networkInstances entry.
+func (p *interfacePatcher) patchNetworkInstances(obj map[string]any) {
+	for _, key := range p.keys {
+		want := strings.TrimSpace(p.specs[key].NetworkInstance)
+		if want == "" || len(p.names[key]) == 0 {
+			continue
+		}
+		p.place(key, "networkInstance")
+		list, _, _ := unstructured.NestedSlice(obj, "spec", "networkInstances")
+		for _, name := range p.names[key] {
+			found := false
+			for i, e := range list {
+				ni, ok := e.(map[string]any)
+				if !ok {
+					continue
+				}
+				at := pathIndex("spec.networkInstances", i)
+				members, _ := ni["interfaces"].([]any)
+				kept := make([]any, 0, len(members))
+				has := false
+				for _, m := range members {
+					if m == name {
+						has = true
+						if ni["name"] != want {
+							p.changes = append(p.changes, InterfaceChange{Interface: key, Path: pathKey(at, "interfaces"), Old: name})
+							continue
+						}
+					}
+					kept = append(kept, m)
+				}
+				if ni["name"] == want {
+					found = true
+					if !has {
+						kept = append(kept, name)
+						p.changes = append(p.changes, InterfaceChange{Interface: key, Path: pathKey(at, "interfaces"), New: name})
+					}
+				}
+				ni["interfaces"] = kept
+			}
+			if !found {
+				list = append(list, map[string]any{"name": want, "interfaces": []any{name}})
+				p.changes = append(p.changes, InterfaceChange{Interface: key, Path: pathKey(pathIndex("spec.networkInstances", len(list)-1), "interfaces"), New: name})
+			}
+		}
+		_ = unstructured.SetNestedSlice(obj, list, "spec", "networkInstances")
+	}
+}
+
+// patchNAD patches the typed CNI config of a NAD named after an interface:
+// static IPAM addresses, VLAN and ipam routes.
+func (p *interfacePatcher) patchNAD(obj map[string]any) error {
+	cfgStr, _, _ := unstructured.NestedString(obj, "spec", "config")
+	cfg, err := parseCNIConfig(cfgStr)
+	if err != nil {
+		return invalidArgument("file", "NAD spec.config: %v", err)
+	}
+	name, _, _ := unstructured.NestedString(obj, "metadata", "name")
+	key, ok := p.keyFor(name)
+	if !ok && cfg.Name != "" {
+		key, ok = p.keyFor(cfg.Name)
+	}
+	if !ok {
+		return nil
+	}
+	p.place(key, "")
+	s, ip := p.specs[key], p.addrs[key]
+	const at = "spec.config"
+
+	if ip != (ifaceAddrs{}) && cfg.staticAddresses() != nil {
+		old := append([]CNIAddress(nil), cfg.staticAddresses()...)
+		if cfg.setStaticAddresses(ip) {
+			ob, _ := json.Marshal(old)
+			nb, _ := json.Marshal(cfg.staticAddresses())
+			path := "ipam.addresses"
+			for i, pl := range cfg.Plugins {
+				if pl.IPAM != nil && len(pl.IPAM.Addresses) > 0 {
+					path = cfg.pluginPath(i, path)
+					break
+				}
+			}
+			p.changes = append(p.changes, InterfaceChange{Interface: key, Path: at + "." + path, Old: string(ob), New: string(nb)})
+		}
+		// setStaticAddresses adds the families the NAD lacks
+		for _, fam := range []string{familyIPv4, familyIPv6} {
+			if ip.forFamily(fam) != (AddrGateway{}) {
+				p.place(key, fam)
+			}
+		}
+	}
+
+	if s.VlanID != nil {
+		if old, new, path, ok := setNADVLAN(cfg, *s.VlanID); ok {
+			p.place(key, "vlanId")
+			if old != new {
+				p.changes = append(p.changes, InterfaceChange{Interface: key, Path: at + "." + path, Old: old, New: new})
+			}
+		}
+	}
+
+	if len(s.Routes) > 0 {
+		for i, pl := range cfg.Plugins {
+			if pl.IPAM == nil {
+				continue
+			}
+			p.setRoutes(key, pl.IPAM.Routes, s.cniRoutes(), at+"."+cfg.pluginPath(i, "ipam.routes"))
+			pl.IPAM.Routes = s.cniRoutes()
+			p.place(key, "routes")
+			break
+		}
+	}
+
+	out, err := cfg.Marshal()
+	if err != nil {
+		return err
+	}
+	if out != cfgStr && len(p.changes) > 0 {
+		return unstructured.SetNestedField(obj, out, "spec", "config")
+	}
+	return nil
+}
+
+// pluginPath is the path of field of plugin i within spec.config.
+func (c *CNIConfig) pluginPath(i int, field string) string {
+	if !c.conflist {
+		return field
+	}
+	return pathIndex("plugins", i) + "." + field
+}
+
+// setNADVLAN sets the VLAN of the first plugin with a parent interface:
+// vlanId of the vlan plugin, vlan of sriov and bridge, or the tag of a
+// macvlan/ipvlan master on a VLAN sub-interface (eth1.100). ok is false when
+// the chain has no VLAN to set.
+func setNADVLAN(cfg *CNIConfig, vlan int) (old, new, path string, ok bool) {
+	new = fmt.Sprint(vlan)
+	for i, pl := range cfg.Plugins {
+		switch {
+		case pl.Type == "vlan":
+			if v, ok := pl.raw["vlanId"]; ok {
+				old = fmt.Sprint(v)
+			}
+			pl.raw["vlanId"] = vlan
+			return old, new, cfg.pluginPath(i, "vlanId"), true
+		case pl.Type == "sriov" || pl.Type == "bridge":
+			if pl.VLAN != 0 {
+				old = fmt.Sprint(pl.VLAN)
+			}
+			pl.VLAN = vlan
+			return old, new, cfg.pluginPath(i, "vlan"), true
+		case pl.Master != "":
+			parent, _, tagged := strings.Cut(pl.Master, ".")
+			if !tagged {
+				return "", "", "", false
+			}
+			old, pl.Master = pl.Master, parent+"."+new
+			return old, pl.Master, cfg.pluginPath(i, "master"), true
+		}
+	}
+	return "", "", "", false
+}
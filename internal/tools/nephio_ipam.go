@@ -0,0 +1,477 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"nfreconfig-mcp-server/internal/config"
+	"nfreconfig-mcp-server/internal/kube"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+)
+
+func init() { registerTool(IPAMScan()) }
+
+// Nephio IPAM kinds. NetworkInstance moved from req.nephio.org to
+// ipam.resource.nephio.org; both are read.
+var (
+	ipClaimGVR   = schema.GroupVersionResource{Group: "ipam.resource.nephio.org", Version: "v1alpha1", Resource: "ipclaims"}
+	ipPrefixGVR  = schema.GroupVersionResource{Group: "ipam.resource.nephio.org", Version: "v1alpha1", Resource: "ipprefixes"}
+	interfaceGVR = schema.GroupVersionResource{Group: "req.nephio.org", Version: "v1alpha1", Resource: "interfaces"}
+
+	networkInstanceGVRs = []schema.GroupVersionResource{
+		{Group: "ipam.resource.nephio.org", Version: "v1alpha1", Resource: "networkinstances"},
+		{Group: "req.nephio.org", Version: "v1alpha1", Resource: "networkinstances"},
+	}
+)
+
+// IPAM sources, reported in ScanIssue.Source.
+const (
+	sourceIPClaims         = "ipclaims"
+	sourceIPPrefixes       = "ipprefixes"
+	sourceNetworkInstances = "networkinstances"
+	sourceInterfaces       = "interfaces"
+	sourceRepoFiles        = "repo-files"
+)
+
+type IPAMScanParams struct {
+	Context   string        `json:"context,omitempty" description:"Management cluster kube context; defaults to the -mgmt-context flag, then kube.managementContext from the server config, then the current context."`
+	Namespace string        `json:"namespace,omitempty" description:"Only read IPAM objects in this namespace of the management cluster; all namespaces when empty."`
+	Cluster   *bool         `json:"cluster,omitempty" description:"Read IPAM objects from the management cluster." default:"true"`
+	Repos     []RepoWorkdir `json:"repos,omitempty" description:"Cloned repositories whose IPClaim, IPPrefix, NetworkInstance and Interface manifests are read too."`
+}
+
+// IPClaimInfo is one IPClaim and what IPAM allocated for it.
+type IPClaimInfo struct {
+	Name            string `json:"name"`
+	Namespace       string `json:"namespace,omitempty"`
+	Source          string `json:"source"` // cluster or repo
+	Repo            string `json:"repo,omitempty"`
+	File            string `json:"file,omitempty"`
+	Kind            string `json:"kind,omitempty"` // network, loopback, pool, prefix
+	NetworkInstance string `json:"networkInstance,omitempty"`
+	RequestedPrefix string `json:"requestedPrefix,omitempty"` // spec.prefix, when a specific prefix was asked for
+	AllocatedPrefix string `json:"allocatedPrefix,omitempty"` // status.prefix
+	Gateway         string `json:"gateway,omitempty"`         // status.gateway
+	Family          string `json:"family,omitempty"`
+	Ready           bool   `json:"ready"`
+	// Mismatch is true when a requested prefix was not the one allocated
+	Mismatch bool `json:"mismatch,omitempty"`
+}
+
+// IPAMPrefix is a prefix a network instance allocates from: a NetworkInstance
+// spec.prefixes entry or an IPPrefix object.
+type IPAMPrefix struct {
+	Prefix string `json:"prefix"`
+	Kind   string `json:"kind,omitempty"`
+	Object string `json:"object"` // NetworkInstance/<name> or IPPrefix/<name>
+	Source string `json:"source"`
+	Family string `json:"family,omitempty"`
+}
+
+// NetworkInstanceIPAM groups prefixes and claims by network instance.
+type NetworkInstanceIPAM struct {
+	Name      string        `json:"name"`
+	Prefixes  []IPAMPrefix  `json:"prefixes,omitempty"`
+	Claims    []IPClaimInfo `json:"claims,omitempty"`
+	Claimed   int           `json:"claimed"`
+	Allocated int           `json:"allocated"`
+}
+
+// NephioInterface is a req.nephio.org Interface: an NF interface request.
+type NephioInterface struct {
+	Name            string `json:"name"`
+	Namespace       string `json:"namespace,omitempty"`
+	Source          string `json:"source"`
+	Repo            string `json:"repo,omitempty"`
+	File            string `json:"file,omitempty"`
+	NetworkInstance string `json:"networkInstance,omitempty"`
+	CNIType         string `json:"cniType,omitempty"`
+	AttachmentType  string `json:"attachmentType,omitempty"`
+	IPFamilyPolicy  string `json:"ipFamilyPolicy,omitempty"`
+}
+
+type IPAMScanResult struct {
+	NetworkInstances []NetworkInstanceIPAM `json:"networkInstances"`
+	Interfaces       []NephioInterface     `json:"interfaces,omitempty"`
+	Errors           []ScanIssue           `json:"errors,omitempty"`
+	Warnings         []ScanIssue           `json:"warnings,omitempty"`
+}
+
+func (r *IPAMScanResult) addIssue(source string, err error) {
+	if issue, warning := newScanIssue(source, err); warning {
+		r.Warnings = append(r.Warnings, issue)
+	} else {
+		r.Errors = append(r.Errors, issue)
+	}
+}
+
+func IPAMScan() MCPTool[IPAMScanParams, IPAMScanResult] {
+	return MCPTool[IPAMScanParams, IPAMScanResult]{
+		Name:        "ipam_scan",
+		Description: "Read Nephio IPAM (ipam.resource.nephio.org IPClaim, IPPrefix, NetworkInstance and req.nephio.org Interface) from the management cluster and/or cloned repos. Returns, per network instance, its prefixes and the claims with requested vs allocated prefix and gateway, and the NF interface requests. Use before planning or patching IPs so Nephio IPAM stays the source of truth. Example: {\"namespace\":\"default\",\"repos\":[{\"name\":\"cucp\",\"workdir\":\"/work/cucp\"}]}.",
+		Handler: func(ctx context.Context, cc *mcp.ServerSession, params *mcp.CallToolParamsFor[IPAMScanParams]) (*mcp.CallToolResultFor[IPAMScanResult], error) {
+			useCluster := params.Arguments.Cluster == nil || *params.Arguments.Cluster
+			if !useCluster && len(params.Arguments.Repos) == 0 {
+				return toolErr[IPAMScanResult](invalidArgument("repos", "nothing to scan: cluster is false and no repos given"))
+			}
+
+			var out IPAMScanResult
+			var objs []manifestObject
+			if useCluster {
+				mgmtCtx, err := defaultMgmtContext(params.Arguments.Context)
+				if err != nil {
+					return toolErr[IPAMScanResult](err)
+				}
+				mgmt, err := kube.DefaultPool().Management(ctx, mgmtCtx)
+				if err != nil {
+					return toolErr[IPAMScanResult](fmt.Errorf("build clients (context=%s): %w", mgmtCtx, err))
+				}
+				objs = append(objs, listIPAMObjects(ctx, mgmt.Dynamic, strings.TrimSpace(params.Arguments.Namespace), out.addIssue)...)
+			}
+			for _, r := range params.Arguments.Repos {
+				r.Name, r.Workdir = strings.TrimSpace(r.Name), cleanPath(r.Workdir)
+				if r.Workdir == "" {
+					continue
+				}
+				objs = append(objs, readIPAMObjects(r, out.addIssue)...)
+			}
+
+			out.NetworkInstances, out.Interfaces = summarizeIPAM(objs)
+			return toolOK(out), nil
+		},
+	}
+}
+
+// manifestObject is an object and where it was read.
+type manifestObject struct {
+	u          *unstructured.Unstructured
+	source     string // cluster or repo
+	repo, file string
+}
+
+var ipamKinds = map[string]bool{"IPClaim": true, "IPPrefix": true, "NetworkInstance": true, "Interface": true}
+
+// listIPAMObjects reads the IPAM kinds from a cluster.
+func listIPAMObjects(ctx context.Context, dyn dynamic.Interface, namespace string, report func(string, error)) []manifestObject {
+	var out []manifestObject
+	add := func(items []unstructured.Unstructured) {
+		for i := range items {
+			out = append(out, manifestObject{u: &items[i], source: "cluster"})
+		}
+	}
+	for _, l := range []struct {
+		source string
+		gvr    schema.GroupVersionResource
+	}{
+		{sourceIPClaims, ipClaimGVR},
+		{sourceIPPrefixes, ipPrefixGVR},
+		{sourceInterfaces, interfaceGVR},
+	} {
+		ul, err := dyn.Resource(l.gvr).Namespace(namespace).List(ctx, metav1.ListOptions{})
+		if err != nil {
+			report(l.source, err)
+			continue
+		}
+		add(ul.Items)
+	}
+	var err error
+	for _, gvr := range networkInstanceGVRs {
+		var ul *unstructured.UnstructuredList
+		if ul, err = dyn.Resource(gvr).Namespace(namespace).List(ctx, metav1.ListOptions{}); err == nil {
+			add(ul.Items)
+			break
+		}
+	}
+	if err != nil {
+		report(sourceNetworkInstances, err)
+	}
+	return out
+}
+
+// readIPAMObjects reads the IPAM kinds from the YAML files of a cloned repo.
+func readIPAMObjects(r RepoWorkdir, report func(string, error)) []manifestObject {
+	return readManifestObjects(r, func(u *unstructured.Unstructured) bool {
+		return ipamKinds[u.GetKind()] && isNephioIPAMGroup(u.GetAPIVersion())
+	}, report)
+}
+
+// readManifestObjects reads the objects keep selects from the YAML files of a
+// cloned repo.
+func readManifestObjects(r RepoWorkdir, keep func(*unstructured.Unstructured) bool, report func(string, error)) []manifestObject {
+	var out []manifestObject
+	maxFiles, count := config.Current().Scan.MaxFiles, 0
+	err := filepath.WalkDir(r.Workdir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			report(sourceRepoFiles, toolError(err, path))
+			return nil
+		}
+		if d.IsDir() {
+			if d.Name() == ".git" {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if ext := strings.ToLower(filepath.Ext(d.Name())); ext != ".yaml" && ext != ".yml" {
+			return nil
+		}
+		if count++; count > maxFiles {
+			return fs.SkipAll
+		}
+		b, err := os.ReadFile(path)
+		if err != nil {
+			report(sourceRepoFiles, toolError(err, path))
+			return nil
+		}
+		rel, _ := filepath.Rel(r.Workdir, path)
+		for _, doc := range splitYAMLDocuments(string(b)) {
+			u, err := parseYAMLToUnstructured([]byte(doc))
+			if err != nil || !keep(u) {
+				continue
+			}
+			out = append(out, manifestObject{u: u, source: "repo", repo: r.Name, file: filepath.ToSlash(rel)})
+		}
+		return nil
+	})
+	if err != nil {
+		report(sourceRepoFiles, toolError(err, r.Workdir))
+	}
+	return out
+}
+
+func isNephioIPAMGroup(apiVersion string) bool {
+	g, _, _ := strings.Cut(apiVersion, "/")
+	return g == "ipam.resource.nephio.org" || g == "req.nephio.org"
+}
+
+// ipClaimInfo reads an IPClaim.
+func ipClaimInfo(o manifestObject) IPClaimInfo {
+	obj := o.u.Object
+	c := IPClaimInfo{
+		Name:      o.u.GetName(),
+		Namespace: o.u.GetNamespace(),
+		Source:    o.source,
+		Repo:      o.repo,
+		File:      o.file,
+	}
+	c.Kind, _, _ = unstructured.NestedString(obj, "spec", "kind")
+	c.NetworkInstance, _, _ = unstructured.NestedString(obj, "spec", "networkInstance", "name")
+	c.RequestedPrefix, _, _ = unstructured.NestedString(obj, "spec", "prefix")
+	c.AllocatedPrefix, _, _ = unstructured.NestedString(obj, "status", "prefix")
+	c.Gateway, _, _ = unstructured.NestedString(obj, "status", "gateway")
+	c.Ready = conditionTrue(obj, "Ready")
+	if c.Family = addrFamily(c.AllocatedPrefix); c.Family == "" {
+		c.Family = addrFamily(c.RequestedPrefix)
+	}
+	c.Mismatch = c.RequestedPrefix != "" && c.AllocatedPrefix != "" && c.RequestedPrefix != c.AllocatedPrefix
+	return c
+}
+
+// conditionTrue reports whether status.conditions has type t with status True.
+func conditionTrue(obj map[string]any, t string) bool {
+	conds, _, _ := unstructured.NestedSlice(obj, "status", "conditions")
+	for _, c := range conds {
+		m, _ := c.(map[string]any)
+		if m["type"] == t {
+			s, _ := m["status"].(string)
+			return strings.EqualFold(s, "true")
+		}
+	}
+	return false
+}
+
+// summarizeIPAM groups the objects by network instance.
+func summarizeIPAM(objs []manifestObject) ([]NetworkInstanceIPAM, []NephioInterface) {
+	nis := map[string]*NetworkInstanceIPAM{}
+	ni := func(name string) *NetworkInstanceIPAM {
+		if nis[name] == nil {
+			nis[name] = &NetworkInstanceIPAM{Name: name}
+		}
+		return nis[name]
+	}
+	var ifaces []NephioInterface
+	for _, o := range objs {
+		obj := o.u.Object
+		switch o.u.GetKind() {
+		case "IPClaim":
+			c := ipClaimInfo(o)
+			n := ni(c.NetworkInstance)
+			n.Claims = append(n.Claims, c)
+			n.Claimed++
+			if c.AllocatedPrefix != "" {
+				n.Allocated++
+			}
+		case "IPPrefix":
+			name, _, _ := unstructured.NestedString(obj, "spec", "networkInstance", "name")
+			p, _, _ := unstructured.NestedString(obj, "spec", "prefix")
+			kind, _, _ := unstructured.NestedString(obj, "spec", "kind")
+			n := ni(name)
+			n.Prefixes = append(n.Prefixes, IPAMPrefix{Prefix: p, Kind: kind, Object: "IPPrefix/" + o.u.GetName(), Source: o.source, Family: addrFamily(p)})
+		case "NetworkInstance":
+			n := ni(o.u.GetName())
+			ps, _, _ := unstructured.NestedSlice(obj, "spec", "prefixes")
+			for _, e := range ps {
+				m, _ := e.(map[string]any)
+				p, _ := m["prefix"].(string)
+				if p == "" {
+					continue
+				}
+				n.Prefixes = append(n.Prefixes, IPAMPrefix{Prefix: p, Object: "NetworkInstance/" + o.u.GetName(), Source: o.source, Family: addrFamily(p)})
+			}
+		case "Interface":
+			i := NephioInterface{Name: o.u.GetName(), Namespace: o.u.GetNamespace(), Source: o.source, Repo: o.repo, File: o.file}
+			i.NetworkInstance, _, _ = unstructured.NestedString(obj, "spec", "networkInstance", "name")
+			i.CNIType, _, _ = unstructured.NestedString(obj, "spec", "cniType")
+			i.AttachmentType, _, _ = unstructured.NestedString(obj, "spec", "attachmentType")
+			i.IPFamilyPolicy, _, _ = unstructured.NestedString(obj, "spec", "ipFamilyPolicy")
+			ifaces = append(ifaces, i)
+		}
+	}
+
+	out := make([]NetworkInstanceIPAM, 0, len(nis))
+	for _, n := range nis {
+		sort.Slice(n.Claims, func(i, j int) bool {
+			a, b := n.Claims[i], n.Claims[j]
+			if a.Namespace+"/"+a.Name != b.Namespace+"/"+b.Name {
+				return a.Namespace+"/"+a.Name < b.Namespace+"/"+b.Name
+			}
+			return a.Source < b.Source
+		})
+		sort.Slice(n.Prefixes, func(i, j int) bool { return n.Prefixes[i].Prefix < n.Prefixes[j].Prefix })
+		out = append(out, *n)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	sort.Slice(ifaces, func(i, j int) bool {
+		if ifaces[i].Namespace != ifaces[j].Namespace {
+			return ifaces[i].Namespace < ifaces[j].Namespace
+		}
+		return ifaces[i].Name < ifaces[j].Name
+	})
+	return out, ifaces
+}
+
+// ---------------- IPClaim lookup for patching ----------------
+
+// resolveIPClaims turns interface -> IPClaim references into newIps entries:
+// status.prefix becomes the address and status.gateway the gateway, per family.
+// Claims are looked up in workdirs first, then on the management cluster.
+func resolveIPClaims(ctx context.Context, refs map[string][]string, workdirs []string, mgmtContext string) (map[string]IPInfo, error) {
+	var repoObjs []manifestObject
+	for _, wd := range workdirs {
+		repoObjs = append(repoObjs, readIPAMObjects(RepoWorkdir{Workdir: wd}, func(string, error) {})...)
+	}
+	var dyn dynamic.Interface
+
+	out := map[string]IPInfo{}
+	for iface, names := range refs {
+		iface = strings.TrimSpace(iface)
+		var ip IPInfo
+		for _, ref := range names {
+			ns, name, hasNS := strings.Cut(strings.TrimSpace(ref), "/")
+			if !hasNS {
+				ns, name = "", ns
+			}
+			field := "ipClaims." + iface
+			c, found, err := findRepoClaim(repoObjs, ns, name)
+			if err != nil {
+				return nil, invalidArgument(field, "%v", err)
+			}
+			if !found {
+				if dyn == nil {
+					mgmtCtx, err := defaultMgmtContext(mgmtContext)
+					if err != nil {
+						return nil, err
+					}
+					mgmt, err := kube.DefaultPool().Management(ctx, mgmtCtx)
+					if err != nil {
+						return nil, fmt.Errorf("build clients (context=%s): %w", mgmtCtx, err)
+					}
+					dyn = mgmt.Dynamic
+				}
+				if c, err = findClusterClaim(ctx, dyn, ns, name); err != nil {
+					return nil, toolError(err, ref)
+				}
+			}
+			if c.AllocatedPrefix == "" {
+				return nil, &ToolError{
+					Code:      CodeUnavailable,
+					Message:   fmt.Sprintf("IPClaim %s has no allocated prefix yet", ref),
+					Target:    ref,
+					Retryable: true,
+					Hint:      "wait for Nephio IPAM to allocate it (status.prefix), or pass the address in newIps",
+				}
+			}
+			ag := &AddrGateway{Address: c.AllocatedPrefix, Gateway: c.Gateway}
+			dst := &ip.IPv4
+			if c.Family == familyIPv6 {
+				dst = &ip.IPv6
+			}
+			if *dst != nil {
+				return nil, invalidArgument(field, "two %s claims for interface %s", c.Family, iface)
+			}
+			*dst = ag
+		}
+		out[iface] = ip
+	}
+	return out, nil
+}
+
+func findRepoClaim(objs []manifestObject, ns, name string) (IPClaimInfo, bool, error) {
+	var hits []IPClaimInfo
+	for _, o := range objs {
+		if o.u.GetKind() == "IPClaim" && o.u.GetName() == name && (ns == "" || o.u.GetNamespace() == ns) {
+			hits = append(hits, ipClaimInfo(o))
+		}
+	}
+	switch len(hits) {
+	case 0:
+		return IPClaimInfo{}, false, nil
+	case 1:
+		return hits[0], true, nil
+	}
+	files := make([]string, 0, len(hits))
+	for _, h := range hits {
+		files = append(files, h.File)
+	}
+	return IPClaimInfo{}, false, fmt.Errorf("IPClaim %s is in several files (%s); qualify it as namespace/name", name, strings.Join(files, ", "))
+}
+
+func findClusterClaim(ctx context.Context, dyn dynamic.Interface, ns, name string) (IPClaimInfo, error) {
+	if ns != "" {
+		u, err := dyn.Resource(ipClaimGVR).Namespace(ns).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return IPClaimInfo{}, err
+		}
+		return ipClaimInfo(manifestObject{u: u, source: "cluster"}), nil
+	}
+	ul, err := dyn.Resource(ipClaimGVR).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return IPClaimInfo{}, err
+	}
+	var hits []IPClaimInfo
+	for i := range ul.Items {
+		if ul.Items[i].GetName() == name {
+			hits = append(hits, ipClaimInfo(manifestObject{u: &ul.Items[i], source: "cluster"}))
+		}
+	}
+	switch len(hits) {
+	case 0:
+		return IPClaimInfo{}, &ToolError{Code: CodeNotFound, Message: fmt.Sprintf("IPClaim %s not found in the repos or on the management cluster", name), Target: name}
+	case 1:
+		return hits[0], nil
+	}
+	nss := make([]string, 0, len(hits))
+	for _, h := range hits {
+		nss = append(nss, h.Namespace)
+	}
+	return IPClaimInfo{}, invalidArgument(name, "IPClaim %s exists in namespaces %s; qualify it as namespace/name", name, strings.Join(nss, ", "))
+}
@@ -0,0 +1,641 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"net/netip"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"nfreconfig-mcp-server/internal/config"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"gopkg.in/yaml.v3"
+)
+
+func init() { registerTool(RepoFindReferences()) }
+
+// referenceFileExts are the files searched: manifests and Helm values, and
+// configuration files kept next to them.
+var referenceFileExts = map[string]bool{
+	".yaml": true, ".yml": true, ".json": true, ".conf": true, ".cfg": true, ".ini": true,
+	".toml": true, ".properties": true, ".env": true, ".tpl": true, ".txt": true,
+}
+
+const defaultMaxReferenceMatches = 1000
+
+type RepoFindReferencesParams struct {
+	Repos        []RepoWorkdir `json:"repos" description:"Cloned repositories to search."`
+	Needles      []string      `json:"needles" description:"IPs, CIDRs or hostnames to find. Matched as whole tokens: 10.10.1.5 does not match 10.10.1.50, and an IP matches in any notation (10.10.1.5:38412, [fd00::5]:2152, http://10.10.1.5/)." example:"[\"10.10.1.5\",\"192.168.10.0/24\",\"amf.core.svc\"]"`
+	Paths        []string      `json:"paths,omitempty" description:"Only search repo-relative files matching one of these glob patterns (du/*.yaml); a pattern without a slash matches the file name in any directory (values.yaml)."`
+	ContextLines *int          `json:"contextLines,omitempty" description:"Lines of context before and after each match (default 2, max 10)."`
+	MaxFiles     int           `json:"maxFiles,omitempty" description:"Maximum files to read per repo; defaults to scan.maxFiles from the server config (5000)."`
+	MaxMatches   int           `json:"maxMatches,omitempty" description:"Stop after this many matches (default 1000)."`
+}
+
+// ReferenceMatch is one occurrence of a needle.
+type ReferenceMatch struct {
+	Needle string `json:"needle"`
+	Match  string `json:"match"` // the token as written, e.g. 10.10.1.5:38412
+	Repo   string `json:"repo"`
+	File   string `json:"file"`
+	Line   int    `json:"line"`
+	Column int    `json:"column"`
+
+	// Document is the index of the YAML document in the file, from 0
+	Document  int    `json:"document"`
+	Kind      string `json:"kind,omitempty"`
+	Name      string `json:"name,omitempty"`
+	Namespace string `json:"namespace,omitempty"`
+	// Path is the field holding the match in a YAML or JSON file
+	// (spec.interfaces[0].ipv4.address, data["du.conf"])
+	Path string `json:"path,omitempty"`
+	// EmbeddedFormat and EmbeddedPath locate the match inside a field's string
+	// content (NAD config JSON, a ConfigMap's config file): format json, yaml,
+	// ini or text and the key path in it; for other files, the key on the line
+	EmbeddedFormat string `json:"embeddedFormat,omitempty"`
+	EmbeddedPath   string `json:"embeddedPath,omitempty"`
+
+	Before []string `json:"before,omitempty"`
+	Text   string   `json:"text"`
+	After  []string `json:"after,omitempty"`
+}
+
+type RepoFindReferencesResult struct {
+	Matches []ReferenceMatch `json:"matches"`
+	Counts  map[string]int   `json:"counts"` // matches per needle
+	// Unmatched are the needles found nowhere
+	Unmatched []string `json:"unmatched,omitempty"`
+	// Targets are the YAML files with matches, every document included, to
+	// pass to manifest_patch_config_refs
+	Targets []PatchTarget `json:"targets"`
+	// EmbeddedTargets are the documents with matches inside embedded
+	// configs, to pass to manifest_patch_embedded_config for edits that keep
+	// the config's layout
+	EmbeddedTargets []PatchTarget `json:"embeddedTargets,omitempty"`
+	// OtherFiles have matches no patch tool edits (non-YAML files, Helm
+	// templates); update them by hand
+	OtherFiles []PatchTarget `json:"otherFiles,omitempty"`
+	Scanned    int           `json:"scanned"` // files searched
+	Truncated  bool          `json:"truncated,omitempty"`
+	Errors     []*ToolError  `json:"errors,omitempty"`
+}
+
+func RepoFindReferences() MCPTool[RepoFindReferencesParams, RepoFindReferencesResult] {
+	return MCPTool[RepoFindReferencesParams, RepoFindReferencesResult]{
+		Name:        "repo_find_references",
+		Description: "Find every place cloned repos reference given IPs, CIDRs or hostnames: YAML fields, JSON/YAML/INI embedded in strings (NAD config, ConfigMap data), Helm values and other config files. Matches whole tokens only (10.10.1.5 does not match 10.10.1.50) and returns file, YAML document, field path, path inside embedded content, line, column and surrounding lines; targets (YAML files) can be passed to manifest_patch_config_refs, embeddedTargets (documents with matches inside embedded configs) to manifest_patch_embedded_config, and otherFiles need editing by hand. Use in Phase 4 to find what references the old CUCP addresses. Example: {\"repos\":[{\"name\":\"du\",\"workdir\":\"/work/du\"}],\"needles\":[\"10.10.1.5\",\"192.168.10.0/24\"]}.",
+		Handler: func(ctx context.Context, cc *mcp.ServerSession, params *mcp.CallToolParamsFor[RepoFindReferencesParams]) (*mcp.CallToolResultFor[RepoFindReferencesResult], error) {
+			a := params.Arguments
+			repos := make([]RepoWorkdir, 0, len(a.Repos))
+			for _, r := range a.Repos {
+				r.Name, r.Workdir = strings.TrimSpace(r.Name), cleanPath(r.Workdir)
+				if r.Name != "" && r.Workdir != "" {
+					repos = append(repos, r)
+				}
+			}
+			if len(repos) == 0 {
+				return toolErr[RepoFindReferencesResult](missingField("repos"))
+			}
+			if len(a.Needles) == 0 {
+				return toolErr[RepoFindReferencesResult](missingField("needles"))
+			}
+			needles := make([]needle, 0, len(a.Needles))
+			for _, s := range a.Needles {
+				n, err := parseNeedle(s)
+				if err != nil {
+					return toolErr[RepoFindReferencesResult](err)
+				}
+				needles = append(needles, n)
+			}
+			for _, p := range a.Paths {
+				if _, err := path.Match(p, ""); err != nil {
+					return toolErr[RepoFindReferencesResult](invalidArgument("paths", "bad pattern %q: %v", p, err))
+				}
+			}
+			contextLines := 2
+			if a.ContextLines != nil {
+				contextLines = min(max(*a.ContextLines, 0), 10)
+			}
+			maxFiles := a.MaxFiles
+			if maxFiles <= 0 {
+				maxFiles = config.Current().Scan.MaxFiles
+			}
+			maxMatches := a.MaxMatches
+			if maxMatches <= 0 {
+				maxMatches = defaultMaxReferenceMatches
+			}
+
+			out := RepoFindReferencesResult{Matches: []ReferenceMatch{}, Counts: map[string]int{}, Targets: []PatchTarget{}}
+			for _, n := range needles {
+				out.Counts[n.raw] = 0
+			}
+			for _, r := range repos {
+				count := 0
+				err := filepath.WalkDir(r.Workdir, func(p string, d fs.DirEntry, err error) error {
+					if err != nil {
+						out.Errors = append(out.Errors, toolError(err, p))
+						return nil
+					}
+					if ctx.Err() != nil {
+						return ctx.Err()
+					}
+					if d.IsDir() {
+						if d.Name() == ".git" {
+							return fs.SkipDir
+						}
+						return nil
+					}
+					if !referenceFileExts[strings.ToLower(filepath.Ext(d.Name()))] {
+						return nil
+					}
+					rel, _ := filepath.Rel(r.Workdir, p)
+					rel = filepath.ToSlash(rel)
+					if !pathSelected(rel, a.Paths) {
+						return nil
+					}
+					if count++; count > maxFiles {
+						return fs.SkipAll
+					}
+					b, err := os.ReadFile(p)
+					if err != nil {
+						out.Errors = append(out.Errors, toolError(err, rel))
+						return nil
+					}
+					out.Scanned++
+					ms := findReferences(b, rel, needles, contextLines)
+					if len(ms) == 0 {
+						return nil
+					}
+					target := PatchTarget{Repo: r.Name, Workdir: r.Workdir, File: rel}
+					if yamlPatchable(rel, b) {
+						out.Targets = append(out.Targets, target)
+						embedded := map[int]bool{}
+						for _, m := range ms {
+							switch m.EmbeddedFormat {
+							case formatJSON, formatLibconfig, formatYAML:
+							default:
+								continue // ini and text payloads have no keyed edits
+							}
+							if m.Path == "" || embedded[m.Document] {
+								continue
+							}
+							embedded[m.Document] = true
+							out.EmbeddedTargets = append(out.EmbeddedTargets, PatchTarget{
+								Repo: r.Name, Workdir: r.Workdir, File: rel,
+								Kind: m.Kind, Name: m.Name, Namespace: m.Namespace,
+							})
+						}
+					} else {
+						out.OtherFiles = append(out.OtherFiles, target)
+					}
+					for _, m := range ms {
+						if len(out.Matches) >= maxMatches {
+							out.Truncated = true
+							return fs.SkipAll
+						}
+						m.Repo, m.File = r.Name, rel
+						out.Matches = append(out.Matches, m)
+						out.Counts[m.Needle]++
+					}
+					return nil
+				})
+				if err != nil {
+					if ctx.Err() != nil {
+						return toolErr[RepoFindReferencesResult](ctx.Err())
+					}
+					out.Errors = append(out.Errors, toolError(err, r.Workdir))
+				}
+				if out.Truncated {
+					break
+				}
+			}
+			for _, n := range needles {
+				if out.Counts[n.raw] == 0 {
+					out.Unmatched = append(out.Unmatched, n.raw)
+				}
+			}
+			return toolOK(out), nil
+		},
+	}
+}
+
+// yamlPatchable reports whether manifest_patch_config_refs can rewrite the
+// file: YAML that parses into objects, so not JSON (written back as YAML) nor
+// a Helm template.
+func yamlPatchable(rel string, b []byte) bool {
+	switch strings.ToLower(path.Ext(rel)) {
+	case ".yaml", ".yml":
+	default:
+		return false
+	}
+	_, err := parseYAMLDocuments(b)
+	return err == nil
+}
+
+// pathSelected reports whether rel matches one of patterns, by its full path
+// or its base name; no patterns selects everything.
+func pathSelected(rel string, patterns []string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, p := range patterns {
+		p = strings.TrimPrefix(strings.TrimSpace(p), "./")
+		if ok, _ := path.Match(p, rel); ok {
+			return true
+		}
+		if ok, _ := path.Match(p, path.Base(rel)); ok && !strings.Contains(p, "/") {
+			return true
+		}
+	}
+	return false
+}
+
+// needle is a value to find as a whole token: an IP (in any notation), a
+// CIDR, or a hostname or other literal.
+type needle struct {
+	raw    string
+	addr   netip.Addr
+	prefix netip.Prefix
+	host   string // lowercased, when neither an IP nor a CIDR
+}
+
+func parseNeedle(s string) (needle, error) {
+	s = strings.TrimSpace(s)
+	n := needle{raw: s}
+	switch {
+	case s == "" || strings.ContainsAny(s, " \t\r\n\"'"):
+		return n, invalidArgument("needles", "invalid needle %q: need an IP, CIDR or hostname", s)
+	case strings.Contains(s, "/"):
+		p, err := netip.ParsePrefix(s)
+		if err != nil {
+			return n, invalidArgument("needles", "invalid CIDR %q: %v", s, err)
+		}
+		n.prefix = p
+	default:
+		if a, err := netip.ParseAddr(s); err == nil {
+			n.addr = a.WithZone("")
+		} else {
+			n.host = strings.ToLower(s)
+		}
+	}
+	return n, nil
+}
+
+// tokenMatch is a needle found in a string: the token it is in, and the part
+// of the token that is the needle (the address of 10.10.1.5/24 or of
+// 10.10.1.5:38412), as byte offsets.
+type tokenMatch struct {
+	tokStart, tokEnd int
+	start, end       int
+	needle           int // index in the needles searched
+}
+
+func isRefTokenChar(c byte) bool {
+	return c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' ||
+		c == '.' || c == ':' || c == '/' || c == '%' || c == '-' || c == '_'
+}
+
+// matchNeedles returns the whole tokens of s that are one of needles. A token
+// is a run of address and hostname characters without trailing punctuation.
+func matchNeedles(s string, needles []needle) []tokenMatch {
+	var out []tokenMatch
+	for i := 0; i < len(s); {
+		if !isRefTokenChar(s[i]) {
+			i++
+			continue
+		}
+		j := i
+		for j < len(s) && isRefTokenChar(s[j]) {
+			j++
+		}
+		tok := trimRefToken(s[i:j])
+		for k, n := range needles {
+			if ts, te, ok := n.matchToken(tok); ok {
+				out = append(out, tokenMatch{tokStart: i, tokEnd: i + len(tok), start: i + ts, end: i + te, needle: k})
+				break
+			}
+		}
+		i = j
+	}
+	return out
+}
+
+// trimRefToken drops trailing punctuation from tok (a sentence's full stop,
+// a key's colon) but keeps the :: of an IPv6 address such as fd00::.
+func trimRefToken(tok string) string {
+	trimmed := strings.TrimRight(tok, ".:-")
+	for k := len(tok); k > len(trimmed); k-- {
+		if _, err := netip.ParseAddr(tok[:k]); err == nil {
+			return tok[:k]
+		}
+	}
+	return trimmed
+}
+
+// matchToken reports whether tok is n, and which part of tok: the address of a
+// CIDR, host:port or URL for an IP, the host of host:port or a URL for a
+// hostname, the whole token for a CIDR.
+func (n needle) matchToken(tok string) (start, end int, ok bool) {
+	if tok == "" {
+		return 0, 0, false
+	}
+	type part struct {
+		s   string
+		off int
+	}
+	parts := []part{{tok, 0}}
+	if i := strings.Index(tok, "://"); i >= 0 {
+		host := tok[i+3:]
+		if j := strings.IndexByte(host, '/'); j >= 0 {
+			host = host[:j]
+		}
+		parts = []part{{host, i + 3}}
+	} else if j := strings.IndexByte(tok, '/'); j >= 0 && !n.prefix.IsValid() {
+		// 10.10.1.5/24, or host:port/path
+		parts = append(parts, part{tok[:j], 0})
+	}
+	for _, p := range parts {
+		switch {
+		case n.prefix.IsValid():
+			if q, err := netip.ParsePrefix(p.s); err == nil && q == n.prefix {
+				return p.off, p.off + len(p.s), true
+			}
+		case n.addr.IsValid():
+			if a, err := netip.ParseAddr(p.s); err == nil && a.WithZone("") == n.addr {
+				return p.off, p.off + len(p.s), true
+			}
+			if a, err := netip.ParseAddrPort(p.s); err == nil && a.Addr().WithZone("") == n.addr {
+				return p.off, p.off + strings.LastIndexByte(p.s, ':'), true
+			}
+			if q, err := netip.ParsePrefix(p.s); err == nil && q.Addr() == n.addr {
+				return p.off, p.off + strings.IndexByte(p.s, '/'), true
+			}
+		default:
+			h := p.s
+			if i := strings.LastIndexByte(h, ':'); i > 0 {
+				if _, err := strconv.Atoi(h[i+1:]); err == nil {
+					h = h[:i]
+				}
+			}
+			if strings.EqualFold(h, n.host) {
+				return p.off, p.off + len(h), true
+			}
+			if strings.EqualFold(p.s, n.host) {
+				return p.off, p.off + len(p.s), true
+			}
+		}
+	}
+	return 0, 0, false
+}
+
+// findReferences returns the matches of needles in a file. YAML files are read
+// document by document, so each match gets its field path; other files, and
+// YAML that does not parse (Helm templates), are searched line by line.
+func findReferences(b []byte, rel string, needles []needle, contextLines int) []ReferenceMatch {
+	text := string(b)
+	lines := strings.Split(text, "\n")
+	for i := range lines {
+		lines[i] = strings.TrimSuffix(lines[i], "\r")
+	}
+	ext := strings.ToLower(path.Ext(rel))
+	if ext == ".yaml" || ext == ".yml" {
+		if out, ok := findYAMLReferences(b, lines, needles, contextLines); ok {
+			return out
+		}
+	}
+
+	var out []ReferenceMatch
+	off := 0
+	for l, line := range lines {
+		for _, tm := range matchNeedles(line, needles) {
+			m := referenceAt(lines, l, tm.tokStart, line[tm.tokStart:tm.tokEnd], tm, needles, contextLines)
+			if ext == ".json" {
+				m.Path, _ = jsonPathAt(text, off+tm.start)
+			} else {
+				m.EmbeddedFormat, m.EmbeddedPath = textKeyAt(lines, l)
+			}
+			out = append(out, m)
+		}
+		off += len(line) + 1
+	}
+	return out
+}
+
+// findYAMLReferences searches the string values of each YAML document; ok is
+// false when the file is not valid YAML.
+func findYAMLReferences(b []byte, lines []string, needles []needle, contextLines int) (out []ReferenceMatch, ok bool) {
+	dec := yaml.NewDecoder(bytes.NewReader(b))
+	for doc := 0; ; doc++ {
+		var n yaml.Node
+		if err := dec.Decode(&n); errors.Is(err, io.EOF) {
+			return out, true
+		} else if err != nil {
+			return nil, false
+		}
+		kind, name, namespace := yamlObjectMeta(&n)
+		walkYAMLScalars(&n, "", func(p string, v *yaml.Node) {
+			tms := matchNeedles(v.Value, needles)
+			line, col := v.Line-1, v.Column-1
+			for _, tm := range tms {
+				tok := v.Value[tm.tokStart:tm.tokEnd]
+				l, c, found := locateToken(lines, line, col, tok)
+				if found {
+					line, col = l, c+len(tok)
+				} else {
+					l, c = v.Line-1, v.Column-1
+				}
+				m := referenceAt(lines, l, c, tok, tm, needles, contextLines)
+				m.Document, m.Kind, m.Name, m.Namespace, m.Path = doc, kind, name, namespace, p
+				if strings.Contains(v.Value, "\n") || strings.HasPrefix(strings.TrimSpace(v.Value), "{") || strings.HasPrefix(strings.TrimSpace(v.Value), "[") {
+					m.EmbeddedFormat, m.EmbeddedPath = embeddedPathAt(v.Value, tm.start)
+				}
+				out = append(out, m)
+			}
+		})
+	}
+}
+
+// referenceAt builds the match of tm found at line l, column c (0-based) of
+// lines, where tok is the token as written.
+func referenceAt(lines []string, l, c int, tok string, tm tokenMatch, needles []needle, contextLines int) ReferenceMatch {
+	m := ReferenceMatch{
+		Needle: needles[tm.needle].raw,
+		Match:  tok,
+		Line:   l + 1,
+		Column: c + tm.start - tm.tokStart + 1,
+	}
+	if l < len(lines) {
+		m.Text = lines[l]
+		m.Before = lines[max(0, l-contextLines):l]
+		m.After = lines[l+1 : min(len(lines), l+1+contextLines)]
+	}
+	return m
+}
+
+// locateToken finds tok as a whole token in lines at or after line, col
+// (0-based).
+func locateToken(lines []string, line, col int, tok string) (int, int, bool) {
+	for l := max(line, 0); l < len(lines); l++ {
+		s, from := lines[l], 0
+		if l == line {
+			from = min(max(col, 0), len(s))
+		}
+		for {
+			i := strings.Index(s[from:], tok)
+			if i < 0 {
+				break
+			}
+			i += from
+			end := i + len(tok)
+			before := i == 0 || !isRefTokenChar(s[i-1])
+			after := end == len(s) || !isRefTokenChar(s[end]) || strings.TrimRight(s[end:], ".:-") == "" ||
+				strings.IndexByte(".:-", s[end]) >= 0 && (end+1 == len(s) || !isRefTokenChar(s[end+1]))
+			if before && after {
+				return l, i, true
+			}
+			from = i + 1
+		}
+	}
+	return 0, 0, false
+}
+
+// yamlObjectMeta returns the kind, name and namespace of a YAML document.
+func yamlObjectMeta(doc *yaml.Node) (kind, name, namespace string) {
+	if doc.Kind == yaml.DocumentNode && len(doc.Content) > 0 {
+		doc = doc.Content[0]
+	}
+	get := func(m *yaml.Node, key string) *yaml.Node {
+		if m == nil || m.Kind != yaml.MappingNode {
+			return nil
+		}
+		for i := 0; i+1 < len(m.Content); i += 2 {
+			if m.Content[i].Value == key {
+				return m.Content[i+1]
+			}
+		}
+		return nil
+	}
+	if n := get(doc, "kind"); n != nil {
+		kind = n.Value
+	}
+	md := get(doc, "metadata")
+	if n := get(md, "name"); n != nil {
+		name = n.Value
+	}
+	if n := get(md, "namespace"); n != nil {
+		namespace = n.Value
+	}
+	return kind, name, namespace
+}
+
+// walkYAMLScalars calls fn with every scalar value under n and its path.
+func walkYAMLScalars(n *yaml.Node, p string, fn func(p string, v *yaml.Node)) {
+	switch n.Kind {
+	case yaml.DocumentNode:
+		for _, c := range n.Content {
+			walkYAMLScalars(c, p, fn)
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			walkYAMLScalars(n.Content[i+1], pathKey(p, n.Content[i].Value), fn)
+		}
+	case yaml.SequenceNode:
+		for i, c := range n.Content {
+			walkYAMLScalars(c, pathIndex(p, i), fn)
+		}
+	case yaml.ScalarNode:
+		fn(p, n)
+	}
+}
+
+var plainPathKey = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_-]*$`)
+
+// pathKey appends a map key to a field path: spec.amf, data["du.conf"].
+func pathKey(p, k string) string {
+	if !plainPathKey.MatchString(k) {
+		return p + "[" + strconv.Quote(k) + "]"
+	}
+	if p == "" {
+		return k
+	}
+	return p + "." + k
+}
+
+func pathIndex(p string, i int) string {
+	return p + "[" + strconv.Itoa(i) + "]"
+}
+
+// embeddedPathAt locates offset off of a string value holding a document of
+// its own: a JSON, libconfig or YAML path, or the key (and INI section) of its
+// line.
+func embeddedPathAt(s string, off int) (format, p string) {
+	if t := strings.TrimSpace(s); strings.HasPrefix(t, "{") || strings.HasPrefix(t, "[") {
+		if p, ok := jsonPathAt(s, off); ok {
+			return formatJSON, p
+		}
+	}
+	if leaves, err := libconfigLeaves(s); err == nil && len(leaves) > 0 {
+		if p, ok := leafAt(leaves, off); ok {
+			return formatLibconfig, p
+		}
+	}
+	line := strings.Count(s[:off], "\n")
+	lines := strings.Split(s, "\n")
+	for _, l := range lines {
+		if iniSection.MatchString(l) {
+			return textKeyAt(lines, line) // [section] also parses as YAML
+		}
+	}
+	if leaves, err := yamlLeaves(s); err == nil {
+		p, _ := leafAt(leaves, off)
+		return formatYAML, p
+	}
+	return textKeyAt(lines, line)
+}
+
+var (
+	iniSection = regexp.MustCompile(`^\s*\[([^\]]+)\]\s*$`)
+	lineKey    = regexp.MustCompile(`^\s*(?:export\s+|-\s+)?["']?([A-Za-z_][A-Za-z0-9_.\-]*)["']?\s*([=:])`)
+)
+
+// textKeyAt returns the key line l of a config file assigns, prefixed with its
+// INI section: format ini for key = value, yaml for key: value (a template
+// that is not valid YAML), text when the line assigns nothing.
+func textKeyAt(lines []string, l int) (format, p string) {
+	m := lineKey.FindStringSubmatch(lines[l])
+	if m == nil {
+		return "text", ""
+	}
+	format = "ini"
+	if m[2] == ":" {
+		format = "yaml"
+	}
+	for i := l - 1; i >= 0; i-- {
+		if s := iniSection.FindStringSubmatch(lines[i]); s != nil {
+			return "ini", s[1] + "." + m[1]
+		}
+	}
+	return format, m[1]
+}
+
+// jsonPathAt returns the path of the JSON value at byte offset off of s.
+func jsonPathAt(s string, off int) (string, bool) {
+	leaves, err := jsonLeaves(s)
+	if err != nil {
+		return "", false
+	}
+	for _, l := range leaves {
+		if l.start <= off && off < l.end {
+			return l.pathString(), true
+		}
+	}
+	return "", false
+}
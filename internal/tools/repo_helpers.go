@@ -1,13 +1,16 @@
 package tools
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
-	"regexp"
 	"strings"
 
+	yamlv3 "gopkg.in/yaml.v3"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"sigs.k8s.io/yaml"
 )
@@ -25,7 +28,22 @@ func readYAMLFile(absPath string) (*unstructured.Unstructured, []byte, error) {
 	}
 	var m map[string]any
 	if err := yaml.Unmarshal(b, &m); err != nil {
-		return nil, b, err
+		return nil, b, &ToolError{
+			Code:    CodeInvalidArgument,
+			Message: "parse yaml: " + err.Error(),
+			Target:  absPath,
+			Hint:    "file must hold a single YAML document; use repo_scan_manifests to locate the right file",
+		}
+	}
+	if docs, err := decodeYAMLDocuments(b); err == nil && len(docs) > 1 {
+		// yaml.Unmarshal reads the first document only; writing it back
+		// would drop the others
+		return nil, b, &ToolError{
+			Code:    CodeInvalidArgument,
+			Message: fmt.Sprintf("file holds %d YAML documents", len(docs)),
+			Target:  absPath,
+			Hint:    "split the file, or use manifest_patch_config_refs or manifest_patch_embedded_config, which patch every document",
+		}
 	}
 	u := &unstructured.Unstructured{Object: m}
 	if u.GetKind() == "" || u.GetAPIVersion() == "" {
@@ -43,13 +61,89 @@ func writeYAMLFile(absPath string, obj map[string]any) error {
 	if len(out) == 0 || out[len(out)-1] != '\n' {
 		out = append(out, '\n')
 	}
+	return writeFileAtomic(absPath, out)
+}
+
+// writeFileAtomic replaces absPath with b through a temp file and rename.
+func writeFileAtomic(absPath string, b []byte) error {
 	tmp := absPath + ".tmp"
-	if err := os.WriteFile(tmp, out, 0o644); err != nil {
+	cleanup := trackTempFile(tmp)
+	defer cleanup()
+	if err := os.WriteFile(tmp, b, 0o644); err != nil {
 		return err
 	}
 	return os.Rename(tmp, absPath)
 }
 
+// decodeYAMLDocuments returns the non-empty documents of a YAML stream as
+// JSON, decoded once so that keys such as y or on stay strings.
+func decodeYAMLDocuments(b []byte) ([][]byte, error) {
+	var docs [][]byte
+	dec := yamlv3.NewDecoder(bytes.NewReader(b))
+	for i := 0; ; i++ {
+		var v any
+		if err := dec.Decode(&v); errors.Is(err, io.EOF) {
+			return docs, nil
+		} else if err != nil {
+			return nil, err
+		}
+		if v == nil {
+			continue
+		}
+		out, err := json.Marshal(v)
+		if err != nil {
+			return nil, fmt.Errorf("document %d: %w", i, err)
+		}
+		docs = append(docs, out)
+	}
+}
+
+// readYAMLDocuments reads every document of a YAML file as an object.
+func readYAMLDocuments(absPath string) ([]map[string]any, error) {
+	b, err := os.ReadFile(absPath)
+	if err != nil {
+		return nil, err
+	}
+	docs, err := parseYAMLDocuments(b)
+	if err != nil {
+		return nil, &ToolError{Code: CodeInvalidArgument, Message: "parse yaml: " + err.Error(), Target: absPath}
+	}
+	return docs, nil
+}
+
+// parseYAMLDocuments parses every document of a YAML stream as an object.
+func parseYAMLDocuments(b []byte) ([]map[string]any, error) {
+	parts, err := decodeYAMLDocuments(b)
+	if err != nil {
+		return nil, err
+	}
+	docs := make([]map[string]any, 0, len(parts))
+	for i, part := range parts {
+		var m map[string]any
+		if err := json.Unmarshal(part, &m); err != nil {
+			return nil, fmt.Errorf("document %d: %w", i, err)
+		}
+		docs = append(docs, m)
+	}
+	return docs, nil
+}
+
+// writeYAMLDocuments writes docs as a multi-document YAML file.
+func writeYAMLDocuments(absPath string, docs []map[string]any) error {
+	var out []byte
+	for i, d := range docs {
+		b, err := yaml.Marshal(d)
+		if err != nil {
+			return err
+		}
+		if i > 0 {
+			out = append(out, "---\n"...)
+		}
+		out = append(out, b...)
+	}
+	return writeFileAtomic(absPath, out)
+}
+
 func absJoin(workdir, rel string) string {
 	return filepath.Join(cleanPath(workdir), filepath.FromSlash(strings.TrimSpace(rel)))
 }
@@ -73,10 +167,9 @@ func walkAny(v any, fn func(path []string, key string, parent map[string]any, va
 	rec(nil, v)
 }
 
-var cidrRe = regexp.MustCompile(`\b(\d{1,3}\.){3}\d{1,3}/\d{1,2}\b`)
-var ipv4Re = regexp.MustCompile(`\b(\d{1,3}\.){3}\d{1,3}\b`)
-
-func extractAllCIDRsAndIPv4Strings(obj map[string]any) (cidrs []string, ips []string) {
+// extractAllCIDRsAndIPs returns every IPv4 and IPv6 CIDR and IP found in the
+// string values of obj.
+func extractAllCIDRsAndIPs(obj map[string]any) (cidrs []string, ips []string) {
 	seenC := map[string]struct{}{}
 	seenI := map[string]struct{}{}
 	walkAny(obj, func(_ []string, _ string, _ map[string]any, val any) {
@@ -84,13 +177,14 @@ func extractAllCIDRsAndIPv4Strings(obj map[string]any) (cidrs []string, ips []st
 		if !ok {
 			return
 		}
-		for _, m := range cidrRe.FindAllString(s, -1) {
+		c, i := findAddrs(s)
+		for _, m := range c {
 			if _, ok := seenC[m]; !ok {
 				seenC[m] = struct{}{}
 				cidrs = append(cidrs, m)
 			}
 		}
-		for _, m := range ipv4Re.FindAllString(s, -1) {
+		for _, m := range i {
 			if _, ok := seenI[m]; !ok {
 				seenI[m] = struct{}{}
 				ips = append(ips, m)
@@ -6,6 +6,7 @@ import (
 	"sort"
 	"strings"
 
+	"nfreconfig-mcp-server/internal/config"
 	"nfreconfig-mcp-server/internal/kube"
 
 	"github.com/modelcontextprotocol/go-sdk/mcp"
@@ -18,8 +19,9 @@ func init() { registerTool(ReposGetReposURLs()) }
 
 // MCP tool name required by you: "[repos]@get_repos_urls"
 type ReposGetReposURLsParams struct {
-	Prefix    string `json:"prefix,omitempty"`    // default "5g-"
-	OnlyReady *bool  `json:"onlyReady,omitempty"` // default true (nil => true)
+	Prefix    string `json:"prefix,omitempty" description:"Repository name prefix to match; defaults to repos.prefix from the server config (5g-)."`
+	OnlyReady *bool  `json:"onlyReady,omitempty" description:"Only return repositories whose Ready condition is True." default:"true"`
+	Context   string `json:"context,omitempty" description:"Management cluster kube context; defaults to the -mgmt-context flag, then kube.managementContext from the server config, then the current context."`
 }
 
 type RepoURL struct {
@@ -41,7 +43,7 @@ func ReposGetReposURLs() MCPTool[ReposGetReposURLsParams, ReposGetReposURLsResul
 		Handler: func(ctx context.Context, cc *mcp.ServerSession, params *mcp.CallToolParamsFor[ReposGetReposURLsParams]) (*mcp.CallToolResultFor[ReposGetReposURLsResult], error) {
 			prefix := strings.TrimSpace(params.Arguments.Prefix)
 			if prefix == "" {
-				prefix = "5g-"
+				prefix = config.Current().Repos.Prefix
 			}
 
 			onlyReady := true
@@ -50,20 +52,17 @@ func ReposGetReposURLs() MCPTool[ReposGetReposURLsParams, ReposGetReposURLsResul
 			}
 
 			// mgmt kube context
-			_, raw, err := kube.LoadRawConfig()
+			mgmtCtx, err := defaultMgmtContext(params.Arguments.Context)
 			if err != nil {
 				return toolErr[ReposGetReposURLsResult](err)
 			}
 
 			// clients against mgmt cluster
-			dyn, err := kube.BuildDynamicClient(raw.CurrentContext)
+			mgmt, err := kube.DefaultPool().Management(ctx, mgmtCtx)
 			if err != nil {
-				return toolErr[ReposGetReposURLsResult](fmt.Errorf("build dynamic client (context=%s): %w", raw.CurrentContext, err))
-			}
-			cs, err := kube.BuildClientset(raw.CurrentContext)
-			if err != nil {
-				return toolErr[ReposGetReposURLsResult](fmt.Errorf("build clientset (context=%s): %w", raw.CurrentContext, err))
+				return toolErr[ReposGetReposURLsResult](fmt.Errorf("build clients (context=%s): %w", mgmtCtx, err))
 			}
+			dyn, cs := mgmt.Dynamic, mgmt.Typed
 
 			// discover Repository GVR
 			gvr, namespaced, err := discoverRepositoryGVR(cs.Discovery())
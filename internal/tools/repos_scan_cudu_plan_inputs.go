@@ -9,6 +9,8 @@ import (
 	"sort"
 	"strings"
 
+	"nfreconfig-mcp-server/internal/config"
+
 	"github.com/modelcontextprotocol/go-sdk/mcp"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"sigs.k8s.io/yaml"
@@ -17,25 +19,33 @@ import (
 func init() { registerTool(RepoScanManifestsMany()) }
 
 type RepoWorkdir struct {
-	Name    string `json:"name"`
-	Workdir string `json:"workdir"`
+	Name    string `json:"name" description:"Repo name, as returned by git_clone_repos."`
+	Workdir string `json:"workdir" description:"Cloned directory, as returned by git_clone_repos."`
 }
 
 type RepoScanManifestsManyParams struct {
-	Repos []RepoWorkdir `json:"repos"`           // required
-	Kinds []string      `json:"kinds,omitempty"` // default ["NFDeployment","NetworkAttachmentDefinition","NFConfig","Config"]
+	Repos []RepoWorkdir `json:"repos" description:"Cloned repositories to scan."`
+	Kinds []string      `json:"kinds,omitempty" description:"Object kinds to collect, any kind the repos hold (ConfigMap, Deployment, ...)." default:"[\"NFDeployment\",\"NetworkAttachmentDefinition\",\"NFConfig\",\"Config\"]"`
 
-	MaxFiles int `json:"maxFiles,omitempty"` // default 5000
+	MaxFiles int `json:"maxFiles,omitempty" description:"Maximum YAML files to read per repo; defaults to scan.maxFiles from the server config (5000)."`
 
 	// NEW:
-	IncludeTopology bool `json:"includeTopology,omitempty"` // default true (recommended)
-	IncludeRaw      bool `json:"includeRaw,omitempty"`      // default false (debug/heavy)
+	IncludeTopology *bool `json:"includeTopology,omitempty" description:"Extract interfaces, CIDRs and IPs per object." default:"true"`
+	IncludeRaw      bool  `json:"includeRaw,omitempty" description:"Include the raw object (debug; large output)."`
 }
 
 type NetworkInterface struct {
 	Name  string   `json:"name"`            // interface name (e.g., "n2", "n3", "eth0")
 	CIDRs []string `json:"cidrs,omitempty"` // CIDRs associated with this interface
 	IPs   []string `json:"ips,omitempty"`   // IPs associated with this interface
+
+	// Addresses lists CIDRs and IPs again with their family (ipv4/ipv6), for
+	// dual-stack interfaces
+	Addresses []AddrEntry `json:"addresses,omitempty"`
+
+	// NAD is the network as its NetworkAttachmentDefinition configures it
+	// (master, VLAN, IPAM ranges, gateways, routes); NADs only
+	NAD *NADNetwork `json:"nad,omitempty"`
 }
 
 type FoundObject struct {
@@ -65,7 +75,7 @@ type RepoScanResult struct {
 	Repo    string        `json:"repo"`
 	Workdir string        `json:"workdir"`
 	Found   []FoundObject `json:"found"`
-	Errors  []string      `json:"errors,omitempty"`
+	Errors  []*ToolError  `json:"errors,omitempty"`
 }
 
 type RepoScanManifestsManyResult struct {
@@ -88,7 +98,7 @@ func RepoScanManifestsMany() MCPTool[RepoScanManifestsManyParams, RepoScanManife
 				repos = append(repos, r)
 			}
 			if len(repos) == 0 {
-				return toolErr[RepoScanManifestsManyResult](fmt.Errorf("missing required field: repos (non-empty array of {name,workdir})"))
+				return toolErr[RepoScanManifestsManyResult](invalidArgument("repos", "missing required field: repos (non-empty array of {name,workdir})"))
 			}
 
 			wantKinds := toSet(params.Arguments.Kinds)
@@ -98,13 +108,13 @@ func RepoScanManifestsMany() MCPTool[RepoScanManifestsManyParams, RepoScanManife
 
 			maxFiles := params.Arguments.MaxFiles
 			if maxFiles <= 0 {
-				maxFiles = 5000
+				maxFiles = config.Current().Scan.MaxFiles
 			}
 
 			// Defaults: includeTopology=true unless explicitly false.
 			includeTopology := true
-			if params.Arguments.IncludeTopology == false {
-				includeTopology = false
+			if params.Arguments.IncludeTopology != nil {
+				includeTopology = *params.Arguments.IncludeTopology
 			}
 			includeRaw := params.Arguments.IncludeRaw
 
@@ -117,13 +127,13 @@ func RepoScanManifestsMany() MCPTool[RepoScanManifestsManyParams, RepoScanManife
 					Repo:    r.Name,
 					Workdir: r.Workdir,
 					Found:   []FoundObject{},
-					Errors:  []string{},
+					Errors:  []*ToolError{},
 				}
 
 				count := 0
 				walkErr := filepath.WalkDir(r.Workdir, func(path string, d fs.DirEntry, err error) error {
 					if err != nil {
-						res.Errors = append(res.Errors, fmt.Sprintf("walk error: %s: %v", path, err))
+						res.Errors = append(res.Errors, toolError(fmt.Errorf("walk error: %w", err), path))
 						return nil
 					}
 					if d.IsDir() {
@@ -148,7 +158,7 @@ func RepoScanManifestsMany() MCPTool[RepoScanManifestsManyParams, RepoScanManife
 
 					b, readErr := os.ReadFile(path)
 					if readErr != nil {
-						res.Errors = append(res.Errors, fmt.Sprintf("read error: %s: %v", relSlash, readErr))
+						res.Errors = append(res.Errors, toolError(fmt.Errorf("read error: %w", readErr), relSlash))
 						return nil
 					}
 
@@ -187,22 +197,29 @@ func RepoScanManifestsMany() MCPTool[RepoScanManifestsManyParams, RepoScanManife
 							fo.NetworkInterfaces = extractNetworkInterfaces(obj.Object)
 
 							// Legacy flat lists for backward compatibility
-							cidrs, ips := extractAllCIDRsAndIPv4Strings(obj.Object)
+							cidrs, ips := extractAllCIDRsAndIPs(obj.Object)
 							sort.Strings(cidrs)
 							sort.Strings(ips)
 							fo.CIDRs = cidrs
 							fo.IPs = ips
 
-							// NAD spec.config JSON string extraction
+							// NAD spec.config: typed CNI config, else what looks like addresses
 							if kind == "NetworkAttachmentDefinition" {
-								spec, _, _ := unstructured.NestedMap(obj.Object, "spec")
-								if cfg, ok := spec["config"].(string); ok && strings.TrimSpace(cfg) != "" {
-									if jm, ok := tryParseJSONConfigString(cfg); ok {
-										c2, i2 := extractAllCIDRsAndIPv4Strings(jm)
-										sort.Strings(c2)
-										sort.Strings(i2)
-										fo.NADConfigCIDRs = c2
-										fo.NADConfigIPs = i2
+								if iface, ok := nadNetworkInterface(obj.Object); ok {
+									fo.NetworkInterfaces = append(fo.NetworkInterfaces, iface)
+									fo.NADConfigCIDRs, fo.NADConfigIPs = nadCIDRsAndIPs(iface)
+									// route destinations are not addresses of the network
+									fo.CIDRs, fo.IPs = fo.NADConfigCIDRs, fo.NADConfigIPs
+								} else {
+									spec, _, _ := unstructured.NestedMap(obj.Object, "spec")
+									if cfg, ok := spec["config"].(string); ok && strings.TrimSpace(cfg) != "" {
+										if jm, ok := tryParseJSONConfigString(cfg); ok {
+											c2, i2 := extractAllCIDRsAndIPs(jm)
+											sort.Strings(c2)
+											sort.Strings(i2)
+											fo.NADConfigCIDRs = c2
+											fo.NADConfigIPs = i2
+										}
 									}
 								}
 							}
@@ -219,7 +236,7 @@ func RepoScanManifestsMany() MCPTool[RepoScanManifestsManyParams, RepoScanManife
 				})
 
 				if walkErr != nil {
-					res.Errors = append(res.Errors, fmt.Sprintf("walk failed: %v", walkErr))
+					res.Errors = append(res.Errors, toolError(fmt.Errorf("walk failed: %w", walkErr), r.Workdir))
 				}
 
 				out.Results = append(out.Results, res)
@@ -325,9 +342,20 @@ func extractNetworkInterfaces(obj map[string]any) []NetworkInterface {
 				seenIP[ifName] = make(map[string]bool)
 			}
 
-			// Extract IPs and CIDRs from parent
-			for k, v := range parent {
-				if s, ok := v.(string); ok {
+			// Extract IPs and CIDRs from parent, and from its per-family
+			// ipv4/ipv6 blocks (NFDeployment interfaces)
+			fields := []map[string]any{parent}
+			for _, fam := range []string{familyIPv4, familyIPv6} {
+				if m, ok := parent[fam].(map[string]any); ok {
+					fields = append(fields, m)
+				}
+			}
+			for _, m := range fields {
+				for k, v := range m {
+					s, ok := v.(string)
+					if !ok {
+						continue
+					}
 					kLower := strings.ToLower(k)
 					// Look for IP/CIDR related fields
 					if strings.Contains(kLower, "ip") ||
@@ -335,15 +363,16 @@ func extractNetworkInterfaces(obj map[string]any) []NetworkInterface {
 						strings.Contains(kLower, "gateway") ||
 						strings.Contains(kLower, "cidr") ||
 						strings.Contains(kLower, "address") {
+						cidrs, ips := findAddrs(s)
 						// Extract CIDRs
-						for _, cidr := range cidrRe.FindAllString(s, -1) {
+						for _, cidr := range cidrs {
 							if !seenCIDR[ifName][cidr] {
 								interfaceMap[ifName].CIDRs = append(interfaceMap[ifName].CIDRs, cidr)
 								seenCIDR[ifName][cidr] = true
 							}
 						}
 						// Extract IPs
-						for _, ip := range ipv4Re.FindAllString(s, -1) {
+						for _, ip := range ips {
 							if !seenIP[ifName][ip] {
 								interfaceMap[ifName].IPs = append(interfaceMap[ifName].IPs, ip)
 								seenIP[ifName][ip] = true
@@ -367,13 +396,14 @@ func extractNetworkInterfaces(obj map[string]any) []NetworkInterface {
 
 				// Extract IPs/CIDRs from string values
 				if s, ok := val.(string); ok {
-					for _, cidr := range cidrRe.FindAllString(s, -1) {
+					cidrs, ips := findAddrs(s)
+					for _, cidr := range cidrs {
 						if !seenCIDR[p][cidr] {
 							interfaceMap[p].CIDRs = append(interfaceMap[p].CIDRs, cidr)
 							seenCIDR[p][cidr] = true
 						}
 					}
-					for _, ip := range ipv4Re.FindAllString(s, -1) {
+					for _, ip := range ips {
 						if !seenIP[p][ip] {
 							interfaceMap[p].IPs = append(interfaceMap[p].IPs, ip)
 							seenIP[p][ip] = true
@@ -388,8 +418,7 @@ func extractNetworkInterfaces(obj map[string]any) []NetworkInterface {
 	result := make([]NetworkInterface, 0, len(interfaceMap))
 	for _, iface := range interfaceMap {
 		if len(iface.CIDRs) > 0 || len(iface.IPs) > 0 {
-			sort.Strings(iface.CIDRs)
-			sort.Strings(iface.IPs)
+			finishInterface(iface)
 			result = append(result, *iface)
 		}
 	}
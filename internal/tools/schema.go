@@ -0,0 +1,185 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/modelcontextprotocol/go-sdk/jsonschema"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// schemaFor infers the JSON Schema of T and enriches it from struct tags:
+//
+//	description:"..."  property description
+//	enum:"a,b,c"       allowed values (applied to the items of slice fields)
+//	default:"..."      default value; a JSON literal, or plain text for string fields
+//	example:"..."      example value, same encoding as default
+//
+// Required properties follow encoding/json: fields without omitempty are required.
+func schemaFor[T any]() (*jsonschema.Schema, error) {
+	s, err := jsonschema.For[T]()
+	if err != nil {
+		return nil, err
+	}
+	if err := annotateSchema(reflect.TypeFor[T](), s); err != nil {
+		var z T
+		return nil, fmt.Errorf("schema for %T: %w", z, err)
+	}
+	return s, nil
+}
+
+func annotateSchema(t reflect.Type, s *jsonschema.Schema) error {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if s == nil {
+		return nil
+	}
+	switch t.Kind() {
+	case reflect.Slice, reflect.Array:
+		return annotateSchema(t.Elem(), s.Items)
+	case reflect.Map:
+		return annotateSchema(t.Elem(), s.AdditionalProperties)
+	case reflect.Struct:
+	default:
+		return nil
+	}
+
+	for i := range t.NumField() {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		ps := s.Properties[name]
+		if ps == nil {
+			continue
+		}
+
+		if d := f.Tag.Get("description"); d != "" {
+			ps.Description = d
+		}
+		if e := f.Tag.Get("enum"); e != "" {
+			target := ps
+			if isListType(f.Type) && ps.Items != nil {
+				target = ps.Items
+			}
+			for _, v := range strings.Split(e, ",") {
+				if v = strings.TrimSpace(v); v != "" {
+					target.Enum = append(target.Enum, v)
+				}
+			}
+		}
+		if d, ok := f.Tag.Lookup("default"); ok {
+			raw, err := tagValue(f.Type, d)
+			if err != nil {
+				return fmt.Errorf("field %s: default: %w", f.Name, err)
+			}
+			ps.Default = raw
+		}
+		if ex, ok := f.Tag.Lookup("example"); ok {
+			raw, err := tagValue(f.Type, ex)
+			if err != nil {
+				return fmt.Errorf("field %s: example: %w", f.Name, err)
+			}
+			var v any
+			_ = json.Unmarshal(raw, &v)
+			ps.Examples = append(ps.Examples, v)
+		}
+
+		if err := annotateSchema(f.Type, ps); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func isListType(t reflect.Type) bool {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t.Kind() == reflect.Slice || t.Kind() == reflect.Array
+}
+
+// tagValue encodes a default/example tag as JSON. String fields take the tag text
+// verbatim; everything else must be a JSON literal.
+func tagValue(t reflect.Type, v string) (json.RawMessage, error) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() == reflect.String {
+		return json.Marshal(v)
+	}
+	if !json.Valid([]byte(v)) {
+		return nil, fmt.Errorf("%q is not valid JSON", v)
+	}
+	return json.RawMessage(v), nil
+}
+
+// inputSchemas holds the resolved input schema of every registered tool, used by
+// validateToolArgs.
+var inputSchemas sync.Map // tool name -> *jsonschema.Resolved
+
+// validateToolArgs is receiving middleware that checks tools/call arguments
+// against the tool's input schema before the SDK decodes them, so a bad call
+// comes back as an INVALID_ARGUMENT tool error the agent can act on.
+func validateToolArgs(next mcp.MethodHandler[*mcp.ServerSession]) mcp.MethodHandler[*mcp.ServerSession] {
+	return func(ctx context.Context, ss *mcp.ServerSession, method string, params mcp.Params) (mcp.Result, error) {
+		p, ok := params.(*mcp.CallToolParamsFor[json.RawMessage])
+		if method != "tools/call" || !ok {
+			return next(ctx, ss, method, params)
+		}
+		v, ok := inputSchemas.Load(p.Name)
+		if !ok {
+			return next(ctx, ss, method, params)
+		}
+		resolved := v.(*jsonschema.Resolved)
+
+		args := map[string]any{}
+		if len(p.Arguments) > 0 && string(p.Arguments) != "null" {
+			if err := json.Unmarshal(p.Arguments, &args); err != nil {
+				return invalidArgsResult(p.Name, fmt.Errorf("arguments must be a JSON object: %w", err)), nil
+			}
+		}
+		// The validator only says "additionalProperties: not"; name the offending fields.
+		if unknown := unknownFields(resolved.Schema(), args); len(unknown) > 0 {
+			return invalidArgsResult(p.Name, fmt.Errorf("unknown field(s) %s", strings.Join(unknown, ", "))), nil
+		}
+		if err := resolved.Validate(args); err != nil {
+			return invalidArgsResult(p.Name, err), nil
+		}
+		return next(ctx, ss, method, params)
+	}
+}
+
+func unknownFields(s *jsonschema.Schema, args map[string]any) []string {
+	var out []string
+	for k := range args {
+		if _, ok := s.Properties[k]; !ok {
+			out = append(out, k)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+func invalidArgsResult(tool string, err error) *mcp.CallToolResult {
+	te := &ToolError{
+		Code:    CodeInvalidArgument,
+		Message: "arguments do not match the input schema: " + err.Error(),
+		Target:  tool,
+		Hint:    "list the tool to see its input schema (required fields, enums, types)",
+	}
+	return toolErrorResult(te)
+}
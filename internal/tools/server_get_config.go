@@ -0,0 +1,38 @@
+package tools
+
+import (
+	"context"
+	"time"
+
+	"nfreconfig-mcp-server/internal/config"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+func init() { registerTool(ServerGetConfig()) }
+
+type ServerGetConfigParams struct{}
+
+type ServerGetConfigResult struct {
+	Source   string         `json:"source"`             // config file path, or "defaults"
+	LoadedAt string         `json:"loadedAt,omitempty"` // last successful (re)load
+	Config   *config.Config `json:"config"`
+}
+
+func ServerGetConfig() MCPTool[ServerGetConfigParams, ServerGetConfigResult] {
+	return MCPTool[ServerGetConfigParams, ServerGetConfigResult]{
+		Name:        "server_get_config",
+		Description: "Return the effective server configuration (tool defaults, management context, allowed repos, credential references) with secrets redacted. Use to learn which defaults apply when optional parameters are omitted.",
+		Handler: func(ctx context.Context, cc *mcp.ServerSession, params *mcp.CallToolParamsFor[ServerGetConfigParams]) (*mcp.CallToolResultFor[ServerGetConfigResult], error) {
+			out := ServerGetConfigResult{
+				Source: "defaults",
+				Config: config.Current().Redacted(),
+			}
+			if path, at := config.Source(); path != "" {
+				out.Source = path
+				out.LoadedAt = at.UTC().Format(time.RFC3339)
+			}
+			return toolOK(out), nil
+		},
+	}
+}
@@ -0,0 +1,115 @@
+package tools
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"nfreconfig-mcp-server/internal/config"
+	"nfreconfig-mcp-server/internal/identity"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+func init() { registerTool(SessionSetIdentity()) }
+
+// Per-session identities set with session_set_identity, used when the caller
+// has no identity from the authenticating proxy (e.g. stdio).
+var (
+	sessionIdentityMu sync.Mutex
+	sessionIdentities = map[*mcp.ServerSession]identity.Identity{}
+)
+
+// withCallerIdentity puts the session identity into ctx when the request did not
+// bring one. Kubernetes clients built from ctx then impersonate it.
+func withCallerIdentity[I, O any](h func(context.Context, *mcp.ServerSession, *mcp.CallToolParamsFor[I]) (*mcp.CallToolResultFor[O], error)) func(context.Context, *mcp.ServerSession, *mcp.CallToolParamsFor[I]) (*mcp.CallToolResultFor[O], error) {
+	return func(ctx context.Context, cc *mcp.ServerSession, params *mcp.CallToolParamsFor[I]) (*mcp.CallToolResultFor[O], error) {
+		if _, ok := identity.From(ctx); !ok && cc != nil {
+			sessionIdentityMu.Lock()
+			id, ok := sessionIdentities[cc]
+			sessionIdentityMu.Unlock()
+			if ok {
+				ctx = identity.With(ctx, id)
+			}
+		}
+		return h(ctx, cc, params)
+	}
+}
+
+type SessionSetIdentityParams struct {
+	User   string   `json:"user,omitempty" description:"Kubernetes user to impersonate for the rest of this session; empty clears the session identity." example:"alice@example.com"`
+	Groups []string `json:"groups,omitempty" description:"Kubernetes groups to impersonate together with user." example:"[\"nf-operators\"]"`
+}
+
+type SessionSetIdentityResult struct {
+	// Identity is what Kubernetes calls in this session now impersonate; empty
+	// means they run as the server.
+	Identity      *identity.Identity `json:"identity,omitempty"`
+	Impersonation bool               `json:"impersonation"` // kube.impersonation.enabled
+}
+
+func SessionSetIdentity() MCPTool[SessionSetIdentityParams, SessionSetIdentityResult] {
+	return MCPTool[SessionSetIdentityParams, SessionSetIdentityResult]{
+		Name:        "session_set_identity",
+		Description: "Set the Kubernetes identity (user and groups) that this session's Kubernetes calls impersonate, so cluster RBAC decides what the agent may see or delete. Only allowed on the stdio transport when the server enables kube.impersonation.allowSessionIdentity; system: users outside kube.impersonation.allowedSystemUsers, system: groups and groups outside kube.impersonation.allowedGroups are refused. Example: {\"user\":\"inventory-agent\",\"groups\":[\"nf-readers\"]}; {} clears it.",
+		Handler: func(ctx context.Context, cc *mcp.ServerSession, params *mcp.CallToolParamsFor[SessionSetIdentityParams]) (*mcp.CallToolResultFor[SessionSetIdentityResult], error) {
+			imp := config.Current().Kube.Impersonation
+			if !imp.Enabled || !imp.AllowSessionIdentity {
+				return toolErr[SessionSetIdentityResult](&ToolError{
+					Code:    CodeForbidden,
+					Message: "session identities are disabled on this server",
+					Hint:    "set kube.impersonation.enabled and allowSessionIdentity in the server config",
+				})
+			}
+			if identity.OverHTTP(ctx) {
+				// anyone who reaches the port could pick any identity; over
+				// HTTP the identity comes from the authenticating proxy
+				return toolErr[SessionSetIdentityResult](&ToolError{
+					Code:    CodeForbidden,
+					Message: "session identities are only allowed on the stdio transport",
+					Hint:    "over HTTP, the identity comes from the authenticating proxy's headers",
+				})
+			}
+
+			user := strings.TrimSpace(params.Arguments.User)
+			var groups []string
+			for _, g := range params.Arguments.Groups {
+				if g = strings.TrimSpace(g); g != "" {
+					groups = append(groups, g)
+				}
+			}
+			if user == "" && len(groups) > 0 {
+				return toolErr[SessionSetIdentityResult](invalidArgument("user", "groups require a user"))
+			}
+			if err := identity.CheckUser(user, imp.AllowedSystemUsers); err != nil {
+				return toolErr[SessionSetIdentityResult](toolError(err, "user"))
+			}
+			if err := identity.CheckGroups(groups, imp.AllowedGroups); err != nil {
+				return toolErr[SessionSetIdentityResult](toolError(err, "groups"))
+			}
+
+			sessionIdentityMu.Lock()
+			_, known := sessionIdentities[cc]
+			if user == "" {
+				delete(sessionIdentities, cc)
+			} else {
+				sessionIdentities[cc] = identity.Identity{User: user, Groups: groups, Source: "session"}
+			}
+			sessionIdentityMu.Unlock()
+			if user != "" && !known && cc != nil {
+				go func() {
+					_ = cc.Wait()
+					sessionIdentityMu.Lock()
+					delete(sessionIdentities, cc)
+					sessionIdentityMu.Unlock()
+				}()
+			}
+
+			out := SessionSetIdentityResult{Impersonation: true}
+			if user != "" {
+				out.Identity = &identity.Identity{User: user, Groups: groups, Source: "session"}
+			}
+			return toolOK(out), nil
+		},
+	}
+}
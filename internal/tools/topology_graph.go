@@ -0,0 +1,767 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+func init() { registerTool(TopologyGraph()) }
+
+// NF types of graph nodes. CU and gNB stand for NFs that were not split
+// further; DN is the data network behind a UPF's N6.
+const (
+	nfAMF  = "AMF"
+	nfSMF  = "SMF"
+	nfUPF  = "UPF"
+	nfCUCP = "CU-CP"
+	nfCUUP = "CU-UP"
+	nfCU   = "CU"
+	nfDU   = "DU"
+	nfGNB  = "gNB"
+	nfDN   = "DN"
+)
+
+// interfaceKinds maps interface names, lowercased without separators, to the
+// 3GPP interface they carry.
+var interfaceKinds = map[string]string{
+	"n2": "N2", "n3": "N3", "n4": "N4", "n6": "N6",
+	"f1": "F1", "f1c": "F1-C", "f1u": "F1-U", "e1": "E1",
+}
+
+// interfaceEnds are the NF types at either end of each 3GPP interface.
+var interfaceEnds = map[string][2]string{
+	"N2":   {nfAMF, nfCUCP},
+	"N3":   {nfUPF, nfCUUP},
+	"N4":   {nfSMF, nfUPF},
+	"N6":   {nfUPF, nfDN},
+	"F1":   {nfCU, nfDU},
+	"F1-C": {nfCUCP, nfDU},
+	"F1-U": {nfCUUP, nfDU},
+	"E1":   {nfCUCP, nfCUUP},
+}
+
+// interfaceOrder sorts edges the way the RAN and core are usually drawn.
+var interfaceOrder = map[string]int{"N2": 1, "N3": 2, "N4": 3, "N6": 4, "F1": 5, "F1-C": 6, "F1-U": 7, "E1": 8}
+
+// Edge sources in GraphEdge.Sources.
+const (
+	edgeFromReference = "reference" // a Config of one NF mentions an address of the other
+	edgeFromSubnet    = "subnet"    // both NFs have the interface on the same subnet
+)
+
+type TopologyGraphParams struct {
+	Repos           []RepoWorkdir `json:"repos,omitempty" description:"Cloned repositories to read NFDeployments, NADs, Configs, NFConfigs and ConfigMaps from."`
+	Clusters        []string      `json:"clusters,omitempty" description:"Clusters to read, as kube contexts or workload clusters (name or namespace/name); every kubeconfig context and CAPI cluster when empty."`
+	IncludeClusters *bool         `json:"includeClusters,omitempty" description:"Read clusters at all; set false to build the graph from repos only (default true)."`
+	NF              string        `json:"nf,omitempty" description:"NF to list the peers of, by NFDeployment name or node id: the NFs to re-associate if it moves." example:"cucp"`
+
+	Namespace      string `json:"namespace,omitempty" description:"Only read cluster objects and pods in this namespace; all namespaces when empty."`
+	Context        string `json:"context,omitempty" description:"Management cluster kube context; defaults to the -mgmt-context flag, then kube.managementContext from the server config, then the current context."`
+	Concurrency    int    `json:"concurrency,omitempty" description:"How many clusters to read at once; defaults to scan.clusterConcurrency from the server config (4)."`
+	ClusterTimeout string `json:"clusterTimeout,omitempty" description:"Deadline for one cluster (Go duration); defaults to scan.clusterTimeout from the server config (60s)." example:"30s"`
+}
+
+// GraphInterface is one interface of a node.
+type GraphInterface struct {
+	Name      string   `json:"name"`
+	Kind      string   `json:"kind,omitempty"` // 3GPP interface (N2, F1-C, ...), when recognized
+	Addresses []string `json:"addresses,omitempty"`
+	Subnets   []string `json:"subnets,omitempty"` // from the addresses, else from the NAD
+}
+
+// GraphNode is an NF, or a data network behind N6. NFDeployments of the same
+// name are one node, unless they are in several clusters.
+type GraphNode struct {
+	ID         string           `json:"id"`
+	Name       string           `json:"name"`
+	Type       string           `json:"type,omitempty"` // AMF, SMF, UPF, CU-CP, CU-UP, CU, DU, gNB or DN; empty when unknown
+	Provider   string           `json:"provider,omitempty"`
+	Cluster    string           `json:"cluster,omitempty"`
+	Repos      []string         `json:"repos,omitempty"`
+	Interfaces []GraphInterface `json:"interfaces,omitempty"`
+	Sources    []string         `json:"sources,omitempty"` // NFDeployments the node was built from
+}
+
+// GraphEdge is a 3GPP interface between two nodes.
+type GraphEdge struct {
+	From      string   `json:"from"` // node ids
+	To        string   `json:"to"`
+	Interface string   `json:"interface"` // N2, F1-C, ...; the interface name when it is not a 3GPP one
+	Subnets   []string `json:"subnets,omitempty"`
+	Sources   []string `json:"sources"` // reference and/or subnet
+	Evidence  []string `json:"evidence"`
+}
+
+// GraphPeer is a node connected to the nf of the request.
+type GraphPeer struct {
+	Node      string   `json:"node"`
+	Name      string   `json:"name"`
+	Type      string   `json:"type,omitempty"`
+	Cluster   string   `json:"cluster,omitempty"`
+	Interface string   `json:"interface"`
+	Evidence  []string `json:"evidence"`
+}
+
+type TopologyGraphResult struct {
+	Nodes []GraphNode `json:"nodes"`
+	Edges []GraphEdge `json:"edges"`
+	// Peers are the nodes connected to nf, when nf was given
+	Peers    []GraphPeer `json:"peers,omitempty"`
+	Mermaid  string      `json:"mermaid"`
+	DOT      string      `json:"dot"`
+	Clusters []string    `json:"clusters,omitempty"`
+	Errors   []ScanIssue `json:"errors,omitempty"`
+	Warnings []ScanIssue `json:"warnings,omitempty"`
+}
+
+func (r *TopologyGraphResult) addIssue(source string, err error) {
+	if issue, warning := newScanIssue(source, err); warning {
+		r.Warnings = append(r.Warnings, issue)
+	} else {
+		r.Errors = append(r.Errors, issue)
+	}
+}
+
+func TopologyGraph() MCPTool[TopologyGraphParams, TopologyGraphResult] {
+	return MCPTool[TopologyGraphParams, TopologyGraphResult]{
+		Name:        "topology_graph",
+		Description: "Build the NF dependency graph of the fleet: NFs (AMF, SMF, UPF, CU-CP, CU-UP, DU) as nodes and 3GPP interfaces (N2, N3, N4, N6, F1-C, F1-U, E1) as edges. Reads NFDeployments, NADs, Configs, NFConfigs and ConfigMaps from the repos and clusters; an edge comes from a Config of one NF referencing an address of another (reference) or from two NFs of matching types with the interface on the same subnet (subnet), each with its evidence. Returns JSON plus Mermaid and Graphviz DOT. With nf, peers lists the NFs to re-associate if it moves. Example: {\"repos\":[{\"name\":\"cucp\",\"workdir\":\"/work/cucp\"}],\"nf\":\"cucp\"}.",
+		Timeout:     5 * time.Minute,
+		Handler: func(ctx context.Context, cc *mcp.ServerSession, params *mcp.CallToolParamsFor[TopologyGraphParams]) (*mcp.CallToolResultFor[TopologyGraphResult], error) {
+			a := params.Arguments
+			includeClusters := a.IncludeClusters == nil || *a.IncludeClusters
+			if !includeClusters && len(a.Repos) == 0 {
+				return toolErr[TopologyGraphResult](missingField("repos"))
+			}
+			concurrency, clusterTimeout, err := fleetScanLimits(a.Concurrency, a.ClusterTimeout)
+			if err != nil {
+				return toolErr[TopologyGraphResult](err)
+			}
+
+			out := TopologyGraphResult{Nodes: []GraphNode{}, Edges: []GraphEdge{}}
+			inv := &addrInventory{}
+			if includeClusters {
+				clusters, err := fleetClusters(ctx, a.Context, a.Clusters, "", out.addIssue)
+				if err != nil {
+					return toolErr[TopologyGraphResult](err)
+				}
+				for _, fc := range clusters {
+					out.Clusters = append(out.Clusters, fc.name)
+				}
+				inv = scanFleet(ctx, a.Context, clusters, strings.TrimSpace(a.Namespace), concurrency, clusterTimeout, fleetReporter(&out.Errors, &out.Warnings))
+			}
+			for _, r := range a.Repos {
+				r.Name, r.Workdir = strings.TrimSpace(r.Name), cleanPath(r.Workdir)
+				if r.Workdir != "" {
+					inv.addRepo(r, out.addIssue)
+				}
+			}
+
+			g := buildGraph(inv)
+			out.Nodes, out.Edges = g.nodeList(), g.edgeList()
+			if nf := strings.TrimSpace(a.NF); nf != "" {
+				peers, ok := g.peers(nf)
+				if !ok {
+					names := make([]string, 0, len(g.nodes))
+					for _, n := range out.Nodes {
+						if n.Type != nfDN {
+							names = append(names, n.ID)
+						}
+					}
+					e := &ToolError{Code: CodeNotFound, Message: fmt.Sprintf("NF %q not found in the graph", nf), Target: nf}
+					if len(names) > 0 {
+						e.Hint = "known NFs: " + strings.Join(names, ", ")
+					}
+					return toolErr[TopologyGraphResult](e)
+				}
+				out.Peers = peers
+			}
+			out.Mermaid, out.DOT = graphMermaid(out.Nodes, out.Edges), graphDOT(out.Nodes, out.Edges)
+			return toolOK(out), nil
+		},
+	}
+}
+
+// nfType guesses the NF type from tokens of an NFDeployment's name and
+// provider (cucp, cu-cp, oai-du, amf.free5gc.io).
+func nfType(name, provider string) string {
+	for _, s := range []string{name, provider} {
+		tokens := strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+			return !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9')
+		})
+		for i, t := range tokens {
+			next := ""
+			if i+1 < len(tokens) {
+				next = tokens[i+1]
+			}
+			switch {
+			case t == "amf":
+				return nfAMF
+			case t == "smf":
+				return nfSMF
+			case t == "upf":
+				return nfUPF
+			case t == "cucp" || t == "cu" && next == "cp":
+				return nfCUCP
+			case t == "cuup" || t == "cu" && next == "up":
+				return nfCUUP
+			case t == "cu":
+				return nfCU
+			case t == "du":
+				return nfDU
+			case t == "gnb":
+				return nfGNB
+			}
+		}
+	}
+	return ""
+}
+
+// interfaceKind returns the 3GPP interface an interface or NAD name carries
+// (n2, f1-c, cucp-n2), or "".
+func interfaceKind(name string) string {
+	n := strings.ToLower(name)
+	if i := strings.LastIndex(n, "/"); i >= 0 {
+		n = n[i+1:]
+	}
+	parts := strings.FieldsFunc(n, func(r rune) bool { return r == '-' || r == '_' || r == '.' })
+	for i := range parts {
+		if k, ok := interfaceKinds[strings.Join(parts[i:], "")]; ok {
+			return k
+		}
+	}
+	return ""
+}
+
+// typeFits reports whether an NF of type t can be the end of an interface that
+// wants type want. An unknown type fits anything; CU and gNB fit the parts
+// they combine.
+func typeFits(t, want string) bool {
+	switch {
+	case t == "" || t == want:
+		return true
+	case t == nfCU:
+		return want == nfCUCP || want == nfCUUP
+	case t == nfGNB:
+		return want == nfCUCP || want == nfCUUP || want == nfCU || want == nfDU
+	case want == nfCU:
+		return t == nfCUCP || t == nfCUUP
+	}
+	return false
+}
+
+type graphBuilder struct {
+	inv   *addrInventory
+	split map[string]bool // NF names in several clusters, one node per cluster
+	nodes map[string]*GraphNode
+	edges map[string]*GraphEdge
+	order []string // edge keys in the order found
+}
+
+// nodeID is the node of NF name as seen in cluster or repo.
+func (g *graphBuilder) nodeID(name, cluster, repo string) string {
+	switch {
+	case !g.split[name]:
+		return name
+	case cluster != "":
+		return name + "@" + cluster
+	}
+	return name + "@" + repo
+}
+
+// nodeOf is the node owning u, or "" when u has no known NF.
+func (g *graphBuilder) nodeOf(u AddrUse) string {
+	if u.NF == "" {
+		return ""
+	}
+	id := g.nodeID(u.NF, u.Cluster, u.Repo)
+	if g.nodes[id] == nil {
+		return ""
+	}
+	return id
+}
+
+func buildGraph(inv *addrInventory) *graphBuilder {
+	g := &graphBuilder{inv: inv, split: map[string]bool{}, nodes: map[string]*GraphNode{}, edges: map[string]*GraphEdge{}}
+	clustersOf := map[string]map[string]bool{}
+	for _, nf := range inv.nfs {
+		if clustersOf[nf.loc.NF] == nil {
+			clustersOf[nf.loc.NF] = map[string]bool{}
+		}
+		if nf.loc.Cluster != "" {
+			clustersOf[nf.loc.NF][nf.loc.Cluster] = true
+		}
+	}
+	for name, cs := range clustersOf {
+		g.split[name] = len(cs) > 1
+	}
+
+	for _, nf := range inv.nfs {
+		id := g.nodeID(nf.loc.NF, nf.loc.Cluster, nf.loc.Repo)
+		n := g.nodes[id]
+		if n == nil {
+			n = &GraphNode{ID: id, Name: nf.loc.NF}
+			g.nodes[id] = n
+		}
+		if n.Type == "" {
+			n.Type = nfType(nf.loc.NF, nf.provider)
+		}
+		if n.Provider == "" {
+			n.Provider = nf.provider
+		}
+		if n.Cluster == "" {
+			n.Cluster = nf.loc.Cluster
+		}
+		if nf.loc.Repo != "" {
+			n.Repos = dedupSorted(append(n.Repos, nf.loc.Repo))
+		}
+		n.Sources = append(n.Sources, nf.loc.where())
+		for _, name := range nf.interfaces {
+			g.iface(n, name)
+		}
+	}
+
+	// declared addresses and their subnets
+	for _, u := range inv.uses {
+		if u.Role != roleAddress || u.Live {
+			continue
+		}
+		id := g.nodeOf(u)
+		if id == "" || u.Interface == "" {
+			continue
+		}
+		gi := g.iface(g.nodes[id], u.Interface)
+		addr := u.Address
+		if u.CIDR != "" {
+			addr = u.CIDR
+		}
+		gi.Addresses = dedupSorted(append(gi.Addresses, addr))
+		if p, err := netip.ParsePrefix(u.CIDR); err == nil {
+			gi.Subnets = dedupSorted(append(gi.Subnets, p.Masked().String()))
+		}
+	}
+	// interfaces without a CIDR take the subnets of their NAD
+	for _, nf := range inv.nfs {
+		n := g.nodes[g.nodeID(nf.loc.NF, nf.loc.Cluster, nf.loc.Repo)]
+		for i := range n.Interfaces {
+			gi := &n.Interfaces[i]
+			if len(gi.Subnets) > 0 {
+				continue
+			}
+			for _, nad := range inv.nads {
+				if nad.loc.Cluster != nf.loc.Cluster || nad.loc.Repo != nf.loc.Repo || !networkNameMatches(nad.iface.Name, gi.Name) {
+					continue
+				}
+				gi.Subnets = dedupSorted(append(gi.Subnets, nadSubnets(nad.iface)...))
+			}
+		}
+	}
+
+	g.subnetEdges()
+	g.referenceEdges()
+	return g
+}
+
+// iface returns the interface called name of n, adding it when missing.
+func (g *graphBuilder) iface(n *GraphNode, name string) *GraphInterface {
+	for i := range n.Interfaces {
+		if n.Interfaces[i].Name == name {
+			return &n.Interfaces[i]
+		}
+	}
+	n.Interfaces = append(n.Interfaces, GraphInterface{Name: name, Kind: interfaceKind(name)})
+	return &n.Interfaces[len(n.Interfaces)-1]
+}
+
+// nadSubnets returns the subnets a NAD network allocates from or assigns.
+func nadSubnets(iface NetworkInterface) []string {
+	var out []string
+	if iface.NAD != nil {
+		for _, r := range iface.NAD.Ranges {
+			if p, err := netip.ParsePrefix(r.Range); err == nil {
+				out = append(out, p.Masked().String())
+			}
+		}
+	}
+	for _, c := range iface.CIDRs {
+		if p, err := netip.ParsePrefix(c); err == nil {
+			out = append(out, p.Masked().String())
+		}
+	}
+	return out
+}
+
+// addEdge records an edge between two nodes, merging it with an edge of the
+// same interface between them. A 3GPP edge is oriented as interfaceEnds lists
+// its types.
+func (g *graphBuilder) addEdge(from, to, iface, subnet, source, evidence string) {
+	if ends, ok := interfaceEnds[iface]; ok && !typeFits(g.nodes[from].Type, ends[0]) && typeFits(g.nodes[to].Type, ends[0]) {
+		from, to = to, from
+	}
+	a, b := from, to
+	if b < a {
+		a, b = b, a
+	}
+	k := a + "|" + b + "|" + iface
+	e := g.edges[k]
+	if e == nil {
+		e = &GraphEdge{From: from, To: to, Interface: iface}
+		g.edges[k] = e
+		g.order = append(g.order, k)
+	}
+	if subnet != "" {
+		e.Subnets = dedupSorted(append(e.Subnets, subnet))
+	}
+	e.Sources = dedupSorted(append(e.Sources, source))
+	for _, ev := range e.Evidence {
+		if ev == evidence {
+			return
+		}
+	}
+	e.Evidence = append(e.Evidence, evidence)
+}
+
+// subnetEdges connects NFs that have the same 3GPP interface on the same
+// subnet and whose types fit its two ends. N6 goes to a data network node per
+// subnet.
+func (g *graphBuilder) subnetEdges() {
+	type member struct {
+		node  string
+		iface string
+	}
+	bySubnet := map[string][]member{} // kind|subnet
+	ids := make([]string, 0, len(g.nodes))
+	for id := range g.nodes {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		for _, gi := range g.nodes[id].Interfaces {
+			if gi.Kind == "" {
+				continue
+			}
+			kinds := []string{gi.Kind}
+			if gi.Kind == "F1" {
+				kinds = []string{"F1-C", "F1-U"} // an F1 not split into C and U planes
+			}
+			for _, k := range kinds {
+				for _, s := range gi.Subnets {
+					bySubnet[k+"|"+s] = append(bySubnet[k+"|"+s], member{id, gi.Name})
+				}
+			}
+		}
+	}
+	keys := make([]string, 0, len(bySubnet))
+	for k := range bySubnet {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		kind, subnet, _ := strings.Cut(k, "|")
+		ends := interfaceEnds[kind]
+		ms := bySubnet[k]
+		if kind == "N6" {
+			for _, m := range ms {
+				if t := g.nodes[m.node].Type; t != "" && t != nfUPF && t != nfDN {
+					continue
+				}
+				dn := "dn:" + subnet
+				if g.nodes[dn] == nil {
+					g.nodes[dn] = &GraphNode{ID: dn, Name: "DN " + subnet, Type: nfDN}
+				}
+				g.addEdge(m.node, dn, kind, subnet, edgeFromSubnet, fmt.Sprintf("%s %s is on %s", m.node, m.iface, subnet))
+			}
+			continue
+		}
+		for i, x := range ms {
+			for _, y := range ms[i+1:] {
+				if x.node == y.node {
+					continue
+				}
+				tx, ty := g.nodes[x.node].Type, g.nodes[y.node].Type
+				if !(typeFits(tx, ends[0]) && typeFits(ty, ends[1]) || typeFits(tx, ends[1]) && typeFits(ty, ends[0])) {
+					continue
+				}
+				g.addEdge(x.node, y.node, kind, subnet, edgeFromSubnet,
+					fmt.Sprintf("%s %s and %s %s are both on %s", x.node, x.iface, y.node, y.iface, subnet))
+			}
+		}
+	}
+}
+
+// referenceEdges connects the NF whose configuration mentions an address to
+// the NF that owns it.
+func (g *graphBuilder) referenceEdges() {
+	owners := map[netip.Addr][]AddrUse{}
+	for _, u := range g.inv.uses {
+		if u.Role != roleAddress || g.nodeOf(u) == "" {
+			continue
+		}
+		if a, err := netip.ParseAddr(u.Address); err == nil {
+			owners[a.Unmap()] = append(owners[a.Unmap()], u)
+		}
+	}
+	for _, r := range g.inv.uses {
+		if r.Role != roleReference {
+			continue
+		}
+		a, err := netip.ParseAddr(r.Address)
+		if err != nil || len(owners[a.Unmap()]) == 0 {
+			continue
+		}
+		from := g.configOwner(r)
+		if from == "" {
+			continue
+		}
+		for _, o := range owners[a.Unmap()] {
+			to := g.nodeOf(o)
+			if to == from {
+				continue
+			}
+			iface := interfaceKind(o.Interface)
+			if iface == "" {
+				iface = interfaceKind(o.Network)
+			}
+			if iface == "" {
+				iface = o.Interface
+			}
+			g.addEdge(from, to, iface, "", edgeFromReference,
+				fmt.Sprintf("%s references %s, the %s address of %s", r.where(), r.Address, o.Interface, to))
+		}
+	}
+}
+
+// configOwner finds the NF a configuration object belongs to among the NFs of
+// the same repo, or of the same cluster and namespace: the one whose name the
+// object's name contains (cucp-config), else the one of the type its name
+// names, else the only one.
+func (g *graphBuilder) configOwner(u AddrUse) string {
+	parts := strings.Split(u.Object, "/")
+	objName, objNS := strings.ToLower(parts[len(parts)-1]), ""
+	if len(parts) == 3 {
+		objNS = parts[1]
+	}
+	candidates := map[string]bool{}
+	for _, nf := range g.inv.nfs {
+		l := nf.loc
+		if u.Repo != "" && l.Repo != u.Repo {
+			continue
+		}
+		if u.Repo == "" && (l.Repo != "" || l.Cluster != u.Cluster || !strings.Contains(l.Object, "/"+objNS+"/")) {
+			continue
+		}
+		candidates[g.nodeID(l.NF, l.Cluster, l.Repo)] = true
+	}
+	ids := make([]string, 0, len(candidates))
+	for id := range candidates {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	best, bestLen := "", 0
+	for _, id := range ids {
+		name := strings.ToLower(g.nodes[id].Name)
+		if strings.Contains(objName, name) && len(name) > bestLen {
+			best, bestLen = id, len(name)
+		}
+	}
+	if best != "" {
+		return best
+	}
+	if t := nfType(objName, ""); t != "" {
+		var typed []string
+		for _, id := range ids {
+			if g.nodes[id].Type == t {
+				typed = append(typed, id)
+			}
+		}
+		if len(typed) == 1 {
+			return typed[0]
+		}
+	}
+	if len(ids) == 1 {
+		return ids[0]
+	}
+	return ""
+}
+
+func (g *graphBuilder) nodeList() []GraphNode {
+	out := make([]GraphNode, 0, len(g.nodes))
+	for _, n := range g.nodes {
+		sort.Slice(n.Interfaces, func(i, j int) bool { return n.Interfaces[i].Name < n.Interfaces[j].Name })
+		out = append(out, *n)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if (out[i].Type == nfDN) != (out[j].Type == nfDN) {
+			return out[j].Type == nfDN
+		}
+		if gi, gj := graphGroup(out[i]), graphGroup(out[j]); gi != gj {
+			return gi < gj
+		}
+		return out[i].ID < out[j].ID
+	})
+	return out
+}
+
+func (g *graphBuilder) edgeList() []GraphEdge {
+	out := make([]GraphEdge, 0, len(g.edges))
+	for _, k := range g.order {
+		out = append(out, *g.edges[k])
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		oi, oj := interfaceOrder[out[i].Interface], interfaceOrder[out[j].Interface]
+		if oi == 0 {
+			oi = len(interfaceOrder) + 1
+		}
+		if oj == 0 {
+			oj = len(interfaceOrder) + 1
+		}
+		if oi != oj {
+			return oi < oj
+		}
+		if out[i].From != out[j].From {
+			return out[i].From < out[j].From
+		}
+		return out[i].To < out[j].To
+	})
+	return out
+}
+
+// peers returns the nodes connected to nf, matched by node id or NF name;
+// ok is false when no node matches.
+func (g *graphBuilder) peers(nf string) (out []GraphPeer, ok bool) {
+	focus := map[string]bool{}
+	for id, n := range g.nodes {
+		if n.Type != nfDN && (id == nf || n.Name == nf) {
+			focus[id] = true
+		}
+	}
+	if len(focus) == 0 {
+		return nil, false
+	}
+	out = []GraphPeer{}
+	for _, e := range g.edgeList() {
+		peer := ""
+		switch {
+		case focus[e.From] && !focus[e.To]:
+			peer = e.To
+		case focus[e.To] && !focus[e.From]:
+			peer = e.From
+		default:
+			continue
+		}
+		n := g.nodes[peer]
+		out = append(out, GraphPeer{Node: peer, Name: n.Name, Type: n.Type, Cluster: n.Cluster, Interface: e.Interface, Evidence: e.Evidence})
+	}
+	return out, true
+}
+
+// graphGroup is the cluster, else the repos, a node is drawn in.
+func graphGroup(n GraphNode) string {
+	switch {
+	case n.Cluster != "":
+		return n.Cluster
+	case len(n.Repos) > 0:
+		return "repo " + strings.Join(n.Repos, ", ")
+	}
+	return ""
+}
+
+func graphLabel(n GraphNode) string {
+	if n.Type == "" || n.Type == nfDN {
+		return n.Name
+	}
+	return n.Name + "\n" + n.Type
+}
+
+// graphMermaid renders the graph as a Mermaid flowchart, a subgraph per
+// cluster.
+func graphMermaid(nodes []GraphNode, edges []GraphEdge) string {
+	ids := map[string]string{}
+	for i, n := range nodes {
+		ids[n.ID] = fmt.Sprintf("n%d", i)
+	}
+	label := func(s string) string {
+		return `"` + strings.NewReplacer(`"`, "#quot;", "\n", "<br/>").Replace(s) + `"`
+	}
+	var b strings.Builder
+	b.WriteString("graph LR\n")
+	group := ""
+	for _, n := range nodes {
+		if gr := graphGroup(n); gr != group || n.Type == nfDN {
+			if group != "" {
+				b.WriteString("  end\n")
+			}
+			group = gr
+			if n.Type == nfDN {
+				group = ""
+			}
+			if group != "" {
+				fmt.Fprintf(&b, "  subgraph g%s[%s]\n", ids[n.ID], label(group))
+			}
+		}
+		indent := "  "
+		if group != "" {
+			indent = "    "
+		}
+		if n.Type == nfDN {
+			fmt.Fprintf(&b, "%s%s((%s))\n", indent, ids[n.ID], label(graphLabel(n)))
+		} else {
+			fmt.Fprintf(&b, "%s%s[%s]\n", indent, ids[n.ID], label(graphLabel(n)))
+		}
+	}
+	if group != "" {
+		b.WriteString("  end\n")
+	}
+	for _, e := range edges {
+		fmt.Fprintf(&b, "  %s ---|%s| %s\n", ids[e.From], label(e.Interface), ids[e.To])
+	}
+	return b.String()
+}
+
+// graphDOT renders the graph as an undirected Graphviz graph, a cluster
+// subgraph per cluster.
+func graphDOT(nodes []GraphNode, edges []GraphEdge) string {
+	q := strconv.Quote
+	var b strings.Builder
+	b.WriteString("graph topology {\n  rankdir=LR;\n  node [shape=box];\n")
+	group, n := "", 0
+	for _, node := range nodes {
+		if gr := graphGroup(node); gr != group || node.Type == nfDN {
+			if group != "" {
+				b.WriteString("  }\n")
+			}
+			group = gr
+			if node.Type == nfDN {
+				group = ""
+			}
+			if group != "" {
+				fmt.Fprintf(&b, "  subgraph %s {\n    label=%s;\n", q(fmt.Sprintf("cluster_%d", n)), q(group))
+				n++
+			}
+		}
+		indent := "  "
+		if group != "" {
+			indent = "    "
+		}
+		attrs := "label=" + q(graphLabel(node))
+		if node.Type == nfDN {
+			attrs += ", shape=ellipse"
+		}
+		fmt.Fprintf(&b, "%s%s [%s];\n", indent, q(node.ID), attrs)
+	}
+	if group != "" {
+		b.WriteString("  }\n")
+	}
+	for _, e := range edges {
+		fmt.Fprintf(&b, "  %s -- %s [label=%s];\n", q(e.From), q(e.To), q(e.Interface))
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
@@ -0,0 +1,466 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+func init() { registerTool(TopologyValidate()) }
+
+// Finding severities: an error must be fixed before a push, a warning is worth
+// a look, info is context.
+const (
+	severityError   = "error"
+	severityWarning = "warning"
+	severityInfo    = "info"
+)
+
+// Checks named in ValidationFinding.Check.
+const (
+	checkDuplicateIP      = "duplicate-ip"
+	checkGatewayConflict  = "gateway-conflict" // address used as a gateway elsewhere, or equal to its own gateway
+	checkReferenced       = "referenced"
+	checkGatewaySubnet    = "gateway-outside-subnet"
+	checkReservedAddress  = "reserved-address" // network or broadcast address
+	checkCIDROverlap      = "cidr-overlap"
+	checkOutsideIPAMRange = "outside-ipam-range"
+	checkPrefixMismatch   = "prefix-mismatch"
+)
+
+type TopologyValidateParams struct {
+	NewIPs   map[string]IPInfo `json:"newIps,omitempty" description:"Planned addressing per interface, as returned by ip_plan_allocate or given to manifest_patch_cucp_ips."`
+	NF       string            `json:"nf,omitempty" description:"NFDeployment the newIps are for, so its own current addresses are not reported as duplicates." example:"cucp"`
+	Cluster  string            `json:"cluster,omitempty" description:"Cluster the newIps and repos deploy to (kube context or workload cluster): its pod/service CIDRs must not overlap them." example:"5g-edge"`
+	Repos    []RepoWorkdir     `json:"repos,omitempty" description:"Patched workdirs whose NFDeployment, NAD and IPClaim addresses are validated too."`
+	Clusters []string          `json:"clusters,omitempty" description:"Clusters to check for duplicates, as kube contexts or workload clusters (name or namespace/name); every kubeconfig context and CAPI cluster when empty."`
+	Fleet    []RepoWorkdir     `json:"fleet,omitempty" description:"Other cloned repositories whose addresses count as used but are not validated themselves."`
+
+	Namespace      string `json:"namespace,omitempty" description:"Only read cluster objects and pods in this namespace; all namespaces when empty."`
+	Context        string `json:"context,omitempty" description:"Management cluster kube context; defaults to the -mgmt-context flag, then kube.managementContext from the server config, then the current context."`
+	Concurrency    int    `json:"concurrency,omitempty" description:"How many clusters to read at once; defaults to scan.clusterConcurrency from the server config (4)."`
+	ClusterTimeout string `json:"clusterTimeout,omitempty" description:"Deadline for one cluster (Go duration); defaults to scan.clusterTimeout from the server config (60s)." example:"30s"`
+}
+
+// ValidationFinding is one problem with an address being validated.
+type ValidationFinding struct {
+	Severity string    `json:"severity"` // error, warning or info
+	Check    string    `json:"check"`
+	Message  string    `json:"message"`
+	Address  string    `json:"address,omitempty"`
+	Subject  AddrUse   `json:"subject"`          // the address validated
+	Others   []AddrUse `json:"others,omitempty"` // the uses it collides with
+}
+
+type TopologyValidateResult struct {
+	// Valid is true when no finding has severity error
+	Valid bool `json:"valid"`
+	// Complete is true when every cluster and source could be read; otherwise
+	// a duplicate may have been missed
+	Complete bool                `json:"complete"`
+	Findings []ValidationFinding `json:"findings"`
+	Counts   map[string]int      `json:"counts"` // findings per severity
+	Checked  int                 `json:"checked"`
+	Clusters []string            `json:"clusters,omitempty"`
+	Errors   []ScanIssue         `json:"errors,omitempty"`
+	Warnings []ScanIssue         `json:"warnings,omitempty"`
+}
+
+func (r *TopologyValidateResult) addIssue(source string, err error) {
+	if issue, warning := newScanIssue(source, err); warning {
+		r.Warnings = append(r.Warnings, issue)
+	} else {
+		r.Errors = append(r.Errors, issue)
+	}
+}
+
+func TopologyValidate() MCPTool[TopologyValidateParams, TopologyValidateResult] {
+	return MCPTool[TopologyValidateParams, TopologyValidateResult]{
+		Name:        "topology_validate",
+		Description: "Validate planned addresses (newIps) and/or patched workdirs against the fleet before a push. Reads every cluster (NADs, NFDeployments, NFConfigs, live pod addresses, pod/service CIDRs) and the given repos, then reports, with severity: the same IP used by another NF anywhere (duplicate-ip), an address that is a gateway elsewhere (gateway-conflict), gateways outside their subnet, network/broadcast addresses, subnets overlapping the target cluster's pod/service CIDRs, addresses outside the NAD IPAM range, and prefix-length mismatches. Example: {\"newIps\":{\"f1c\":{\"address\":\"10.20.1.10/24\",\"gateway\":\"10.20.1.1\"}},\"nf\":\"cucp\",\"cluster\":\"5g-edge\",\"repos\":[{\"name\":\"cucp\",\"workdir\":\"/work/cucp\"}]}.",
+		Timeout:     5 * time.Minute,
+		Handler: func(ctx context.Context, cc *mcp.ServerSession, params *mcp.CallToolParamsFor[TopologyValidateParams]) (*mcp.CallToolResultFor[TopologyValidateResult], error) {
+			a := params.Arguments
+			if len(a.NewIPs) == 0 && len(a.Repos) == 0 {
+				return toolErr[TopologyValidateResult](missingField("newIps"))
+			}
+			planned := make(map[string]ifaceAddrs, len(a.NewIPs))
+			for name, ip := range a.NewIPs {
+				ia, err := resolveIPInfo(name, ip)
+				if err != nil {
+					return toolErr[TopologyValidateResult](err)
+				}
+				planned[strings.TrimSpace(name)] = ia
+			}
+
+			concurrency, clusterTimeout, err := fleetScanLimits(a.Concurrency, a.ClusterTimeout)
+			if err != nil {
+				return toolErr[TopologyValidateResult](err)
+			}
+
+			out := TopologyValidateResult{Findings: []ValidationFinding{}, Counts: map[string]int{}}
+			target := strings.TrimSpace(a.Cluster)
+			clusters, err := fleetClusters(ctx, a.Context, a.Clusters, target, out.addIssue)
+			if err != nil {
+				return toolErr[TopologyValidateResult](err)
+			}
+
+			for _, fc := range clusters {
+				out.Clusters = append(out.Clusters, fc.name)
+			}
+			inv := scanFleet(ctx, a.Context, clusters, strings.TrimSpace(a.Namespace), concurrency, clusterTimeout, fleetReporter(&out.Errors, &out.Warnings))
+			for _, r := range a.Fleet {
+				r.Name, r.Workdir = strings.TrimSpace(r.Name), cleanPath(r.Workdir)
+				if r.Workdir != "" {
+					inv.addRepo(r, out.addIssue)
+				}
+			}
+			for _, r := range a.Repos {
+				r.Name, r.Workdir = strings.TrimSpace(r.Name), cleanPath(r.Workdir)
+				if r.Workdir == "" {
+					continue
+				}
+				n := len(inv.uses)
+				inv.addRepo(r, out.addIssue)
+				for i := n; i < len(inv.uses); i++ {
+					inv.uses[i].subject = true
+				}
+			}
+			targetName := target
+			for _, fc := range clusters {
+				if fc.ref == target || fc.name == target {
+					targetName = fc.name
+				}
+			}
+			inv.addPlanned(planned, strings.TrimSpace(a.NF), targetName)
+
+			out.Findings = inv.validate(targetName)
+			for _, u := range inv.uses {
+				if u.subject {
+					out.Checked++
+				}
+			}
+			out.Valid = true
+			for _, f := range out.Findings {
+				out.Counts[f.Severity]++
+				if f.Severity == severityError {
+					out.Valid = false
+				}
+			}
+			out.Complete = len(out.Errors) == 0
+			return toolOK(out), nil
+		},
+	}
+}
+
+// addPlanned records planned addressing as subjects.
+func (inv *addrInventory) addPlanned(planned map[string]ifaceAddrs, nf, cluster string) {
+	names := make([]string, 0, len(planned))
+	for n := range planned {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	for _, iface := range names {
+		for _, ag := range []AddrGateway{planned[iface].v4, planned[iface].v6} {
+			loc := AddrUse{Interface: iface, NF: nf, Cluster: cluster, Object: "newIps/" + iface, subject: true}
+			if p, err := netip.ParsePrefix(ag.Address); err == nil {
+				u := loc
+				u.Address, u.CIDR, u.Role = p.Addr().String(), ag.Address, roleAddress
+				inv.uses = append(inv.uses, u)
+			}
+			if g, err := netip.ParseAddr(ag.Gateway); err == nil {
+				u := loc
+				u.Address, u.Role = g.String(), roleGateway
+				inv.uses = append(inv.uses, u)
+			}
+		}
+	}
+}
+
+// sameInterface reports whether a and b name the same network interface,
+// directly or through a NAD name (n2, cucp-n2).
+func sameInterface(a, b AddrUse) bool {
+	for _, x := range []string{a.Interface, a.Network} {
+		for _, y := range []string{b.Interface, b.Network} {
+			if x != "" && y != "" && (networkNameMatches(x, y) || networkNameMatches(y, x)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// sameOwner tells whether two uses of an address are the same interface of the
+// same NF seen in different places (repo and cluster, declared and live). sure
+// is false when an NF is unknown and only the interface names matched or not.
+// A NAD named after the NF (cucp-f1c for cucp) is taken to be the NF's.
+func sameOwner(a, b AddrUse) (same, sure bool) {
+	if a.NF != "" && b.NF != "" {
+		return a.NF == b.NF, true
+	}
+	for _, x := range [][2]AddrUse{{a, b}, {b, a}} {
+		if x[0].NF != "" && isNADUse(x[1]) && strings.HasPrefix(path.Base(x[1].Object), x[0].NF+"-") {
+			return sameInterface(a, b), true
+		}
+	}
+	return sameInterface(a, b), false
+}
+
+func isNADUse(u AddrUse) bool {
+	return strings.HasPrefix(u.Object, "NetworkAttachmentDefinition/")
+}
+
+// validate checks the subject uses against the whole inventory. target is the
+// cluster the subjects deploy to; without one, overlaps with any cluster's
+// pod/service CIDRs are only warnings.
+func (inv *addrInventory) validate(target string) []ValidationFinding {
+	byAddr := map[netip.Addr][]int{}
+	for i, u := range inv.uses {
+		if a, err := netip.ParseAddr(u.Address); err == nil {
+			byAddr[a.Unmap()] = append(byAddr[a.Unmap()], i)
+		}
+	}
+	var out []ValidationFinding
+	add := func(sev, check string, s AddrUse, others []AddrUse, format string, args ...any) {
+		out = append(out, ValidationFinding{Severity: sev, Check: check, Message: fmt.Sprintf(format, args...), Address: s.Address, Subject: s, Others: others})
+	}
+
+	// collisions with other uses of the same address
+	for i, s := range inv.uses {
+		if !s.subject || s.Role != roleAddress {
+			continue
+		}
+		a, err := netip.ParseAddr(s.Address)
+		if err != nil {
+			continue
+		}
+		var dupErr, dupWarn, gws, refs []AddrUse
+		for _, j := range byAddr[a.Unmap()] {
+			o := inv.uses[j]
+			if j == i || (o.subject && j < i && o.Role == roleAddress) {
+				continue // itself, or a pair already reported from the other side
+			}
+			switch o.Role {
+			case roleAddress:
+				// with an NF unknown, an interface of the same name may
+				// still be another NF's (a DU's f1c for a CU-CP's f1c): an
+				// error when that use is a NAD or live in the target cluster
+				same, sure := sameOwner(s, o)
+				switch {
+				case same && sure:
+					continue
+				case sure, same && (isNADUse(o) || o.Live && target != "" && o.Cluster == target):
+					dupErr = append(dupErr, o)
+				default:
+					dupWarn = append(dupWarn, o)
+				}
+			case roleGateway:
+				gws = append(gws, o)
+			case roleReference:
+				refs = append(refs, o)
+			}
+		}
+		if len(dupErr) > 0 {
+			add(severityError, checkDuplicateIP, s, dupErr, "%s is also the address of %s", s.Address, describeUses(dupErr))
+		}
+		if len(dupWarn) > 0 {
+			add(severityWarning, checkDuplicateIP, s, dupWarn, "%s is also used by %s, which may be another NF", s.Address, describeUses(dupWarn))
+		}
+		if len(gws) > 0 {
+			add(severityError, checkGatewayConflict, s, gws, "%s is a gateway in %s", s.Address, describeUses(gws))
+		}
+		if len(refs) > 0 {
+			add(severityInfo, checkReferenced, s, refs, "%s is referenced by %s", s.Address, describeUses(refs))
+		}
+	}
+
+	// per interface: gateways, reserved addresses, pod/service CIDRs, NAD ranges
+	type group struct {
+		addrs []AddrUse
+		gws   []AddrUse
+	}
+	groups := map[string]*group{}
+	var keys []string
+	for _, u := range inv.uses {
+		if !u.subject {
+			continue
+		}
+		k := u.where() + "|" + u.Interface + "|" + addrFamily(u.Address)
+		if groups[k] == nil {
+			groups[k] = &group{}
+			keys = append(keys, k)
+		}
+		switch u.Role {
+		case roleAddress:
+			groups[k].addrs = append(groups[k].addrs, u)
+		case roleGateway:
+			groups[k].gws = append(groups[k].gws, u)
+		}
+	}
+	for _, k := range keys {
+		g := groups[k]
+		var prefixes []netip.Prefix
+		for _, s := range g.addrs {
+			p, err := netip.ParsePrefix(s.CIDR)
+			if err != nil {
+				continue
+			}
+			prefixes = append(prefixes, p)
+			a, subnet := p.Addr(), p.Masked()
+			if p.Bits() < a.BitLen()-1 && (a == subnet.Addr() || (a.Is4() && a == lastAddr(subnet))) {
+				add(severityError, checkReservedAddress, s, nil, "%s is the network or broadcast address of %s", s.Address, subnet)
+			}
+			for _, gw := range g.gws {
+				if gw.Address == s.Address {
+					add(severityError, checkGatewayConflict, s, []AddrUse{gw}, "%s is its own gateway", s.Address)
+				}
+			}
+			for _, rc := range inv.reserved {
+				if !rc.prefix.Overlaps(subnet) {
+					continue
+				}
+				if target != "" && rc.cluster != target {
+					continue
+				}
+				sev := severityError
+				if target == "" {
+					sev = severityWarning
+				}
+				add(sev, checkCIDROverlap, s, nil, "subnet %s overlaps the %s %s CIDR %s", subnet, rc.cluster, rc.kind, rc.prefix)
+			}
+			inv.checkNAD(s, p, add)
+			inv.checkPrefixLength(s, p, add)
+		}
+		for _, gw := range g.gws {
+			a, err := netip.ParseAddr(gw.Address)
+			if err != nil || len(prefixes) == 0 {
+				continue
+			}
+			in := false
+			for _, p := range prefixes {
+				if p.Masked().Contains(a) {
+					in = true
+				}
+			}
+			if !in {
+				add(severityError, checkGatewaySubnet, gw, nil, "gateway %s is outside %s", gw.Address, prefixList(prefixes))
+			}
+		}
+	}
+
+	rank := map[string]int{severityError: 0, severityWarning: 1, severityInfo: 2}
+	sort.SliceStable(out, func(i, j int) bool {
+		if rank[out[i].Severity] != rank[out[j].Severity] {
+			return rank[out[i].Severity] < rank[out[j].Severity]
+		}
+		return out[i].Address < out[j].Address
+	})
+	return out
+}
+
+// checkNAD compares an address with the IPAM ranges of the interface's NAD:
+// outside its subnet is an error, outside the range bounds a warning, and a
+// different prefix length a warning.
+func (inv *addrInventory) checkNAD(s AddrUse, p netip.Prefix, add func(sev, check string, s AddrUse, others []AddrUse, format string, args ...any)) {
+	if isNADUse(s) {
+		return
+	}
+	a := p.Addr()
+	for _, n := range inv.nadsFor(s.Interface) {
+		if n.iface.NAD == nil {
+			continue
+		}
+		var ranges []IPRange
+		for _, r := range n.iface.NAD.Ranges {
+			if addrFamily(r.Range) == addrFamily(s.Address) {
+				ranges = append(ranges, r)
+			}
+		}
+		if len(ranges) == 0 {
+			continue
+		}
+		nad := n.loc
+		in := false
+		for _, r := range ranges {
+			rp, err := netip.ParsePrefix(r.Range)
+			if err != nil || !rp.Masked().Contains(a) {
+				continue
+			}
+			in = true
+			if rp.Bits() != p.Bits() {
+				add(severityWarning, checkPrefixMismatch, s, []AddrUse{nad}, "%s has prefix length /%d but the NAD %s range is %s", s.CIDR, p.Bits(), n.iface.Name, r.Range)
+			}
+			start, serr := netip.ParseAddr(r.RangeStart)
+			end, eerr := netip.ParseAddr(r.RangeEnd)
+			if (serr == nil && a.Less(start)) || (eerr == nil && end.Less(a)) {
+				add(severityWarning, checkOutsideIPAMRange, s, []AddrUse{nad}, "%s is outside the NAD %s range %s-%s", s.Address, n.iface.Name, r.RangeStart, r.RangeEnd)
+			}
+		}
+		if !in {
+			rs := make([]string, 0, len(ranges))
+			for _, r := range ranges {
+				rs = append(rs, r.Range)
+			}
+			add(severityError, checkOutsideIPAMRange, s, []AddrUse{nad}, "%s is outside the NAD %s subnet %s", s.Address, n.iface.Name, strings.Join(rs, ", "))
+		}
+		return // the first NAD with ranges of the family decides
+	}
+}
+
+// checkPrefixLength warns when another address of the same interface in the
+// same network is declared with a different prefix length.
+func (inv *addrInventory) checkPrefixLength(s AddrUse, p netip.Prefix, add func(sev, check string, s AddrUse, others []AddrUse, format string, args ...any)) {
+	var others []AddrUse
+	for _, o := range inv.uses {
+		if o.Role != roleAddress || o.CIDR == "" || o.CIDR == s.CIDR || !sameInterface(s, o) {
+			continue
+		}
+		if o.subject && o.where()+o.CIDR < s.where()+s.CIDR {
+			continue // reported from o's side
+		}
+		op, err := netip.ParsePrefix(o.CIDR)
+		if err != nil || op.Bits() == p.Bits() {
+			continue
+		}
+		if op.Masked().Contains(p.Addr()) || p.Masked().Contains(op.Addr()) {
+			others = append(others, o)
+		}
+	}
+	if len(others) > 0 {
+		add(severityWarning, checkPrefixMismatch, s, others, "%s and %s are in the same network with different prefix lengths", s.CIDR, describeUses(others))
+	}
+}
+
+// describeUses lists where uses are, for messages.
+func describeUses(uses []AddrUse) string {
+	parts := make([]string, 0, len(uses))
+	for _, u := range uses {
+		w := u.where()
+		if u.CIDR != "" && u.Role == roleAddress {
+			w = u.CIDR + " in " + w
+		}
+		if u.Interface != "" {
+			w += " (" + u.Interface + ")"
+		}
+		parts = append(parts, w)
+	}
+	parts = dedupSorted(parts)
+	if len(parts) > 3 {
+		return fmt.Sprintf("%s and %d more", strings.Join(parts[:3], ", "), len(parts)-3)
+	}
+	return strings.Join(parts, ", ")
+}
+
+func prefixList(ps []netip.Prefix) string {
+	s := make([]string, 0, len(ps))
+	for _, p := range ps {
+		s = append(s, p.Masked().String())
+	}
+	return strings.Join(dedupSorted(s), ", ")
+}
@@ -0,0 +1,49 @@
+package tools
+
+import "testing"
+
+func plannedF1C(t *testing.T, nf string, others ...AddrUse) []ValidationFinding {
+	t.Helper()
+	ia, err := resolveIPInfo("f1c", IPInfo{Address: "10.20.1.10/24", Gateway: "10.20.1.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	inv := &addrInventory{uses: others}
+	inv.addPlanned(map[string]ifaceAddrs{"f1c": ia}, nf, "edge")
+	return inv.validate("edge")
+}
+
+func duplicates(fs []ValidationFinding) map[string]int {
+	out := map[string]int{}
+	for _, f := range fs {
+		if f.Check == checkDuplicateIP {
+			out[f.Severity] += len(f.Others)
+		}
+	}
+	return out
+}
+
+func TestValidateDuplicateWithoutNF(t *testing.T) {
+	du := AddrUse{Address: "10.20.1.10", CIDR: "10.20.1.10/24", Role: roleAddress, Interface: "f1c", NF: "du", Cluster: "edge", Object: "NFDeployment/ran/du"}
+	got := duplicates(plannedF1C(t, "", du))
+	if got[severityWarning] != 1 || got[severityError] != 0 {
+		t.Errorf("DU NFDeployment f1c, nf omitted: duplicates %v; want one warning", got)
+	}
+}
+
+func TestValidateDuplicateOnNADAndLivePod(t *testing.T) {
+	nad := AddrUse{Address: "10.20.1.10", CIDR: "10.20.1.10/24", Role: roleAddress, Interface: "du-f1c", Cluster: "edge", Object: "NetworkAttachmentDefinition/ran/du-f1c"}
+	pod := AddrUse{Address: "10.20.1.10", Role: roleAddress, Interface: "f1c", Network: "du-f1c", Cluster: "edge", Object: "Pod/ran/du-0", Live: true}
+	got := duplicates(plannedF1C(t, "cucp", nad, pod))
+	if got[severityError] != 2 {
+		t.Errorf("DU NAD and unlabeled DU pod: duplicates %v; want two errors", got)
+	}
+
+	// the NF's own NFDeployment and NAD keep their address
+	own := AddrUse{Address: "10.20.1.10", CIDR: "10.20.1.10/24", Role: roleAddress, Interface: "f1c", NF: "cucp", Cluster: "edge", Object: "NFDeployment/ran/cucp"}
+	ownNAD := nad
+	ownNAD.Interface, ownNAD.Object = "cucp-f1c", "NetworkAttachmentDefinition/ran/cucp-f1c"
+	if got := duplicates(plannedF1C(t, "cucp", own, ownNAD)); len(got) != 0 {
+		t.Errorf("the NF's own uses: duplicates %v; want none", got)
+	}
+}
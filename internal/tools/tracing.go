@@ -0,0 +1,57 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+
+	"nfreconfig-mcp-server/internal/telemetry"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracedHandler wraps a tool handler in a server span. A trace context sent by the
+// client in params._meta becomes the parent, so the agent's trace continues here.
+func tracedHandler[I, O any](name string, h func(context.Context, *mcp.ServerSession, *mcp.CallToolParamsFor[I]) (*mcp.CallToolResultFor[O], error)) func(context.Context, *mcp.ServerSession, *mcp.CallToolParamsFor[I]) (*mcp.CallToolResultFor[O], error) {
+	return func(ctx context.Context, cc *mcp.ServerSession, params *mcp.CallToolParamsFor[I]) (*mcp.CallToolResultFor[O], error) {
+		if params != nil {
+			ctx = telemetry.ExtractFromMeta(ctx, params.Meta)
+		}
+		attrs := []attribute.KeyValue{attribute.String("mcp.tool.name", name)}
+		if cc != nil {
+			attrs = append(attrs, attribute.String("mcp.session.id", cc.ID()))
+		}
+		ctx, span := telemetry.Tracer().Start(ctx, "tool "+name,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(attrs...),
+		)
+		defer span.End()
+
+		res, err := h(ctx, cc, params)
+		if err != nil {
+			telemetry.RecordError(span, err)
+		} else if res != nil && res.IsError {
+			span.SetStatus(codes.Error, "tool returned isError")
+		}
+		return res, err
+	}
+}
+
+// startExecSpan opens a span for a subprocess. Only the subcommand and workdir are
+// recorded; full argument lists may carry URLs with credentials.
+func startExecSpan(ctx context.Context, workdir, name string, args ...string) (context.Context, trace.Span) {
+	spanName := "exec " + name
+	if len(args) > 0 {
+		spanName = fmt.Sprintf("exec %s %s", name, args[0])
+	}
+	attrs := []attribute.KeyValue{attribute.String("process.executable.name", name)}
+	if workdir != "" {
+		attrs = append(attrs, attribute.String("process.working_directory", workdir))
+	}
+	return telemetry.Tracer().Start(ctx, spanName,
+		trace.WithSpanKind(trace.SpanKindInternal),
+		trace.WithAttributes(attrs...),
+	)
+}
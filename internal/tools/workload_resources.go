@@ -2,7 +2,7 @@ package tools
 
 import (
 	"context"
-	"fmt"
+	"sort"
 	"strings"
 
 	"nfreconfig-mcp-server/internal/kube"
@@ -20,11 +20,11 @@ func init() {
 }
 
 type WorkloadResourceParams struct {
-	Context   string `json:"context,omitempty"`   // mgmt kubeconfig context; default = current
-	Cluster   string `json:"cluster"`             // CAPI Cluster name (e.g., 5g-edge)
-	Kind      string `json:"kind"`                // e.g., NFDeployment, NetworkAttachmentDefinition, NFConfig, Config, Application
-	Namespace string `json:"namespace,omitempty"` // list: "" or "*" => all namespaces; get/delete: must be set (namespaced kinds)
-	Name      string `json:"name,omitempty"`      // for get/delete
+	Context   string `json:"context,omitempty" description:"Management cluster kube context; defaults to the -mgmt-context flag, then kube.managementContext from the server config, then the current context."`
+	Cluster   string `json:"cluster" description:"Workload cluster (CAPI Cluster) as name or namespace/name; a bare name must be unique across namespaces." example:"5g-edge"`
+	Kind      string `json:"kind" description:"Resource kind." enum:"NFDeployment,NFConfig,Config,NetworkAttachmentDefinition,Application"`
+	Namespace string `json:"namespace,omitempty" description:"Namespace; for list, empty or * means all namespaces. Required for get/delete."`
+	Name      string `json:"name,omitempty" description:"Object name; required for get/delete."`
 }
 
 type WorkloadListResult struct {
@@ -36,22 +36,39 @@ type WorkloadGetResult struct {
 }
 
 type WorkloadDeleteResult struct {
-	Deleted bool   `json:"deleted"`
-	Error   string `json:"error,omitempty"`
+	Deleted bool       `json:"deleted"`
+	Error   *ToolError `json:"error,omitempty"`
 }
 
 // -------------------- defaults / helpers --------------------
 
 func defaultMgmtContext(explicit string) (string, error) {
-	if strings.TrimSpace(explicit) != "" {
-		return strings.TrimSpace(explicit), nil
+	explicit = strings.TrimSpace(explicit)
+	if explicit == "" {
+		explicit = kube.ManagementContext()
+	}
+	if explicit == "in-cluster" {
+		return explicit, nil
 	}
 	_, raw, err := kube.LoadRawConfig()
 	if err != nil {
 		return "", err
 	}
+	if explicit != "" {
+		if _, ok := raw.Contexts[explicit]; !ok {
+			te := invalidArgument("context", "kube context %q not found in %s", explicit, kube.DefaultKubeconfigPath())
+			names := make([]string, 0, len(raw.Contexts))
+			for n := range raw.Contexts {
+				names = append(names, n)
+			}
+			sort.Strings(names)
+			te.Hint = "available contexts: " + strings.Join(names, ", ")
+			return "", te
+		}
+		return explicit, nil
+	}
 	if strings.TrimSpace(raw.CurrentContext) == "" {
-		return "", fmt.Errorf("kubeconfig has empty currentContext; provide params.context explicitly")
+		return "", invalidArgument("context", "kubeconfig has empty currentContext; provide params.context explicitly")
 	}
 	return raw.CurrentContext, nil
 }
@@ -79,7 +96,7 @@ var kindMap = map[string]kindSpec{
 func resolveKind(kind string) (kindSpec, error) {
 	k := strings.TrimSpace(kind)
 	if k == "" {
-		return kindSpec{}, fmt.Errorf("missing required field: kind")
+		return kindSpec{}, missingField("kind")
 	}
 	if spec, ok := kindMap[k]; ok {
 		return spec, nil
@@ -89,13 +106,16 @@ func resolveKind(kind string) (kindSpec, error) {
 	for kk := range kindMap {
 		allowed = append(allowed, kk)
 	}
-	return kindSpec{}, fmt.Errorf("unsupported kind %q. allowed: %s", k, strings.Join(allowed, ", "))
+	sort.Strings(allowed)
+	te := invalidArgument("kind", "unsupported kind %q", k)
+	te.Hint = "allowed kinds: " + strings.Join(allowed, ", ")
+	return kindSpec{}, te
 }
 
 func requireCluster(cluster string) (string, error) {
 	c := strings.TrimSpace(cluster)
 	if c == "" {
-		return "", fmt.Errorf("missing required field: cluster")
+		return "", missingField("cluster")
 	}
 	return c, nil
 }
@@ -103,7 +123,7 @@ func requireCluster(cluster string) (string, error) {
 func requireName(name string) (string, error) {
 	n := strings.TrimSpace(name)
 	if n == "" {
-		return "", fmt.Errorf("missing required field: name")
+		return "", missingField("name")
 	}
 	return n, nil
 }
@@ -187,7 +207,7 @@ func WorkloadGetResource() MCPTool[WorkloadResourceParams, WorkloadGetResult] {
 			ns := cleanNamespace(params.Arguments.Namespace)
 			if ks.Namespaced {
 				if ns == "" || ns == "*" {
-					return toolErr[WorkloadGetResult](fmt.Errorf("namespace is required for get_resource (set a concrete namespace, not empty/*)"))
+					return toolErr[WorkloadGetResult](invalidArgument("namespace", "namespace is required for get_resource (set a concrete namespace, not empty/*)"))
 				}
 			}
 
@@ -238,7 +258,7 @@ func WorkloadDeleteResource() MCPTool[WorkloadResourceParams, WorkloadDeleteResu
 			ns := cleanNamespace(params.Arguments.Namespace)
 			if ks.Namespaced {
 				if ns == "" || ns == "*" {
-					return toolErr[WorkloadDeleteResult](fmt.Errorf("namespace is required for delete_resource (set a concrete namespace, not empty/*)"))
+					return toolErr[WorkloadDeleteResult](invalidArgument("namespace", "namespace is required for delete_resource (set a concrete namespace, not empty/*)"))
 				}
 			}
 